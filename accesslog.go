@@ -0,0 +1,86 @@
+package main
+
+import (
+	"go.uber.org/zap"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// accessRecorder 记录响应状态码以及本次读取是否命中，用于访问日志
+type accessRecorder struct {
+	http.ResponseWriter
+	status int
+	hit    *bool // 只有读取缓存的 GET 请求才会设置
+}
+
+func (r *accessRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *accessRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// 记录本次请求是否命中本地缓存，没有开启访问日志时什么都不做
+func markHit(w http.ResponseWriter, hit bool) {
+	if rec, ok := w.(*accessRecorder); ok {
+		rec.hit = &hit
+	}
+}
+
+// SetAccessLog 开启访问日志，每个请求处理完成后以 Info 级别输出方法、key、group、状态码、耗时以及是否命中
+// sampleRate 为记录的比例，1 表示记录所有请求，0.1 表示随机记录十分之一，小于等于 0 时关闭访问日志
+func (p *HTTPPool) SetAccessLog(sampleRate float64) {
+	if sampleRate > 1 {
+		sampleRate = 1
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.accessLogRate = sampleRate
+}
+
+// 按照采样比例决定本次请求是否输出访问日志
+func (p *HTTPPool) sampleAccessLog() bool {
+	p.mu.Lock()
+	rate := p.accessLogRate
+	p.mu.Unlock()
+	return rate > 0 && (rate >= 1 || rand.Float64() < rate)
+}
+
+// ServeHTTP 实现 http.Handler 接口，开启访问日志时包装一层记录请求的结果
+func (p *HTTPPool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !p.sampleAccessLog() {
+		p.serve(w, r)
+		return
+	}
+	start := time.Now()
+	rec := &accessRecorder{ResponseWriter: w}
+	p.serve(rec, r)
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	key := ""
+	if strings.HasPrefix(r.URL.Path, p.basePath) {
+		key = strings.TrimPrefix(r.URL.Path, p.basePath)
+	}
+	fields := []zap.Field{
+		zap.String("method", r.Method),
+		zap.String("path", r.URL.Path),
+		zap.String("key", key),
+		zap.String("group", r.URL.Query().Get("group")),
+		zap.Int("status", rec.status),
+		zap.Duration("latency", time.Since(start)),
+	}
+	if rec.hit != nil {
+		fields = append(fields, zap.Bool("hit", *rec.hit))
+	}
+	p.log.Info("HTTP 访问日志", fields...)
+}
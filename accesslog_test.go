@@ -0,0 +1,95 @@
+package main
+
+import (
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const accessLogMessage = "HTTP 访问日志"
+
+// 每个请求输出一条结构化的访问日志，包含方法、key、状态码、耗时以及是否命中
+func TestAccessLog(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	opt := DefaultCacheOptions()
+	opt.Logger = zap.New(core)
+	c := NewCache(&opt)
+	defer c.Close()
+	c.AddBytes("k", []byte("v"))
+	pool := NewHTTPPool("self", c)
+	do := func(method, path string) int {
+		w := httptest.NewRecorder()
+		pool.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader("x")))
+		return w.Code
+	}
+
+	do(http.MethodGet, defaultBasePath+"k")
+	if n := logs.FilterMessage(accessLogMessage).Len(); n != 0 {
+		t.Fatalf("没有开启访问日志时不应该输出，实际输出了 %d 条", n)
+	}
+
+	pool.SetAccessLog(1)
+	for _, req := range []struct {
+		method, path string
+		status       int
+	}{
+		{http.MethodGet, defaultBasePath + "k", http.StatusOK},
+		{http.MethodGet, defaultBasePath + "nope", http.StatusNotFound},
+		{http.MethodPut, defaultBasePath + "p", http.StatusOK},
+		{http.MethodGet, "/healthz", http.StatusOK},
+	} {
+		if code := do(req.method, req.path); code != req.status {
+			t.Fatalf("%s %s 返回 %d，期望 %d", req.method, req.path, code, req.status)
+		}
+	}
+	entries := logs.FilterMessage(accessLogMessage).All()
+	if len(entries) != 4 {
+		t.Fatalf("应该每个请求输出一条日志，实际输出了 %d 条", len(entries))
+	}
+	m := entries[0].ContextMap()
+	if m["method"] != http.MethodGet || m["key"] != "k" || m["status"] != int64(http.StatusOK) || m["hit"] != true {
+		t.Fatalf("命中请求的日志字段错误: %v", m)
+	}
+	if _, ok := m["latency"]; !ok {
+		t.Fatalf("日志中缺少 latency: %v", m)
+	}
+	if m = entries[1].ContextMap(); m["status"] != int64(http.StatusNotFound) || m["hit"] != false || m["key"] != "nope" {
+		t.Fatalf("未命中请求的日志字段错误: %v", m)
+	}
+	m = entries[2].ContextMap()
+	if m["method"] != http.MethodPut || m["status"] != int64(http.StatusOK) {
+		t.Fatalf("写入请求的日志字段错误: %v", m)
+	}
+	if _, ok := m["hit"]; ok {
+		t.Fatalf("写入请求不应该有 hit 字段: %v", m)
+	}
+}
+
+func TestAccessLogSampling(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	opt := DefaultCacheOptions()
+	opt.Logger = zap.New(core)
+	c := NewCache(&opt)
+	defer c.Close()
+	pool := NewHTTPPool("self", c)
+	do := func() {
+		pool.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, defaultBasePath+"k", nil))
+	}
+
+	pool.SetAccessLog(0.5)
+	for i := 0; i < 1000; i++ {
+		do()
+	}
+	if n := logs.FilterMessage(accessLogMessage).Len(); n < 350 || n > 650 {
+		t.Fatalf("采样率为 0.5 时 1000 个请求输出了 %d 条日志", n)
+	}
+	pool.SetAccessLog(0)
+	before := logs.FilterMessage(accessLogMessage).Len()
+	do()
+	if logs.FilterMessage(accessLogMessage).Len() != before {
+		t.Fatal("关闭访问日志之后不应该再输出")
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"Distributed-Cache-Go/lru"
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	defaultFailureThreshold = 5
+	defaultBreakerCooldown  = 10 * time.Second
+)
+
+// ErrCircuitOpen 远程节点的熔断器处于打开状态，请求没有发出
+var ErrCircuitOpen = errors.New("远程节点熔断中")
+
+// BreakerOptions 熔断器的参数
+type BreakerOptions struct {
+	FailureThreshold int           // 连续失败多少次后打开熔断器，默认为 5
+	Cooldown         time.Duration // 打开后经过多久进入半开状态，放行一个探测请求，默认为 10s
+	Clock            lru.Clock     // 时间来源，为空时使用系统时间，测试时可以传入 lru.FakeClock
+}
+
+// systemClock 使用系统时间，是熔断器默认的 Clock
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// 熔断器的状态
+const (
+	breakerClosed   = iota // 正常放行
+	breakerOpen            // 直接失败
+	breakerHalfOpen        // 只放行一个探测请求
+)
+
+// circuitBreaker 单个远程节点的熔断器
+// 连续失败 FailureThreshold 次后打开，冷却 Cooldown 后半开放行一个探测请求，探测成功则关闭，失败则重新打开
+type circuitBreaker struct {
+	mu       sync.Mutex
+	opts     BreakerOptions
+	state    int
+	failures int       // 连续失败的次数
+	openedAt time.Time // 最近一次打开的时间
+}
+
+func newCircuitBreaker(opts BreakerOptions) *circuitBreaker {
+	if opts.FailureThreshold <= 0 {
+		opts.FailureThreshold = defaultFailureThreshold
+	}
+	if opts.Cooldown <= 0 {
+		opts.Cooldown = defaultBreakerCooldown
+	}
+	if opts.Clock == nil {
+		opts.Clock = systemClock{}
+	}
+	return &circuitBreaker{opts: opts}
+}
+
+// 判断是否允许发出请求
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case breakerOpen:
+		if b.opts.Clock.Now().Sub(b.openedAt) < b.opts.Cooldown {
+			return false
+		}
+		// 冷却结束，进入半开状态并放行当前这一个请求作为探测
+		b.state = breakerHalfOpen
+		return true
+	case breakerHalfOpen:
+		// 探测请求还没有返回，其余请求直接失败
+		return false
+	default:
+		return true
+	}
+}
+
+// 记录请求的结果，远程节点未命中或者调用方主动取消时结果无法说明节点是否正常，既不算成功也不算失败
+func (b *circuitBreaker) record(ctx context.Context, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err != nil && !isPeerFailure(ctx, err) {
+		// 半开状态的探测被跳过时恢复为打开状态，冷却时间已经结束，下一个请求会重新探测
+		if b.state == breakerHalfOpen {
+			b.state = breakerOpen
+		}
+		return
+	}
+	if err == nil {
+		b.state = breakerClosed
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.state == breakerHalfOpen || b.failures >= b.opts.FailureThreshold {
+		b.state = breakerOpen
+		b.openedAt = b.opts.Clock.Now()
+	}
+}
+
+// breakerPeer 为远程节点加上熔断器，实现了 PeerGetter 和 PeerSetter 接口
+type breakerPeer struct {
+	peer    PeerGetter
+	breaker *circuitBreaker
+}
+
+// NewBreakerPeer 为 peer 加上熔断器，peer 实现了 PeerSetter 时写入同样受熔断器保护
+func NewBreakerPeer(peer PeerGetter, opts BreakerOptions) PeerGetter {
+	return &breakerPeer{peer: peer, breaker: newCircuitBreaker(opts)}
+}
+
+// 远程节点未命中或者调用方主动取消不算作失败
+func isPeerFailure(ctx context.Context, err error) bool {
+	return err != nil && !errors.Is(err, ErrPeerNotFound) && ctx.Err() == nil
+}
+
+func (p *breakerPeer) Get(ctx context.Context, group string, key string) ([]byte, error) {
+	if !p.breaker.allow() {
+		return nil, ErrCircuitOpen
+	}
+	b, err := p.peer.Get(ctx, group, key)
+	p.breaker.record(ctx, err)
+	return b, err
+}
+
+func (p *breakerPeer) Set(ctx context.Context, group string, key string, value []byte) error {
+	setter, ok := p.peer.(PeerSetter)
+	if !ok {
+		return errors.New("远程节点不支持写入")
+	}
+	if !p.breaker.allow() {
+		return ErrCircuitOpen
+	}
+	err := setter.Set(ctx, group, key, value)
+	p.breaker.record(ctx, err)
+	return err
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"Distributed-Cache-Go/lru"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// 返回预先设置的错误的远程节点
+type stubPeer struct {
+	calls int
+	err   error
+}
+
+func (p *stubPeer) Get(ctx context.Context, group string, key string) ([]byte, error) {
+	p.calls++
+	if p.err != nil {
+		return nil, p.err
+	}
+	return []byte("ok"), nil
+}
+
+func TestBreakerOpensAndRecovers(t *testing.T) {
+	clock := lru.NewFakeClock(time.Unix(0, 0))
+	peer := &stubPeer{err: errors.New("连接失败")}
+	b := NewBreakerPeer(peer, BreakerOptions{FailureThreshold: 3, Cooldown: time.Second, Clock: clock})
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		b.Get(ctx, "g", "k")
+	}
+	if peer.calls != 3 {
+		t.Fatalf("连续失败 3 次后应该熔断，实际调用了 %d 次", peer.calls)
+	}
+	clock.Advance(999 * time.Millisecond)
+	if _, err := b.Get(ctx, "g", "k"); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("冷却结束之前应该返回 ErrCircuitOpen，实际为 %v", err)
+	}
+	clock.Advance(time.Millisecond)
+	peer.err = nil
+	if _, err := b.Get(ctx, "g", "k"); err != nil || peer.calls != 4 {
+		t.Fatalf("冷却结束后应该放行探测请求: %v", err)
+	}
+	if _, err := b.Get(ctx, "g", "k"); err != nil || peer.calls != 5 {
+		t.Fatalf("探测成功后应该关闭熔断器: %v", err)
+	}
+}
+
+// 半开状态的探测请求因为未命中或者取消结束时，不能关闭熔断器，下一个请求重新探测
+func TestBreakerSkipsInconclusiveProbe(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		ctx  func() context.Context
+	}{
+		{"未命中", ErrPeerNotFound, context.Background},
+		{"取消", context.Canceled, func() context.Context {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			return ctx
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := lru.NewFakeClock(time.Unix(0, 0))
+			peer := &stubPeer{err: errors.New("连接失败")}
+			b := NewBreakerPeer(peer, BreakerOptions{FailureThreshold: 1, Cooldown: time.Second, Clock: clock})
+			b.Get(context.Background(), "g", "k")
+			clock.Advance(time.Second)
+
+			peer.err = tt.err
+			b.Get(tt.ctx(), "g", "k")
+			// 探测被跳过，熔断器没有关闭，下一个请求仍然是探测；探测失败后重新打开
+			peer.err = errors.New("连接失败")
+			b.Get(context.Background(), "g", "k")
+			if peer.calls != 3 {
+				t.Fatalf("跳过的探测之后应该重新探测，实际调用了 %d 次", peer.calls)
+			}
+			if _, err := b.Get(context.Background(), "g", "k"); !errors.Is(err, ErrCircuitOpen) {
+				t.Fatalf("探测失败后应该重新打开熔断器，实际为 %v", err)
+			}
+		})
+	}
+}
@@ -1,19 +1,80 @@
 package main
 
-// 实现Value接口
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// 归还到池中的缓冲区最大容量，更大的缓冲区直接丢弃，避免池长期占用大量内存
+const maxPooledBufferSize = 1 << 20
+
+// 读取数据时使用的临时缓冲区，数据拷贝到 ByteView 之后立即归还
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+// ByteView 实现Value接口，是一个不可变的字节视图
+// 内部的字节切片不会以引用的方式对外暴露，所有对外返回的切片都是拷贝
 type ByteView struct {
 	b []byte
 }
 
+// NewByteView 根据传入的字节切片创建 ByteView，会拷贝一份数据，调用方之后修改 b 不会影响缓存的值
+func NewByteView(b []byte) ByteView {
+	return ByteView{b: cloneBytes(b)}
+}
+
+// BuildByteView 调用 write 把数据写入一个池化的临时缓冲区，然后创建 ByteView
+// 适合需要先拼接或者编码再写入缓存的场景，临时缓冲区在返回前归还到池中，下一次构造时复用，
+// 返回的 ByteView 持有一份大小刚好的拷贝，不会与缓冲区共享内存，write 也不能在返回后继续持有 w
+func BuildByteView(write func(w io.Writer) error) (ByteView, error) {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer func() {
+		if buf.Cap() <= maxPooledBufferSize {
+			bufferPool.Put(buf)
+		}
+	}()
+	if err := write(buf); err != nil {
+		return ByteView{}, err
+	}
+	return NewByteView(buf.Bytes()), nil
+}
+
+// ReadByteView 读取 r 中的全部数据并创建 ByteView
+// 读取过程使用池化的临时缓冲区，避免 io.ReadAll 扩容时的多次分配；返回的 ByteView 持有一份大小刚好的拷贝，不会与缓冲区共享内存
+func ReadByteView(r io.Reader) (ByteView, error) {
+	return BuildByteView(func(w io.Writer) error {
+		_, err := w.(*bytes.Buffer).ReadFrom(r)
+		return err
+	})
+}
+
 func (v ByteView) Len() int {
 	return len(v.b)
 }
+
+// ByteSlice 返回数据的拷贝，防止缓存的值被外部修改
 func (v ByteView) ByteSlice() []byte {
 	return cloneBytes(v.b)
 }
 func (v ByteView) String() string {
 	return string(v.b)
 }
+
+// Reader 返回读取数据的只读 io.Reader，直接读取底层数据而不拷贝，由于 ByteView 不可变，读取期间数据不会被修改
+func (v ByteView) Reader() io.Reader {
+	return bytes.NewReader(v.b)
+}
+
+// Slice 返回 [start, end) 范围内的子视图，越界时与切片表达式一样会 panic
+// 子视图与原视图共享底层数据，由于两者都不可变，所以不需要拷贝
+func (v ByteView) Slice(start, end int) ByteView {
+	return ByteView{b: v.b[start:end]}
+}
 func cloneBytes(b []byte) []byte {
 	c := make([]byte, len(b))
 	copy(c, b)
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+)
+
+// 修改传入的切片、ByteSlice 返回的切片或者子视图返回的切片都不会影响缓存的值
+func TestByteViewImmutable(t *testing.T) {
+	src := []byte("hello")
+	v := NewByteView(src)
+	tests := []struct {
+		name   string
+		mutate func()
+	}{
+		{"修改传入的切片", func() { src[0] = 'x' }},
+		{"修改 ByteSlice 的返回值", func() { v.ByteSlice()[1] = 'y' }},
+		{"修改子视图 ByteSlice 的返回值", func() { v.Slice(1, 3).ByteSlice()[0] = 'z' }},
+	}
+	for _, tt := range tests {
+		tt.mutate()
+		if v.String() != "hello" {
+			t.Fatalf("%s 之后值变成了 %q", tt.name, v.String())
+		}
+	}
+	if s := v.Slice(1, 3); s.String() != "el" || s.Len() != 2 {
+		t.Fatalf("Slice(1, 3) = %q", s.String())
+	}
+
+	opt := DefaultCacheOptions()
+	c := NewCache(&opt)
+	defer c.Close()
+	c.Add("k", v)
+	got, _ := c.Get(context.Background(), "k")
+	got.ByteSlice()[0] = 'x'
+	if again, _ := c.Get(context.Background(), "k"); again.String() != "hello" {
+		t.Fatalf("修改读取到的值之后缓存中的值变成了 %q", again.String())
+	}
+}
+
+func TestBuildByteViewCopies(t *testing.T) {
+	v, err := BuildByteView(func(w io.Writer) error {
+		_, err := io.WriteString(w, "hello")
+		return err
+	})
+	if err != nil || v.String() != "hello" || cap(v.b) != 5 {
+		t.Fatalf("BuildByteView 结果错误: %q %v", v.String(), err)
+	}
+	// 第二次构造会复用同一个缓冲区，之前返回的值不能被覆盖
+	w, _ := ReadByteView(bytes.NewReader([]byte("xx")))
+	if v.String() != "hello" || w.String() != "xx" {
+		t.Fatalf("池化的缓冲区与返回值共享了内存: %q %q", v.String(), w.String())
+	}
+
+	want := errors.New("写入失败")
+	if _, err := BuildByteView(func(io.Writer) error { return want }); !errors.Is(err, want) {
+		t.Fatalf("应该返回 write 的错误，实际为 %v", err)
+	}
+}
+
+func TestAddFrom(t *testing.T) {
+	opt := DefaultCacheOptions()
+	c := NewCache(&opt)
+	defer c.Close()
+	if err := c.AddFrom("k", func(w io.Writer) error {
+		_, err := fmt.Fprintf(w, "user:%d", 42)
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := c.Get(context.Background(), "k"); !ok || v.String() != "user:42" {
+		t.Fatalf("读取到的值错误: %q %v", v.String(), ok)
+	}
+}
+
+// AddBytes/GetBytes 往返读写原始字节，修改传入或者返回的切片都不会影响缓存的值
+func TestAddGetBytes(t *testing.T) {
+	opt := DefaultCacheOptions()
+	c := NewCache(&opt)
+	defer c.Close()
+	ctx := context.Background()
+	src := []byte("abc")
+	c.AddBytes("k", src)
+	c.AddBytes("empty", nil)
+
+	tests := []struct {
+		name   string
+		mutate func()
+	}{
+		{"修改传入的切片", func() { src[0] = 'X' }},
+		{"修改 GetBytes 的返回值", func() {
+			b, _ := c.GetBytes(ctx, "k")
+			b[1] = 'Y'
+		}},
+	}
+	for _, tt := range tests {
+		tt.mutate()
+		if b, ok := c.GetBytes(ctx, "k"); !ok || string(b) != "abc" {
+			t.Fatalf("%s 之后值变成了 %q %v", tt.name, b, ok)
+		}
+	}
+	if b, ok := c.GetBytes(ctx, "empty"); !ok || len(b) != 0 {
+		t.Fatalf("空值读取为 %q %v", b, ok)
+	}
+	if _, ok := c.GetBytes(ctx, "none"); ok {
+		t.Fatal("不存在的key不应该命中")
+	}
+}
+
+// 比较每次写入的内存分配：先拼接出临时切片再 AddBytes，与直接写入池化缓冲区的 AddFrom
+func BenchmarkAddAllocs(b *testing.B) {
+	payload := bytes.Repeat([]byte("x"), 4<<10)
+	opt := DefaultCacheOptions()
+	c := NewCache(&opt)
+	defer c.Close()
+
+	b.Run("unpooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var buf bytes.Buffer
+			buf.WriteString("prefix:")
+			buf.Write(payload)
+			c.AddBytes("k", buf.Bytes())
+		}
+	})
+	b.Run("pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			c.AddFrom("k", func(w io.Writer) error {
+				io.WriteString(w, "prefix:")
+				_, err := w.Write(payload)
+				return err
+			})
+		}
+	})
+}
+
+func TestGetReader(t *testing.T) {
+	for _, threshold := range []int{0, 100} {
+		t.Run(fmt.Sprintf("CompressThreshold=%d", threshold), func(t *testing.T) {
+			opt := DefaultCacheOptions()
+			opt.CompressThreshold = threshold
+			c := NewCache(&opt)
+			defer c.Close()
+			ctx := context.Background()
+			data := bytes.Repeat([]byte("abcdefgh"), 10000)
+			c.AddBytes("k", data)
+
+			r, n, ok := c.GetReader(ctx, "k")
+			if !ok || n != len(data) {
+				t.Fatalf("GetReader 返回长度 %d %v，期望 %d", n, ok, len(data))
+			}
+			if got, err := io.ReadAll(r); err != nil || !bytes.Equal(got, data) {
+				t.Fatalf("读取到的数据错误: %v", err)
+			}
+			if _, _, ok := c.GetReader(ctx, "none"); ok {
+				t.Fatal("不存在的key不应该返回 reader")
+			}
+		})
+	}
+}
+
+// ReadInto 把值拷贝到调用方的缓冲区，缓冲区太小时写满并返回 false，修改缓冲区不会影响缓存的值
+func TestReadInto(t *testing.T) {
+	opt := DefaultCacheOptions()
+	c := NewCache(&opt)
+	defer c.Close()
+	c.AddBytes("a", []byte("hello"))
+	c.AddBytes("empty", nil)
+
+	tests := []struct {
+		name   string
+		key    string
+		size   int
+		wantN  int
+		wantOK bool
+		want   string
+	}{
+		{"缓冲区足够", "a", 8, 5, true, "hello"},
+		{"缓冲区刚好", "a", 5, 5, true, "hello"},
+		{"缓冲区太小", "a", 3, 3, false, "hel"},
+		{"空值", "empty", 3, 0, true, ""},
+		{"未命中", "none", 8, 0, false, ""},
+	}
+	for _, tt := range tests {
+		buf := make([]byte, tt.size)
+		n, ok := c.ReadInto(tt.key, buf)
+		if n != tt.wantN || ok != tt.wantOK || string(buf[:n]) != tt.want {
+			t.Fatalf("%s: ReadInto 返回 %d %v %q", tt.name, n, ok, buf[:n])
+		}
+		for i := range buf {
+			buf[i] = 'X'
+		}
+	}
+	if v, _ := c.Get(context.Background(), "a"); v.String() != "hello" {
+		t.Fatalf("修改缓冲区之后缓存中的值变成了 %q", v.String())
+	}
+}
+
+// 读取到一半时key被删除并重新写入，reader 仍然读取到原来完整的数据
+func TestGetReaderDeleteMidRead(t *testing.T) {
+	opt := DefaultCacheOptions()
+	c := NewCache(&opt)
+	defer c.Close()
+	ctx := context.Background()
+	data := bytes.Repeat([]byte("abcdefgh"), 10000)
+	c.AddBytes("k", data)
+
+	r, _, ok := c.GetReader(ctx, "k")
+	if !ok {
+		t.Fatal("应该命中")
+	}
+	head := make([]byte, 100)
+	if _, err := io.ReadFull(r, head); err != nil {
+		t.Fatal(err)
+	}
+	c.Delete("k")
+	c.AddBytes("k", []byte("new"))
+	rest, err := io.ReadAll(r)
+	if err != nil || !bytes.Equal(append(head, rest...), data) {
+		t.Fatalf("删除之后 reader 读取到的数据被改变了: %v", err)
+	}
+}
@@ -2,8 +2,14 @@ package main
 
 import (
 	"Distributed-Cache-Go/lru"
+	"Distributed-Cache-Go/metrics"
+	"Distributed-Cache-Go/singleflight"
 	"context"
+	"errors"
+	"fmt"
 	"go.uber.org/zap"
+	"io"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -18,65 +24,393 @@ type Cache struct {
 	// 状态属性（运行时状态跟踪），用于记录和管理缓存实例的运行状态
 	initialized int32 // 原子变量，标记缓存是否已初始化
 	closed      int32 // 原子变量，标记缓存是否已关闭
+	draining    int32 // 原子变量，标记缓存是否正在 Drain，Drain 开始后不再接受新的写入
+	// 写操作在执行期间持有读锁，Drain 通过获取写锁等待所有正在执行的写操作完成
+	writeMu sync.RWMutex
 	// 统计属性，用于记录缓存的使用情况
 	hits   int64 // 缓存命中次数
 	misses int64 // 缓存未命中次数
 	log    *zap.Logger
+	// 合并同一个 key 的并发加载，防止缓存击穿
+	loadGroup singleflight.Group
+	// 分布式节点选择，为空时只从本地加载
+	peers PeerPicker
+	group string // 所属 Group 的名称，请求远程节点时使用
+	// 按 key 分段的写锁，同一个 key 的写入（包括持久化）以及 Increment/Decrement 的读取、修改、写回串行执行
+	keyLocks *lru.KeyLocks
+	// 限制并发执行的 loader 数量的信号量，没有配置 MaxConcurrentLoads 时为空
+	loadSem chan struct{}
+	// 原子变量，已经使用的容量是否处于 HighWaterMark 之上，保证每次越过阈值只调用一次 OnHighWater
+	aboveHighWater int32
+	// Transaction 提交期间产生的淘汰回调，提交完成、释放所有锁之后再调用 OnEvicted，由 evictMu 保护
+	evictMu          sync.Mutex
+	evictHolds       int // 正在提交的 Transaction 数量，大于 0 时 OnEvicted 先放入 pendingEvictions
+	pendingEvictions []pendingEviction
 }
+
+// LoaderFunc 缓存未命中时用于从数据源加载数据的函数
+type LoaderFunc func(ctx context.Context, key string) ([]byte, error)
+
+// Get 使 LoaderFunc 实现 Getter 接口
+func (f LoaderFunc) Get(ctx context.Context, key string) ([]byte, error) {
+	return f(ctx, key)
+}
+
+// Getter 数据源接口，缓存未命中时由 Load 调用，把数据回填到缓存中
+type Getter interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+}
+
+var (
+	// ErrNoGetter 调用 Load 时没有配置 Getter
+	ErrNoGetter = errors.New("缓存没有配置 Getter")
+	// ErrCacheClosed 缓存已经关闭或者正在 Drain
+	ErrCacheClosed = errors.New("缓存已经关闭")
+	// ErrTypeMismatch 缓存中存储的值不是 ByteView
+	ErrTypeMismatch = errors.New("缓存中的值类型错误")
+	// ErrCacheMiss 缓存未命中
+	ErrCacheMiss = errors.New("缓存未命中")
+	// ErrLoadThrottled 正在执行的 loader 数量已经达到 MaxConcurrentLoads，并且配置了立即失败
+	ErrLoadThrottled = errors.New("并发加载数量超过限制")
+	// ErrPeersUnavailable 负责该key的远程节点全部不可用，并且关闭了本地兜底
+	ErrPeersUnavailable = errors.New("远程节点全部不可用")
+	// ErrLoaderPanic loader 发生了 panic，错误信息中带有 panic 的值
+	ErrLoaderPanic = errors.New("loader 发生 panic")
+)
+
 type CacheOptions struct {
 	CacheType       lru.CacheType
 	MaxBytes        int64
-	OnEvicted       func(key string, value lru.Value)
+	MaxEntries      int64
+	MaxValueBytes   int64
+	OnEvicted       func(key string, value lru.Value, reason lru.EvictReason) // 在底层存储的写锁内同步调用，不能再调用该缓存的方法（Transaction 提交期间的回调除外）
 	CleanupInterval time.Duration
+	DefaultTTL      time.Duration
+	TTLJitter       time.Duration
+	EvictionSamples int
+	PromotionBuffer int
+	Logger          *zap.Logger
+	ShardCount      int
+	Getter          Getter    // 缓存未命中时用于回源加载数据，Load 方法使用
+	Persister       Persister // 持久化，写入会同步到 Persister，创建缓存时从中恢复数据
+
+	// 压缩：大于 CompressThreshold 字节的值压缩后再存储，0 表示不压缩；Compressor 为空时使用 gzip
+	CompressThreshold int
+	Compressor        Compressor
+
+	// 副本数，大于 1 并且注册的 PeerPicker 实现了 ReplicaPicker 时，Set 会写入哈希环上的前 ReplicationFactor 个节点，
+	// 从远程节点加载数据时主节点不可用会依次尝试其余副本
+	ReplicationFactor int
+
+	// 是否统计热点key，只对 LRU 和 Sharded 类型有效，含义见 lru.Options
+	TrackHotKeys bool
+
+	// 是否开启滑动过期，只对 LRU 和 Sharded 类型有效，含义见 lru.Options
+	SlidingExpiration bool
+
+	// TwoQueue 类型的参数，含义见 lru.Options
+	TwoQueueRecentRatio float64
+	TwoQueueGhostRatio  float64
+
+	// Sharded 类型选择分片使用的哈希算法以及是否错开各个分片的清理时间，含义见 lru.Options
+	ShardHash      lru.ShardHash
+	CleanupStagger bool
+
+	// 不启动底层存储的后台清理协程，含义见 lru.Options
+	DisableBackgroundCleanup bool
+
+	// 底层存储淘汰日志的汇总间隔，为 0 时默认为 10 秒，小于 0 时不输出，含义见 lru.Options
+	EvictionLogInterval time.Duration
+
+	// 自定义每个键值对占用的容量，为空时使用 len(key)+value.Len()，含义见 lru.Options
+	// 存储层看到的 value 是压缩之后的值，配置了 KeyPrefix 时 key 带有前缀，负缓存的 value 不是 ByteView
+	Weigher func(key string, value lru.Value) int64
+
+	// 底层存储每次获取写锁最多淘汰的条目数，0 表示不限制，只对 LRU 和 Sharded 类型有效，含义见 lru.Options
+	EvictionBatchSize int
+
+	// key 的命名空间前缀，多个模块共享同一个 Persister 时用来避免key冲突
+	// 不为空时写入底层存储和 Persister 的key都会自动加上前缀，读取时去掉，DumpKeys、快照和 OnEvicted 看到的都是不带前缀的逻辑key
+	KeyPrefix string
+
+	// 底层存储判断过期使用的时间来源，为空时使用系统时间，含义见 lru.Options
+	Clock lru.Clock
+
+	// 异步持久化：WriteBehindInterval 大于 0 时 Persister 会被包装成 WriteBehindPersister，按该间隔批量写入，
+	// 脏数据达到 WriteBehindBatchSize 时立即刷新，Close 时会做最后一次刷新
+	WriteBehindInterval  time.Duration
+	WriteBehindBatchSize int
+
+	// 负缓存：大于 0 时 loader/Getter 返回 ErrNotFound 的key会被记住 NegativeTTL 这么长时间，期间不会再次调用 loader
+	NegativeTTL time.Duration
+
+	// 序列化方式，TypedCache 和 Group.GetInto 使用，为空时使用 GobCodec
+	Codec Codec
+
+	// Get/GetE 命中和未命中时的回调，在不持有任何锁的情况下调用，回调中可以再次访问缓存
+	OnHit  func(key string)
+	OnMiss func(key string)
+
+	// 容量预警：已经使用的容量从低于 HighWaterMark*MaxBytes 变为不低于时调用一次 OnHighWater，降回阈值以下之后才会再次触发
+	// HighWaterMark 取值 (0, 1]，默认为 0.9；MaxBytes 为 0 时不检查。在 Add/Set/SetMulti/Delete 之后、不持有任何锁的情况下检查和调用
+	OnHighWater   func(currentBytes, maxBytes int64)
+	HighWaterMark float64
+
+	// 按 key 分段的写锁的段数，0 表示使用默认值 256，不是 2 的幂时向上取整
+	KeyLockStripes int
+
+	// 限制同时执行的 loader/Getter 数量以保护数据源，0 表示不限制；同一个key的并发加载只算一次
+	// 达到上限时默认等待空位（可以被 context 取消），LoadFailFast 为 true 时直接返回 ErrLoadThrottled
+	MaxConcurrentLoads int
+	LoadFailFast       bool
+
+	// 负责该key的远程节点（以及副本节点）全部不可用时，默认在本地调用 loader/Getter 加载，以一致性换取分区期间的可用性
+	// 设置为 true 时关闭本地兜底，直接返回 ErrPeersUnavailable，保证只有负责该key的节点访问数据源；远程节点明确返回未命中时仍然在本地加载
+	DisableLocalFallback bool
 }
 
+// storeOptions 转换成底层存储使用的 lru.Options，两边的字段名和含义保持一致
+func (o *CacheOptions) storeOptions() *lru.Options {
+	return &lru.Options{
+		CleanupInterval:     o.CleanupInterval,
+		DefaultTTL:          o.DefaultTTL,
+		TTLJitter:           o.TTLJitter,
+		EvictionSamples:     o.EvictionSamples,
+		PromotionBuffer:     o.PromotionBuffer,
+		SlidingExpiration:   o.SlidingExpiration,
+		MaxBytes:            o.MaxBytes,
+		MaxEntries:          o.MaxEntries,
+		MaxValueBytes:       o.MaxValueBytes,
+		OnEvicted:           o.onEvicted(),
+		Logger:              o.Logger,
+		ShardCount:          o.ShardCount,
+		ShardHash:           o.ShardHash,
+		Clock:               o.Clock,
+		CleanupStagger:      o.CleanupStagger,
+		TwoQueueRecentRatio: o.TwoQueueRecentRatio,
+		TwoQueueGhostRatio:  o.TwoQueueGhostRatio,
+		TrackHotKeys:        o.TrackHotKeys,
+
+		DisableBackgroundCleanup: o.DisableBackgroundCleanup,
+		EvictionLogInterval:      o.EvictionLogInterval,
+		Weigher:                  o.Weigher,
+		EvictionBatchSize:        o.EvictionBatchSize,
+	}
+}
+
+// 返回存储层使用的淘汰回调，配置了 KeyPrefix 时先去掉前缀再调用 OnEvicted
+func (o *CacheOptions) onEvicted() func(key string, value lru.Value, reason lru.EvictReason) {
+	fn, prefix := o.OnEvicted, o.KeyPrefix
+	if fn == nil || prefix == "" {
+		return fn
+	}
+	return func(key string, value lru.Value, reason lru.EvictReason) {
+		fn(strings.TrimPrefix(key, prefix), value, reason)
+	}
+}
+
+// CacheStats 缓存的统计信息，与底层存储使用同一个类型
+type CacheStats = lru.CacheStats
+
 func DefaultCacheOptions() CacheOptions {
 	return CacheOptions{
 		CacheType:       lru.LRU,
 		MaxBytes:        8 * 1024 * 1024, // 8MB
 		CleanupInterval: time.Minute,
 		OnEvicted:       nil,
+		Logger:          zap.NewNop(),
 	}
 }
 func NewCache(opt *CacheOptions) *Cache {
 	cache := &Cache{
 		cacheOptions: *opt,
+		keyLocks:     lru.NewKeyLocks(opt.KeyLockStripes),
+	}
+	// 未配置日志时使用空日志，避免空指针
+	if cache.cacheOptions.Logger == nil {
+		cache.cacheOptions.Logger = zap.NewNop()
+	}
+	cache.log = cache.cacheOptions.Logger
+	if cache.cacheOptions.MaxConcurrentLoads > 0 {
+		cache.loadSem = make(chan struct{}, cache.cacheOptions.MaxConcurrentLoads)
+	}
+	if cache.cacheOptions.Persister != nil && cache.cacheOptions.WriteBehindInterval > 0 {
+		cache.cacheOptions.Persister = NewWriteBehindPersister(cache.cacheOptions.Persister,
+			cache.cacheOptions.WriteBehindInterval, cache.cacheOptions.WriteBehindBatchSize, cache.log)
+	}
+	// 配置了持久化时，从 Persister 中恢复之前的数据
+	if cache.cacheOptions.Persister != nil {
+		cache.restore()
 	}
 	return cache
 }
 
+// 从 Persister 中恢复数据，恢复的数据不会再次写入 Persister
+func (c *Cache) restore() {
+	data, err := c.cacheOptions.Persister.Load()
+	if err != nil {
+		c.log.Error("从持久化数据中恢复缓存失败", zap.Error(err))
+		return
+	}
+	c.ensureInitialized()
+	prefix := c.cacheOptions.KeyPrefix
+	count := 0
+	for key, value := range data {
+		// 共享 Persister 时只恢复属于当前命名空间的数据
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		key = key[len(prefix):]
+		err := c.store.AddAndUpdateCache(key, c.encodeValue(key, ByteView{b: value}))
+		if err != nil {
+			c.log.Error("恢复缓存数据失败", zap.String("key", key), zap.Error(err))
+			continue
+		}
+		count++
+	}
+	c.log.Info("从持久化数据中恢复缓存完成", zap.Int("count", count))
+}
+
+// 将写入同步到 Persister，value 为 nil 表示删除
+func (c *Cache) persist(key string, value []byte) {
+	if c.cacheOptions.Persister == nil {
+		return
+	}
+	err := c.cacheOptions.Persister.Save(c.cacheOptions.KeyPrefix+key, value)
+	if err != nil {
+		c.log.Error("缓存持久化失败", zap.String("key", key), zap.Error(err))
+	}
+}
+
 // 延迟初始化的函数
 func (c *Cache) ensureInitialized() {
 	// 首先判断一下当前实例是否已经被初始化了，如果已经被初始化了，那么就直接返回
 	if atomic.LoadInt32(&c.initialized) == 1 {
 		return
 	}
+	// 加写锁后再次检查，避免并发初始化，同时保证不会与 Close 交错
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if atomic.LoadInt32(&c.initialized) == 1 {
+		return
+	}
 	// 如果当前实例没有被初始化，那么就进行延迟初始化
-	Options := &lru.Options{
-		CleanupInterval: c.cacheOptions.CleanupInterval,
-		MaxBytes:        c.cacheOptions.MaxBytes,
-		OnEvicted:       c.cacheOptions.OnEvicted,
+	Options := c.cacheOptions.storeOptions()
+	if Options.OnEvicted != nil {
+		Options.OnEvicted = c.deferrableOnEvicted(Options.OnEvicted)
 	}
 	cache := lru.NewStore(c.cacheOptions.CacheType, Options)
-	// 将初始化后的缓存实例赋值给当前实例的 store 属性
+	// 将初始化后的缓存实例赋值给当前实例的 store 属性，配置了前缀时包装一层自动加上前缀
 	c.store = cache
+	if c.cacheOptions.KeyPrefix != "" {
+		c.store = &prefixStore{store: cache, prefix: c.cacheOptions.KeyPrefix}
+	}
 	// 将状态修改为 初始化完成
-	atomic.AddInt32(&c.initialized, 1)
+	atomic.StoreInt32(&c.initialized, 1)
 	c.log.Info("缓存实例初始化完成")
 }
 
-// 增加或者更新
-func (c *Cache) Add(key string, value ByteView) {
+// 默认的容量预警阈值
+const defaultHighWaterMark = 0.9
+
+// checkHighWater 检查已经使用的容量是否越过了 HighWaterMark，从下往上越过时调用 OnHighWater，调用方不能持有任何锁
+func (c *Cache) checkHighWater() {
+	fn := c.cacheOptions.OnHighWater
+	maxBytes := c.cacheOptions.MaxBytes
+	if fn == nil || maxBytes <= 0 || atomic.LoadInt32(&c.initialized) == 0 {
+		return
+	}
+	mark := c.cacheOptions.HighWaterMark
+	if mark <= 0 || mark > 1 {
+		mark = defaultHighWaterMark
+	}
+	current := c.store.Bytes()
+	if float64(current) < mark*float64(maxBytes) {
+		atomic.StoreInt32(&c.aboveHighWater, 0)
+		return
+	}
+	if atomic.CompareAndSwapInt32(&c.aboveHighWater, 0, 1) {
+		fn(current, maxBytes)
+	}
+}
+
+// beginWrite 开始一次写操作，缓存正在 Drain 时返回 false，返回 true 时调用方必须在写入完成后调用 endWrite
+// 写操作之间不能嵌套调用 beginWrite，否则 Drain 等待期间会死锁
+func (c *Cache) beginWrite() bool {
+	c.writeMu.RLock()
+	if atomic.LoadInt32(&c.draining) == 1 {
+		c.writeMu.RUnlock()
+		return false
+	}
+	return true
+}
+
+func (c *Cache) endWrite() {
+	c.writeMu.RUnlock()
+}
+
+// 增加或者更新，缓存已经关闭或者正在 Drain 时返回 ErrCacheClosed，
+// 值超过 MaxValueBytes（或者更新时超过 MaxBytes）时返回包装了 lru.ErrValueTooLarge 的错误
+func (c *Cache) Add(key string, value ByteView) error {
+	if !c.beginWrite() {
+		return ErrCacheClosed
+	}
+	// defer 按相反的顺序执行，容量检查在 endWrite 之后进行
+	defer c.checkHighWater()
+	defer c.endWrite()
+	return c.add(key, value)
+}
+
+// add 写入本地缓存并持久化，不检查 Drain 状态，由已经调用过 beginWrite 的方法使用
+// 写入和持久化在同一个 key 锁内完成，保证同一个 key 持久化的顺序与写入缓存的顺序一致
+func (c *Cache) add(key string, value ByteView) error {
+	if atomic.LoadInt32(&c.closed) == 1 {
+		return ErrCacheClosed
+	}
+	c.keyLocks.Lock(key)
+	defer c.keyLocks.Unlock(key)
 	// 首先判断一下是否已经进行了初始化
 	if atomic.LoadInt32(&c.initialized) == 0 {
 		// 执行延迟初始化
 		c.ensureInitialized()
 	}
-	err := c.store.AddAndUpdateCache(key, value)
+	err := c.store.AddAndUpdateCache(key, c.encodeValue(key, value))
 	if err != nil {
 		c.log.Error("缓存增加或者更新失败", zap.Error(err))
+		return fmt.Errorf("缓存增加或者更新失败:%w", err)
 	}
+	c.persist(key, value.ByteSlice())
+	return nil
+}
 
+// Set 写入数据，开启副本时同时写入负责该 key 的主节点和副本节点，只有当前节点也是其中之一时才会写入本地
+// 没有开启副本时等同于 Add；写入本地或者远程节点失败时返回最后一个错误，其余节点仍然会被写入
+func (c *Cache) Set(ctx context.Context, key string, value ByteView) error {
+	if !c.beginWrite() {
+		return ErrCacheClosed
+	}
+	defer c.checkHighWater()
+	defer c.endWrite()
+	picker, ok := c.peers.(ReplicaPicker)
+	if !ok || c.cacheOptions.ReplicationFactor <= 1 {
+		return c.add(key, value)
+	}
+	peers, self := picker.PickReplicas(key, c.cacheOptions.ReplicationFactor)
+	var lastErr error
+	if self {
+		lastErr = c.add(key, value)
+	}
+	for _, peer := range peers {
+		setter, ok := peer.(PeerSetter)
+		if !ok {
+			continue
+		}
+		if err := setter.Set(ctx, c.group, key, value.ByteSlice()); err != nil {
+			c.log.Warn("写入副本节点失败", zap.String("key", key), zap.Error(err))
+			lastErr = err
+		}
+	}
+	return lastErr
 }
 
 // 删除
@@ -84,23 +418,58 @@ func (c *Cache) Delete(key string) {
 	if atomic.LoadInt32(&c.closed) == 1 || atomic.LoadInt32(&c.initialized) == 0 {
 		return
 	}
+	if !c.beginWrite() {
+		return
+	}
+	defer c.checkHighWater()
+	defer c.endWrite()
+	c.keyLocks.Lock(key)
+	defer c.keyLocks.Unlock(key)
 
 	err := c.store.DeleteCache(key)
 	if err != nil {
 		c.log.Error("缓存删除失败", zap.Error(err))
+		return
 	}
+	c.persist(key, nil)
 }
 
 // 查找
 func (c *Cache) Get(ctx context.Context, key string) (value ByteView, ok bool) {
+	value, err := c.GetE(ctx, key)
+	return value, err == nil
+}
+
+// GetE 与 Get 相同，但通过错误区分未命中的原因：
+// 缓存已关闭返回 ErrCacheClosed，存储的值不是 ByteView 返回 ErrTypeMismatch，命中负缓存返回 ErrNotFound，其余情况返回 ErrCacheMiss
+// 压缩过的值解压失败时返回解压的错误
+// 配置了 OnHit/OnMiss 时在释放锁之后调用，缓存已关闭时不会调用
+func (c *Cache) GetE(ctx context.Context, key string) (ByteView, error) {
+	value, err := c.get(key)
+	switch {
+	case err == nil:
+		if c.cacheOptions.OnHit != nil {
+			c.cacheOptions.OnHit(key)
+		}
+	case err != ErrCacheClosed:
+		if c.cacheOptions.OnMiss != nil {
+			c.cacheOptions.OnMiss(key)
+		}
+	}
+	return value, err
+}
+
+// get 查找本地缓存并更新命中统计，GetE 的实现
+func (c *Cache) get(key string) (ByteView, error) {
 	if atomic.LoadInt32(&c.closed) == 1 {
-		return ByteView{}, false
+		return ByteView{}, ErrCacheClosed
 	}
 
 	// 如果缓存未初始化，直接返回未命中
 	if atomic.LoadInt32(&c.initialized) == 0 {
 		atomic.AddInt64(&c.misses, 1)
-		return ByteView{}, false
+		metrics.Misses.Inc()
+		return ByteView{}, ErrCacheMiss
 	}
 
 	c.mu.RLock()
@@ -110,18 +479,421 @@ func (c *Cache) Get(ctx context.Context, key string) (value ByteView, ok bool) {
 	val, found := c.store.FindCache(key)
 	if !found {
 		atomic.AddInt64(&c.misses, 1)
-		return ByteView{}, false
+		metrics.Misses.Inc()
+		return ByteView{}, ErrCacheMiss
+	}
+
+	// 转换并返回，压缩过的值会在这里解压
+	bv, err := c.decodeValue(key, val)
+	if err != nil {
+		atomic.AddInt64(&c.misses, 1)
+		metrics.Misses.Inc()
+		return ByteView{}, err
 	}
 
 	// 更新命中计数
 	atomic.AddInt64(&c.hits, 1)
+	metrics.Hits.Inc()
+	return bv, nil
+}
+
+// AddBytes 直接写入字节切片，会拷贝一份 b，之后修改 b 不会影响缓存中的值
+func (c *Cache) AddBytes(key string, b []byte) error {
+	return c.Add(key, NewByteView(b))
+}
 
-	// 转换并返回
-	if bv, ok := val.(ByteView); ok {
-		return bv, true
+// AddFrom 调用 write 构造值并写入缓存，write 写入的是池化的临时缓冲区，
+// 与先拼接出一个 []byte 再调用 AddBytes 相比，每次写入少一次临时切片的分配，缓存中保存的仍然是一份拷贝
+func (c *Cache) AddFrom(key string, write func(w io.Writer) error) error {
+	value, err := BuildByteView(write)
+	if err != nil {
+		return err
+	}
+	return c.Add(key, value)
+}
+
+// GetBytes 查找缓存并返回值的拷贝，调用方可以随意修改返回的切片
+func (c *Cache) GetBytes(ctx context.Context, key string) ([]byte, bool) {
+	value, ok := c.Get(ctx, key)
+	if !ok {
+		return nil, false
 	}
+	return value.ByteSlice(), true
+}
 
-	// 类型断言失败
-	atomic.AddInt64(&c.misses, 1)
-	return ByteView{}, false
+// GetReader 查找缓存并返回读取值的 io.Reader 以及值的长度，适合把较大的值直接流式写入 HTTP 响应，不需要先拷贝一份
+// 读取的是查找时的那一份数据，之后该key被更新、删除或者淘汰都不会影响正在进行的读取；对命中统计和 OnHit/OnMiss 的影响与 Get 相同
+func (c *Cache) GetReader(ctx context.Context, key string) (io.Reader, int, bool) {
+	value, ok := c.Get(ctx, key)
+	if !ok {
+		return nil, 0, false
+	}
+	return value.Reader(), value.Len(), true
+}
+
+// ReadInto 查找缓存并把值直接拷贝到调用方提供的 dst 中，避免 ByteSlice 额外的一次分配
+// 返回写入的字节数以及值是否完整写入：未命中时返回 0 和 false；dst 太小时只写入前 len(dst) 个字节并返回 false，
+// 此时可以通过 n == len(dst) 与未命中区分。对命中统计和 OnHit/OnMiss 的影响与 Get 相同
+func (c *Cache) ReadInto(key string, dst []byte) (n int, ok bool) {
+	value, found := c.Get(context.Background(), key)
+	if !found {
+		return 0, false
+	}
+	n = copy(dst, value.b)
+	return n, n == value.Len()
+}
+
+// GetMulti 批量查找，未命中的 key 不会出现在返回结果中，缓存已经关闭时返回 ErrCacheClosed
+// LRU 和 Sharded 类型的整个批次只获取一次存储的锁（Sharded 类型每个分片一次），其余类型逐个查找
+func (c *Cache) GetMulti(ctx context.Context, keys []string) (map[string]ByteView, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if atomic.LoadInt32(&c.closed) == 1 {
+		return nil, ErrCacheClosed
+	}
+	result := make(map[string]ByteView, len(keys))
+	if len(keys) == 0 {
+		return result, nil
+	}
+	if atomic.LoadInt32(&c.initialized) == 0 {
+		atomic.AddInt64(&c.misses, int64(len(keys)))
+		metrics.Misses.Add(float64(len(keys)))
+		return result, nil
+	}
+
+	c.mu.RLock()
+	found := lru.GetMany(c.store, keys)
+	c.mu.RUnlock()
+	for key, val := range found {
+		bv, err := c.decodeValue(key, val)
+		if err != nil {
+			continue
+		}
+		result[key] = bv
+	}
+	hits := len(result)
+	atomic.AddInt64(&c.hits, int64(hits))
+	metrics.Hits.Add(float64(hits))
+	atomic.AddInt64(&c.misses, int64(len(keys)-hits))
+	metrics.Misses.Add(float64(len(keys) - hits))
+	return result, nil
+}
+
+// SetMulti 批量增加或者更新，LRU 和 Sharded 类型的整个批次只获取一次存储的锁（Sharded 类型每个分片一次）
+func (c *Cache) SetMulti(pairs map[string]ByteView) {
+	if len(pairs) == 0 || atomic.LoadInt32(&c.closed) == 1 {
+		return
+	}
+	if !c.beginWrite() {
+		return
+	}
+	defer c.checkHighWater()
+	defer c.endWrite()
+	if atomic.LoadInt32(&c.initialized) == 0 {
+		c.ensureInitialized()
+	}
+	keys := make([]string, 0, len(pairs))
+	items := make(map[string]lru.Value, len(pairs))
+	for key, value := range pairs {
+		keys = append(keys, key)
+		items[key] = c.encodeValue(key, value)
+	}
+	// 与 Increment 和 Transaction 一样先获取 key 锁再获取读锁，保证加锁顺序一致
+	unlock := c.keyLocks.LockKeys(keys)
+	defer unlock()
+	c.mu.RLock()
+	errs := lru.AddMany(c.store, items)
+	c.mu.RUnlock()
+	for key, value := range pairs {
+		if err, failed := errs[key]; failed {
+			c.log.Error("缓存批量增加或者更新失败", zap.String("key", key), zap.Error(err))
+			continue
+		}
+		c.persist(key, value.ByteSlice())
+	}
+}
+
+// Stats 返回缓存的统计信息，可以与 Get/Add 并发调用
+func (c *Cache) Stats() CacheStats {
+	stats := CacheStats{
+		Hits:   atomic.LoadInt64(&c.hits),
+		Misses: atomic.LoadInt64(&c.misses),
+	}
+	if total := stats.Hits + stats.Misses; total > 0 {
+		stats.HitRatio = float64(stats.Hits) / float64(total)
+	}
+	if atomic.LoadInt32(&c.initialized) == 1 {
+		stats.Entries = c.store.Len()
+		stats.Bytes = c.store.Bytes()
+		if s, ok := c.store.(interface{ Stats() CacheStats }); ok {
+			stats.Expirations = s.Stats().Expirations
+		}
+	}
+	return stats
+}
+
+// ResetStats 将命中和未命中次数以及底层存储的过期删除次数清零，返回清零之前的统计信息，可以与 Get/Add 并发调用
+// 每个计数器分别原子地交换为 0，清零期间并发的访问会被计入清零前或者清零后的其中一边，不会丢失
+func (c *Cache) ResetStats() CacheStats {
+	stats := CacheStats{
+		Hits:   atomic.SwapInt64(&c.hits, 0),
+		Misses: atomic.SwapInt64(&c.misses, 0),
+	}
+	if total := stats.Hits + stats.Misses; total > 0 {
+		stats.HitRatio = float64(stats.Hits) / float64(total)
+	}
+	if atomic.LoadInt32(&c.initialized) == 1 {
+		stats.Entries = c.store.Len()
+		stats.Bytes = c.store.Bytes()
+		if s, ok := c.store.(interface{ ResetStats() CacheStats }); ok {
+			stats.Expirations = s.ResetStats().Expirations
+		}
+	}
+	return stats
+}
+
+// contains 判断本地缓存中是否存在未过期的key，负缓存不算，不影响命中统计和淘汰顺序
+func (c *Cache) contains(key string) bool {
+	if atomic.LoadInt32(&c.initialized) == 0 {
+		return false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	val, ok := c.store.Peek(key)
+	return ok && !isTombstone(val)
+}
+
+// keys 返回底层存储中当前的所有key，可能包含已经过期但还没有被清理的key
+func (c *Cache) keys() []string {
+	if atomic.LoadInt32(&c.initialized) == 0 {
+		return nil
+	}
+	var keys []string
+	c.store.Range(func(key string, _ lru.Value) bool {
+		keys = append(keys, key)
+		return true
+	})
+	return keys
+}
+
+// DumpKeys 返回所有未过期的key及其大小、剩余过期时间和淘汰顺序，只有 LRU 和 Sharded 类型支持，其余类型返回空
+func (c *Cache) DumpKeys() []lru.KeyInfo {
+	if atomic.LoadInt32(&c.initialized) == 0 {
+		return nil
+	}
+	if d, ok := c.store.(interface{ DumpKeys() []lru.KeyInfo }); ok {
+		return d.DumpKeys()
+	}
+	return nil
+}
+
+// Close 关闭缓存，关闭后的缓存不再提供读写，重复调用是安全的
+// 使用异步持久化时会把尚未写入的数据全部刷新到底层 Persister 之后再返回
+func (c *Cache) Close() {
+	if !atomic.CompareAndSwapInt32(&c.closed, 0, 1) {
+		return
+	}
+	// 底层存储的 Close 会等待后台清理协程退出，先释放锁，避免持有锁等待
+	// 注意 OnEvicted 在底层存储的写锁内同步调用，除了 Transaction 提交期间推迟到释放锁之后的回调，都不能再调用该缓存的方法，否则会死锁
+	c.mu.Lock()
+	var store lru.Store
+	if atomic.LoadInt32(&c.initialized) == 1 {
+		store = c.store
+	}
+	c.mu.Unlock()
+	if store != nil {
+		store.Close()
+	}
+	if wb, ok := c.cacheOptions.Persister.(*WriteBehindPersister); ok {
+		wb.Close()
+	}
+}
+
+// Drain 优雅地关闭缓存：先停止接受新的写入，等待正在执行的写操作（包括副本写入）完成，
+// 再把异步持久化中尚未写入的数据全部刷新，最后调用 Close 并等待后台协程退出
+// ctx 在完成之前结束时返回 ctx.Err()，此时缓存仍然拒绝写入但没有关闭，调用方可以重试 Drain 或者直接 Close
+func (c *Cache) Drain(ctx context.Context) error {
+	if atomic.LoadInt32(&c.closed) == 1 {
+		return nil
+	}
+	atomic.StoreInt32(&c.draining, 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		// 获取到写锁说明之前开始的写操作都已经结束，之后的写操作会看到 draining 标记直接返回
+		c.writeMu.Lock()
+		c.writeMu.Unlock()
+		if wb, ok := c.cacheOptions.Persister.(*WriteBehindPersister); ok {
+			wb.Flush()
+		}
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	c.Close()
+	c.log.Info("缓存 Drain 完成")
+	return nil
+}
+
+// GetOrLoad 查找缓存，未命中时调用 loader 加载数据并写入缓存
+// 注册了 PeerPicker 时，由远程节点负责的 key 会先从远程节点获取，获取到的数据不会写入本地缓存
+// ctx 被取消时立即返回 ctx.Err()，但不会中断正在进行的加载，其他等待同一个 key 的调用方仍然可以拿到结果
+// 对同一个 key 的并发未命中只会调用一次 loader，其余调用方共享加载结果；loader 返回错误或者 panic 时不会写入缓存
+// 配置了 NegativeTTL 时，loader 返回 ErrNotFound 的key在 NegativeTTL 之内直接返回 ErrNotFound
+func (c *Cache) GetOrLoad(ctx context.Context, key string, loader LoaderFunc) (ByteView, error) {
+	// context 已经被取消时直接返回
+	if err := ctx.Err(); err != nil {
+		return ByteView{}, err
+	}
+	value, err := c.GetE(ctx, key)
+	if err == nil {
+		return value, nil
+	}
+	if errors.Is(err, ErrNotFound) {
+		return ByteView{}, err
+	}
+	// 在单独的协程中加载，这样 context 被取消时可以立即返回，而不必等待加载完成
+	// 加载是所有等待同一个 key 的调用方共享的，所以使用不会被取消的 context，
+	// 否则第一个调用方取消时其他调用方也会一起失败；加载的结果仍然会写入缓存
+	loadCtx := context.WithoutCancel(ctx)
+	type result struct {
+		value ByteView
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		value, err := c.load(loadCtx, key, loader)
+		done <- result{value: value, err: err}
+	}()
+	select {
+	case <-ctx.Done():
+		return ByteView{}, ctx.Err()
+	case r := <-done:
+		return r.value, r.err
+	}
+}
+
+// load 通过 singleflight 加载数据，对同一个 key 的并发调用只会真正加载一次
+func (c *Cache) load(ctx context.Context, key string, loader LoaderFunc) (ByteView, error) {
+	v, err, _ := c.loadGroup.Do(key, func() (interface{}, error) {
+		// 如果 key 由远程节点负责，优先从远程节点获取，失败时再从本地加载
+		peers := c.pickPeers(key)
+		var lastErr error
+		unreachable := len(peers) > 0
+		for _, peer := range peers {
+			b, err := peer.Get(ctx, c.group, key)
+			if err == nil {
+				return NewByteView(b), nil
+			}
+			c.log.Warn("从远程节点获取数据失败", zap.String("key", key), zap.Error(err))
+			if errors.Is(err, ErrPeerNotFound) {
+				unreachable = false
+			}
+			lastErr = err
+		}
+		if unreachable {
+			if c.cacheOptions.DisableLocalFallback {
+				return nil, fmt.Errorf("%w: %v", ErrPeersUnavailable, lastErr)
+			}
+			c.log.Warn("远程节点全部不可用，在本地加载", zap.String("key", key))
+		}
+		b, err := c.callLoader(ctx, key, loader)
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				c.addNegative(key)
+			}
+			return nil, err
+		}
+		value := NewByteView(b)
+		c.Add(key, value)
+		return value, nil
+	})
+	if err != nil {
+		return ByteView{}, err
+	}
+	return v.(ByteView), nil
+}
+
+// callLoader 占用一个加载名额后调用 loader，loader 发生 panic 时转换成错误返回
+// 加载在 GetOrLoad 启动的协程中执行，panic 不会被 net/http 等调用方恢复，不处理的话会导致整个进程退出
+func (c *Cache) callLoader(ctx context.Context, key string, loader LoaderFunc) (b []byte, err error) {
+	if err := c.acquireLoad(ctx); err != nil {
+		return nil, err
+	}
+	defer c.releaseLoad()
+	defer func() {
+		if r := recover(); r != nil {
+			c.log.Error("loader 发生 panic", zap.String("key", key), zap.Any("panic", r), zap.Stack("stack"))
+			b, err = nil, fmt.Errorf("%w: %v", ErrLoaderPanic, r)
+		}
+	}()
+	return loader(ctx, key)
+}
+
+// acquireLoad 获取一个执行 loader 的名额，没有空位时根据 LoadFailFast 等待或者返回 ErrLoadThrottled
+func (c *Cache) acquireLoad(ctx context.Context) error {
+	if c.loadSem == nil {
+		return nil
+	}
+	select {
+	case c.loadSem <- struct{}{}:
+		return nil
+	default:
+	}
+	if c.cacheOptions.LoadFailFast {
+		return ErrLoadThrottled
+	}
+	select {
+	case c.loadSem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// releaseLoad 归还 acquireLoad 获取的名额
+func (c *Cache) releaseLoad() {
+	if c.loadSem != nil {
+		<-c.loadSem
+	}
+}
+
+// 返回应该从哪些远程节点获取 key，按优先级排列，当前节点负责该 key 时返回空
+// 开启副本时依次返回主节点和副本节点，当前节点本身就是副本之一时直接从本地加载
+func (c *Cache) pickPeers(key string) []PeerGetter {
+	if c.peers == nil {
+		return nil
+	}
+	if picker, ok := c.peers.(ReplicaPicker); ok && c.cacheOptions.ReplicationFactor > 1 {
+		peers, self := picker.PickReplicas(key, c.cacheOptions.ReplicationFactor)
+		if self {
+			return nil
+		}
+		return peers
+	}
+	if peer, ok := c.peers.PickPeer(key); ok {
+		return []PeerGetter{peer}
+	}
+	return nil
+}
+
+// Load 查找缓存，未命中时调用 CacheOptions.Getter 加载数据并回填到缓存
+// Getter 返回的错误会直接返回给调用方，并且不会写入缓存
+func (c *Cache) Load(ctx context.Context, key string) (ByteView, error) {
+	getter := c.cacheOptions.Getter
+	if getter == nil {
+		return ByteView{}, ErrNoGetter
+	}
+	return c.GetOrLoad(ctx, key, getter.Get)
+}
+
+// RegisterPeers 注册用于分布式查找的 PeerPicker，只能注册一次
+func (c *Cache) RegisterPeers(peers PeerPicker) {
+	if c.peers != nil {
+		panic("RegisterPeers 被调用了多次")
+	}
+	c.peers = peers
 }
@@ -0,0 +1,794 @@
+package main
+
+import (
+	"Distributed-Cache-Go/lru"
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 长度为 1 的非 ByteView 值，用来模拟底层存储中类型错误的数据
+type foreignValue struct{}
+
+func (foreignValue) Len() int {
+	return 1
+}
+
+// GetE 通过不同的错误区分未命中的原因，Get 在这些情况下都只返回 false
+func TestGetE(t *testing.T) {
+	opt := DefaultCacheOptions()
+	c := NewCache(&opt)
+	ctx := context.Background()
+	if _, err := c.GetE(ctx, "a"); !errors.Is(err, ErrCacheMiss) {
+		t.Fatalf("缓存还没有初始化时应该返回 ErrCacheMiss，实际为 %v", err)
+	}
+	c.AddBytes("a", []byte("1"))
+	c.store.AddAndUpdateCache("foreign", foreignValue{})
+
+	tests := []struct {
+		name    string
+		key     string
+		want    string
+		wantErr error
+	}{
+		{"命中", "a", "1", nil},
+		{"未命中", "none", "", ErrCacheMiss},
+		{"类型错误", "foreign", "", ErrTypeMismatch},
+	}
+	for _, tt := range tests {
+		v, err := c.GetE(ctx, tt.key)
+		if !errors.Is(err, tt.wantErr) || v.String() != tt.want {
+			t.Fatalf("%s: GetE(%q) = %q, %v", tt.name, tt.key, v.String(), err)
+		}
+		if _, ok := c.Get(ctx, tt.key); ok != (tt.wantErr == nil) {
+			t.Fatalf("%s: Get(%q) 返回 %v", tt.name, tt.key, ok)
+		}
+	}
+	c.Close()
+	if _, err := c.GetE(ctx, "a"); !errors.Is(err, ErrCacheClosed) {
+		t.Fatalf("缓存关闭之后应该返回 ErrCacheClosed，实际为 %v", err)
+	}
+}
+
+// 100 个协程同时加载同一个未命中的key，loader 只会执行一次，所有调用方都拿到同一个结果
+func TestGetOrLoadConcurrentLoadsOnce(t *testing.T) {
+	opt := DefaultCacheOptions()
+	c := NewCache(&opt)
+	defer c.Close()
+	var calls int32
+	loader := func(ctx context.Context, key string) ([]byte, error) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(50 * time.Millisecond)
+		return []byte("v"), nil
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err := c.GetOrLoad(context.Background(), "k", loader); err != nil || v.String() != "v" {
+				t.Errorf("GetOrLoad 返回 %q %v", v.String(), err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatalf("loader 应该只执行一次，实际执行了 %d 次", n)
+	}
+}
+
+// Load 未命中时调用 Getter 并回填缓存，之后的查找不再调用 Getter；Getter 返回的错误直接返回且不会写入缓存
+func TestLoadWithGetter(t *testing.T) {
+	calls := map[string]int{}
+	opt := DefaultCacheOptions()
+	opt.Getter = LoaderFunc(func(ctx context.Context, key string) ([]byte, error) {
+		calls[key]++
+		if key == "bad" {
+			return nil, errors.New("数据源不可用")
+		}
+		return []byte(key + "!"), nil
+	})
+	c := NewCache(&opt)
+	defer c.Close()
+	ctx := context.Background()
+
+	tests := []struct {
+		key       string
+		want      string
+		wantErr   bool
+		wantCalls int
+	}{
+		{"a", "a!", false, 1},
+		{"a", "a!", false, 1},
+		{"bad", "", true, 1},
+		{"bad", "", true, 2},
+	}
+	for _, tt := range tests {
+		v, err := c.Load(ctx, tt.key)
+		if (err != nil) != tt.wantErr || v.String() != tt.want || calls[tt.key] != tt.wantCalls {
+			t.Fatalf("Load(%q) = %q, %v，Getter 调用了 %d 次", tt.key, v.String(), err, calls[tt.key])
+		}
+	}
+	if _, ok := c.Get(ctx, "a"); !ok {
+		t.Fatal("加载到的值应该被写入缓存")
+	}
+	if _, ok := c.Get(ctx, "bad"); ok {
+		t.Fatal("加载失败的key不应该被写入缓存")
+	}
+
+	noGetter := DefaultCacheOptions()
+	c2 := NewCache(&noGetter)
+	defer c2.Close()
+	if _, err := c2.Load(ctx, "a"); !errors.Is(err, ErrNoGetter) {
+		t.Fatalf("没有配置 Getter 时应该返回 ErrNoGetter，实际为 %v", err)
+	}
+}
+
+// 第一个调用方取消时，共享同一次加载的其他调用方仍然能拿到结果
+func TestGetOrLoadCancelDoesNotAffectWaiters(t *testing.T) {
+	opt := DefaultCacheOptions()
+	c := NewCache(&opt)
+	defer c.Close()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	loader := func(ctx context.Context, key string) ([]byte, error) {
+		close(started)
+		<-release
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return []byte("v"), nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := c.GetOrLoad(ctx, "k", loader)
+		first <- err
+	}()
+	<-started
+	second := make(chan error, 1)
+	go func() {
+		v, err := c.GetOrLoad(context.Background(), "k", loader)
+		if err == nil && v.String() != "v" {
+			err = errors.New("读取到的值错误: " + v.String())
+		}
+		second <- err
+	}()
+	// 等待第二个调用方进入 singleflight
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	if err := <-first; !errors.Is(err, context.Canceled) {
+		t.Fatalf("第一个调用方应该返回 context.Canceled，实际为 %v", err)
+	}
+	close(release)
+	if err := <-second; err != nil {
+		t.Fatalf("第二个调用方不应该受到影响: %v", err)
+	}
+	if v, ok := c.Get(context.Background(), "k"); !ok || v.String() != "v" {
+		t.Fatalf("加载的结果应该写入缓存")
+	}
+}
+
+// loader 发生 panic 时返回 ErrLoaderPanic，而不是导致进程退出
+func TestGetOrLoadLoaderPanic(t *testing.T) {
+	opt := DefaultCacheOptions()
+	c := NewCache(&opt)
+	defer c.Close()
+	_, err := c.GetOrLoad(context.Background(), "k", func(ctx context.Context, key string) ([]byte, error) {
+		panic("boom")
+	})
+	if !errors.Is(err, ErrLoaderPanic) {
+		t.Fatalf("应该返回 ErrLoaderPanic，实际为 %v", err)
+	}
+	if _, ok := c.Get(context.Background(), "k"); ok {
+		t.Fatalf("panic 时不应该写入缓存")
+	}
+	// panic 之后同一个 key 可以重新加载
+	v, err := c.GetOrLoad(context.Background(), "k", func(ctx context.Context, key string) ([]byte, error) {
+		return []byte("v"), nil
+	})
+	if err != nil || v.String() != "v" {
+		t.Fatalf("重新加载失败: %v", err)
+	}
+}
+
+func TestGetMultiSetMulti(t *testing.T) {
+	for _, typ := range []lru.CacheType{lru.LRU, lru.Sharded, lru.FIFO} {
+		opt := DefaultCacheOptions()
+		opt.CacheType = typ
+		opt.KeyPrefix = "p:"
+		c := NewCache(&opt)
+		c.SetMulti(map[string]ByteView{"a": NewByteView([]byte("1")), "b": NewByteView([]byte("2"))})
+		got, err := c.GetMulti(context.Background(), []string{"a", "b", "c"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 2 || got["a"].String() != "1" || got["b"].String() != "2" {
+			t.Fatalf("%s: GetMulti 的结果为 %v", typ, got)
+		}
+		if stats := c.Stats(); stats.Hits != 2 || stats.Misses != 1 {
+			t.Fatalf("%s: 命中统计错误: %+v", typ, stats)
+		}
+		c.Close()
+		if _, err := c.GetMulti(context.Background(), []string{"a"}); !errors.Is(err, ErrCacheClosed) {
+			t.Fatalf("%s: 缓存关闭之后应该返回 ErrCacheClosed，实际为 %v", typ, err)
+		}
+	}
+}
+
+// Transaction 提交时淘汰的key，OnEvicted 在释放锁之后调用，回调中读取缓存不会死锁
+func TestTransactionOnEvictedCanReadCache(t *testing.T) {
+	opt := DefaultCacheOptions()
+	opt.MaxEntries = 2
+	var c *Cache
+	evicted := make(chan string, 4)
+	opt.OnEvicted = func(key string, value lru.Value, reason lru.EvictReason) {
+		c.Get(context.Background(), "b")
+		evicted <- key
+	}
+	c = NewCache(&opt)
+	defer c.Close()
+	c.AddBytes("a", []byte("1"))
+	c.AddBytes("b", []byte("2"))
+
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Transaction(func(tx *Tx) error {
+			tx.Set("c", NewByteView([]byte("3")))
+			return nil
+		})
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Transaction 没有返回，OnEvicted 中读取缓存发生了死锁")
+	}
+	if key := <-evicted; key != "a" {
+		t.Fatalf("应该淘汰 a，实际为 %s", key)
+	}
+}
+
+// 按照已知的命中和未命中序列检查 Stats，并发读写时读取统计是安全的
+func TestStats(t *testing.T) {
+	tests := []struct {
+		name    string
+		gets    []string
+		hits    int64
+		misses  int64
+		ratio   float64
+		entries int
+		bytes   int64
+	}{
+		{"没有任何访问", nil, 0, 0, 0, 1, 3},
+		{"三次命中一次未命中", []string{"a", "a", "a", "b"}, 3, 1, 0.75, 1, 3},
+		{"全部未命中", []string{"b", "c"}, 0, 2, 0, 1, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opt := DefaultCacheOptions()
+			c := NewCache(&opt)
+			defer c.Close()
+			c.AddBytes("a", []byte("xx"))
+			for _, key := range tt.gets {
+				c.Get(context.Background(), key)
+			}
+			s := c.Stats()
+			if s.Hits != tt.hits || s.Misses != tt.misses || s.HitRatio != tt.ratio || s.Entries != tt.entries || s.Bytes != tt.bytes {
+				t.Fatalf("Stats() = %+v", s)
+			}
+		})
+	}
+
+	opt := DefaultCacheOptions()
+	c := NewCache(&opt)
+	defer c.Close()
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				key := strconv.Itoa(j % 10)
+				c.AddBytes(key, []byte(key))
+				c.Get(context.Background(), key)
+				c.Stats()
+			}
+		}(i)
+	}
+	wg.Wait()
+	if s := c.Stats(); s.Hits+s.Misses != 4000 {
+		t.Fatalf("并发访问之后命中和未命中之和为 %d，期望 4000", s.Hits+s.Misses)
+	}
+}
+
+func TestResetStats(t *testing.T) {
+	opt := DefaultCacheOptions()
+	clock := lru.NewFakeClock(time.Unix(0, 0))
+	opt.Clock = clock
+	opt.DefaultTTL = time.Second
+	c := NewCache(&opt)
+	defer c.Close()
+	ctx := context.Background()
+	c.AddBytes("a", []byte("1"))
+	c.Get(ctx, "a")
+	clock.Advance(2 * time.Second)
+	c.Get(ctx, "a")
+
+	prev := c.ResetStats()
+	if prev.Hits != 1 || prev.Misses != 1 || prev.Expirations != 1 {
+		t.Fatalf("清零之前的统计错误: %+v", prev)
+	}
+	if s := c.Stats(); s.Hits != 0 || s.Misses != 0 || s.Expirations != 0 {
+		t.Fatalf("清零之后统计应该为 0: %+v", s)
+	}
+	c.AddBytes("b", []byte("2"))
+	c.Get(ctx, "b")
+	c.Get(ctx, "c")
+	if s := c.Stats(); s.Hits != 1 || s.Misses != 1 {
+		t.Fatalf("清零之后应该重新开始累计: %+v", s)
+	}
+}
+
+// 底层存储返回的值与写入的 ByteView 相同
+func TestStoreReturnsByteView(t *testing.T) {
+	s := lru.NewLruCache(&lru.Options{DisableBackgroundCleanup: true})
+	defer s.Close()
+	want := NewByteView([]byte("value"))
+	if err := s.AddAndUpdateCache("k", want); err != nil {
+		t.Fatal(err)
+	}
+	v, ok := s.FindCache("k")
+	if !ok {
+		t.Fatal("应该命中")
+	}
+	if got, ok := v.(ByteView); !ok || got.String() != want.String() {
+		t.Fatalf("FindCache 返回 %#v，期望 %q", v, want.String())
+	}
+}
+
+// Increment 写回时保留原来的绝对过期时间，开启 TTLJitter 时频繁递增的key也会按时过期；非整数的值返回 ErrNotInteger
+func TestIncrementKeepsExpiry(t *testing.T) {
+	for _, ct := range []lru.CacheType{lru.LRU, lru.LFU, lru.FIFO, lru.TwoQueue, lru.Sharded} {
+		t.Run(string(ct), func(t *testing.T) {
+			clock := lru.NewFakeClock(time.Unix(0, 0))
+			opt := DefaultCacheOptions()
+			opt.CacheType = ct
+			opt.DefaultTTL = time.Minute
+			opt.TTLJitter = 5 * time.Second
+			opt.Clock = clock
+			opt.DisableBackgroundCleanup = true
+			c := NewCache(&opt)
+			defer c.Close()
+			if _, err := c.Increment("n", 1); err != nil {
+				t.Fatal(err)
+			}
+			expiry, _ := c.store.TTL("n")
+			for i := 0; i < 10; i++ {
+				clock.Advance(time.Second)
+				if _, err := c.Increment("n", 1); err != nil {
+					t.Fatal(err)
+				}
+				if remaining, ok := c.store.TTL("n"); !ok || remaining != expiry-time.Duration(i+1)*time.Second {
+					t.Fatalf("第 %d 次递增后剩余过期时间为 %v，期望 %v", i+1, remaining, expiry-time.Duration(i+1)*time.Second)
+				}
+			}
+			clock.Advance(expiry)
+			if n, err := c.Increment("n", 1); err != nil || n != 1 {
+				t.Fatalf("过期之后应该重新从 delta 开始计数，实际为 %d %v", n, err)
+			}
+
+			c.AddBytes("s", []byte("abc"))
+			if _, err := c.Increment("s", 1); !errors.Is(err, ErrNotInteger) {
+				t.Fatalf("非整数的值应该返回 ErrNotInteger，实际为 %v", err)
+			}
+		})
+	}
+}
+
+// 多个协程同时修改同一个key和各自的key，同一个key的 Increment 不会丢失更新，使用 go test -race 运行
+func TestKeyLocksHammer(t *testing.T) {
+	opt := DefaultCacheOptions()
+	opt.CacheType = lru.Sharded
+	opt.KeyLockStripes = 16
+	c := NewCache(&opt)
+	defer c.Close()
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				key := strconv.Itoa(g) + "-" + strconv.Itoa(i)
+				c.Increment("hot", 1)
+				c.AddBytes(key, []byte("v"))
+				c.Get(ctx, "hot")
+				c.Delete(key)
+			}
+		}(g)
+	}
+	wg.Wait()
+	v, _ := c.Get(ctx, "hot")
+	if n, _ := strconv.Atoi(v.String()); n != 8*200 {
+		t.Fatalf("hot 应该为 %d，实际为 %s", 8*200, v.String())
+	}
+	if n := c.Stats().Entries; n != 1 {
+		t.Fatalf("应该只剩下 hot，实际有 %d 个条目", n)
+	}
+}
+
+// 保存在内存中的 Persister，用于检查写入了哪些数据
+type memPersister struct {
+	mu   sync.Mutex
+	data map[string][]byte
+}
+
+func newMemPersister() *memPersister {
+	return &memPersister{data: make(map[string][]byte)}
+}
+
+func (p *memPersister) Save(key string, value []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if value == nil {
+		delete(p.data, key)
+	} else {
+		p.data[key] = value
+	}
+	return nil
+}
+
+func (p *memPersister) Load() (map[string][]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	data := make(map[string][]byte, len(p.data))
+	for k, v := range p.data {
+		data[k] = v
+	}
+	return data, nil
+}
+
+// Drain 把异步持久化中尚未写入的数据全部刷新到底层 Persister，之后拒绝写入
+func TestDrainFlushesWriteBehind(t *testing.T) {
+	p := newMemPersister()
+	opt := DefaultCacheOptions()
+	opt.Persister = p
+	opt.WriteBehindInterval = time.Hour
+	opt.WriteBehindBatchSize = 1 << 20
+	c := NewCache(&opt)
+	for i := 0; i < 300; i++ {
+		c.AddBytes(strconv.Itoa(i), []byte("v"))
+	}
+	if data, _ := p.Load(); len(data) != 0 {
+		t.Fatalf("Drain 之前不应该写入底层 Persister，实际写入了 %d 个", len(data))
+	}
+	if err := c.Drain(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := p.Load(); len(data) != 300 {
+		t.Fatalf("Drain 之后底层 Persister 中应该有 300 个key，实际为 %d", len(data))
+	}
+	if err := c.Set(context.Background(), "x", NewByteView(nil)); !errors.Is(err, ErrCacheClosed) {
+		t.Fatalf("Drain 之后写入应该返回 ErrCacheClosed，实际为 %v", err)
+	}
+	if err := c.Drain(context.Background()); err != nil {
+		t.Fatalf("重复调用 Drain 返回 %v", err)
+	}
+}
+
+// 正在执行的写操作没有结束时 ctx 超时，Drain 返回 ctx.Err()，缓存仍然拒绝写入，写操作结束后可以再次 Drain
+func TestDrainTimeout(t *testing.T) {
+	opt := DefaultCacheOptions()
+	c := NewCache(&opt)
+	c.beginWrite()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := c.Drain(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("应该返回 DeadlineExceeded，实际为 %v", err)
+	}
+	c.endWrite()
+	if err := c.Add("late", NewByteView([]byte("x"))); !errors.Is(err, ErrCacheClosed) {
+		t.Fatalf("Drain 期间写入应该返回 ErrCacheClosed，实际为 %v", err)
+	}
+	if err := c.Drain(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 保存写入数据的远程节点，down 为 true 时模拟节点不可用
+type replicaPeer struct {
+	data map[string][]byte
+	down bool
+}
+
+func (p *replicaPeer) Get(ctx context.Context, group string, key string) ([]byte, error) {
+	if p.down {
+		return nil, errors.New("节点不可用")
+	}
+	b, ok := p.data[key]
+	if !ok {
+		return nil, ErrPeerNotFound
+	}
+	return b, nil
+}
+
+func (p *replicaPeer) Set(ctx context.Context, group string, key string, value []byte) error {
+	if p.down {
+		return errors.New("节点不可用")
+	}
+	p.data[key] = value
+	return nil
+}
+
+// 所有key都按照 peers 的顺序分布，当前节点不负责任何key
+type replicaPicker struct {
+	peers []*replicaPeer
+}
+
+func (p *replicaPicker) PickPeer(key string) (PeerGetter, bool) {
+	return p.peers[0], true
+}
+
+func (p *replicaPicker) PickReplicas(key string, n int) ([]PeerGetter, bool) {
+	var peers []PeerGetter
+	for i := 0; i < n && i < len(p.peers); i++ {
+		peers = append(peers, p.peers[i])
+	}
+	return peers, false
+}
+
+// ReplicationFactor 为 2 时写入落在三个节点中的前两个上，主节点不可用时从副本读取
+func TestReplication(t *testing.T) {
+	tests := []struct {
+		factor int
+		want   []bool // 每个节点上是否有写入的数据
+	}{
+		{1, []bool{false, false, false}},
+		{2, []bool{true, true, false}},
+		{3, []bool{true, true, true}},
+	}
+	for _, tt := range tests {
+		peers := []*replicaPeer{{data: map[string][]byte{}}, {data: map[string][]byte{}}, {data: map[string][]byte{}}}
+		opt := DefaultCacheOptions()
+		opt.ReplicationFactor = tt.factor
+		c := NewCache(&opt)
+		c.RegisterPeers(&replicaPicker{peers: peers})
+		if err := c.Set(context.Background(), "k", NewByteView([]byte("v"))); err != nil {
+			t.Fatal(err)
+		}
+		for i, p := range peers {
+			if _, ok := p.data["k"]; ok != tt.want[i] {
+				t.Fatalf("ReplicationFactor=%d: 节点 %d 是否有数据为 %v，期望 %v", tt.factor, i, ok, tt.want[i])
+			}
+		}
+		c.Close()
+	}
+
+	peers := []*replicaPeer{{data: map[string][]byte{}}, {data: map[string][]byte{}}, {data: map[string][]byte{}}}
+	opt := DefaultCacheOptions()
+	opt.ReplicationFactor = 2
+	c := NewCache(&opt)
+	defer c.Close()
+	c.RegisterPeers(&replicaPicker{peers: peers})
+	c.Set(context.Background(), "k", NewByteView([]byte("v")))
+	peers[0].down = true
+	v, err := c.GetOrLoad(context.Background(), "k", func(ctx context.Context, key string) ([]byte, error) {
+		return nil, errors.New("不应该在本地加载")
+	})
+	if err != nil || v.String() != "v" {
+		t.Fatalf("主节点不可用时应该从副本读取，实际为 %q %v", v.String(), err)
+	}
+}
+
+// 负责该key的主节点和副本全部不可用时在本地加载，DisableLocalFallback 时返回 ErrPeersUnavailable；远程节点明确未命中时总是在本地加载
+func TestLocalFallback(t *testing.T) {
+	tests := []struct {
+		name      string
+		disable   bool
+		down      bool
+		wantErr   error
+		wantLocal int32
+	}{
+		{"节点全部不可用时本地兜底", false, true, nil, 1},
+		{"关闭本地兜底", true, true, ErrPeersUnavailable, 0},
+		{"远程未命中时本地加载", true, false, nil, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			peers := []*replicaPeer{{data: map[string][]byte{}, down: tt.down}, {data: map[string][]byte{}, down: tt.down}}
+			opt := DefaultCacheOptions()
+			opt.ReplicationFactor = 2
+			opt.DisableLocalFallback = tt.disable
+			c := NewCache(&opt)
+			defer c.Close()
+			c.RegisterPeers(&replicaPicker{peers: peers})
+			var local int32
+			v, err := c.GetOrLoad(context.Background(), "k", func(ctx context.Context, key string) ([]byte, error) {
+				atomic.AddInt32(&local, 1)
+				return []byte("local-" + key), nil
+			})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("应该返回 %v，实际为 %v", tt.wantErr, err)
+				}
+			} else if err != nil || v.String() != "local-k" {
+				t.Fatalf("本地加载的结果为 %q %v", v.String(), err)
+			}
+			if local != tt.wantLocal {
+				t.Fatalf("本地 loader 调用了 %d 次，期望 %d 次", local, tt.wantLocal)
+			}
+		})
+	}
+}
+
+// MaxConcurrentLoads 限制同时执行的 loader 数量，LoadFailFast 时超出限制的加载立即返回 ErrLoadThrottled，否则排队等待
+func TestMaxConcurrentLoads(t *testing.T) {
+	tests := []struct {
+		name          string
+		failFast      bool
+		wantThrottled int32
+	}{
+		{"排队等待", false, 0},
+		{"立即失败", true, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opt := DefaultCacheOptions()
+			opt.MaxConcurrentLoads = 2
+			opt.LoadFailFast = tt.failFast
+			c := NewCache(&opt)
+			defer c.Close()
+			var running, peak int32
+			started := make(chan struct{}, 6)
+			release := make(chan struct{})
+			loader := func(ctx context.Context, key string) ([]byte, error) {
+				n := atomic.AddInt32(&running, 1)
+				for {
+					p := atomic.LoadInt32(&peak)
+					if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+						break
+					}
+				}
+				started <- struct{}{}
+				<-release
+				atomic.AddInt32(&running, -1)
+				return []byte(key), nil
+			}
+
+			var throttled int32
+			var wg sync.WaitGroup
+			load := func(key string) {
+				defer wg.Done()
+				v, err := c.GetOrLoad(context.Background(), key, loader)
+				switch {
+				case errors.Is(err, ErrLoadThrottled):
+					atomic.AddInt32(&throttled, 1)
+				case err != nil || v.String() != key:
+					t.Errorf("GetOrLoad(%q) 返回 %q %v", key, v.String(), err)
+				}
+			}
+			// 先占满两个加载名额，再发起其余的加载
+			wg.Add(2)
+			go load("0")
+			go load("1")
+			<-started
+			<-started
+			wg.Add(4)
+			for i := 2; i < 6; i++ {
+				go load(strconv.Itoa(i))
+			}
+			if tt.failFast {
+				deadline := time.Now().Add(time.Second)
+				for atomic.LoadInt32(&throttled) < tt.wantThrottled && time.Now().Before(deadline) {
+					time.Sleep(time.Millisecond)
+				}
+			}
+			close(release)
+			wg.Wait()
+			if peak > 2 {
+				t.Fatalf("同时执行的 loader 最多有 %d 个，超过了限制", peak)
+			}
+			if throttled != tt.wantThrottled {
+				t.Fatalf("%d 个加载被拒绝，期望 %d 个", throttled, tt.wantThrottled)
+			}
+		})
+	}
+}
+
+// OnHit/OnMiss 以正确的key和次数触发，回调中再调用缓存的方法不会死锁；缓存关闭之后不再触发
+func TestOnHitOnMiss(t *testing.T) {
+	hits := map[string]int{}
+	misses := map[string]int{}
+	opt := DefaultCacheOptions()
+	var c *Cache
+	opt.OnHit = func(key string) {
+		hits[key]++
+		c.AddBytes("seen:"+key, nil)
+	}
+	opt.OnMiss = func(key string) {
+		misses[key]++
+		if key != "x" {
+			c.Get(context.Background(), "x")
+		}
+	}
+	c = NewCache(&opt)
+	ctx := context.Background()
+	c.AddBytes("a", []byte("1"))
+	c.Get(ctx, "a")
+	c.Get(ctx, "a")
+	c.Get(ctx, "b")
+	c.GetE(ctx, "c")
+
+	wantHits := map[string]int{"a": 2}
+	wantMisses := map[string]int{"b": 1, "c": 1, "x": 2}
+	if !reflect.DeepEqual(hits, wantHits) || !reflect.DeepEqual(misses, wantMisses) {
+		t.Fatalf("OnHit 收到 %v，OnMiss 收到 %v", hits, misses)
+	}
+	if !c.contains("seen:a") {
+		t.Fatal("OnHit 中写入的key应该存在")
+	}
+	c.Close()
+	c.Get(ctx, "a")
+	if hits["a"] != 2 || misses["a"] != 0 {
+		t.Fatalf("缓存关闭之后不应该触发回调: %v %v", hits, misses)
+	}
+}
+
+// 已经使用的容量越过 HighWaterMark 时 OnHighWater 只调用一次，降回阈值以下之后再次越过才会重新触发
+func TestOnHighWater(t *testing.T) {
+	tests := []struct {
+		name  string
+		mark  float64
+		below int // 低于阈值时可以写入的条目数，每个条目占用 100 字节
+	}{
+		{"默认阈值", 0, 8},
+		{"HighWaterMark=0.5", 0.5, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opt := DefaultCacheOptions()
+			opt.MaxBytes = 1000
+			opt.HighWaterMark = tt.mark
+			var calls int
+			var current, maxBytes int64
+			var c *Cache
+			opt.OnHighWater = func(cur, max int64) {
+				calls++
+				current, maxBytes = cur, max
+				// 回调中可以再次访问缓存
+				c.Stats()
+			}
+			c = NewCache(&opt)
+			defer c.Close()
+			add := func(from, to int) {
+				for i := from; i < to; i++ {
+					c.Add("k"+strconv.Itoa(i), NewByteView(make([]byte, 98)))
+				}
+			}
+			add(0, tt.below)
+			if calls != 0 {
+				t.Fatalf("低于阈值时调用了 %d 次 OnHighWater", calls)
+			}
+			add(tt.below, 30)
+			if calls != 1 || maxBytes != 1000 || current < int64(tt.below+1)*100 || current > 1000 {
+				t.Fatalf("越过阈值之后调用了 %d 次，参数为 %d %d", calls, current, maxBytes)
+			}
+			for i := 0; i < 30; i++ {
+				c.Delete("k" + strconv.Itoa(i))
+			}
+			add(0, 10)
+			if calls != 2 {
+				t.Fatalf("降回阈值以下再次越过之后调用了 %d 次，期望 2 次", calls)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"Distributed-Cache-Go/msgpack"
+	"bytes"
+	"encoding/gob"
+)
+
+// Codec 缓存值的序列化方式，TypedCache 和 Group.GetInto 通过它在 Go 类型和 ByteView 之间转换
+// Codec 只决定缓存值本身的格式：HTTP 和 gRPC 的消息格式由各自的协议决定（见 protocol.go），不受 Codec 影响，
+// 节点之间原样传输编码之后的值，所以同一个 Group 的所有节点（以及共享缓存的其他服务）需要使用相同的 Codec
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// GobCodec 使用 encoding/gob 序列化，是默认的 Codec，只适合 Go 服务之间共享
+type GobCodec struct{}
+
+func (GobCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (GobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// MsgPackCodec 使用 MsgPack 序列化，格式紧凑并且与语言无关，适合跨服务共享缓存
+// 结构体按字段名编码为 map，可以通过 `msgpack:"name"` 标签修改字段名
+// time.Time 使用 MsgPack 的时间戳扩展类型，没有导出字段并且没有实现 encoding.BinaryMarshaler/TextMarshaler 的结构体会编码失败
+type MsgPackCodec struct{}
+
+func (MsgPackCodec) Marshal(v interface{}) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+func (MsgPackCodec) Unmarshal(data []byte, v interface{}) error {
+	return msgpack.Unmarshal(data, v)
+}
+
+// 返回实际使用的 Codec，未配置时使用 gob
+func (c *Cache) codec() Codec {
+	if c.cacheOptions.Codec != nil {
+		return c.cacheOptions.Codec
+	}
+	return GobCodec{}
+}
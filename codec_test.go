@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+type codecUser struct {
+	Name string
+	Tags []string
+	N    int
+}
+
+// 没有配置 Codec 时使用 gob，配置 MsgPackCodec 之后 TypedCache 和 GetInto 都使用 MsgPack
+func TestCodecRoundTrip(t *testing.T) {
+	v := codecUser{"a", []string{"x"}, 3}
+	tests := []struct {
+		name  string
+		codec Codec
+		check Codec // 直接用来解码缓存中原始字节的 Codec
+	}{
+		{"默认 gob", nil, GobCodec{}},
+		{"MsgPack", MsgPackCodec{}, MsgPackCodec{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opt := DefaultCacheOptions()
+			opt.Codec = tt.codec
+			c := NewCache(&opt)
+			defer c.Close()
+			tc := NewTypedCache[codecUser](c, nil, nil)
+			if err := tc.Add("k", v); err != nil {
+				t.Fatal(err)
+			}
+			if got, ok := tc.Get(context.Background(), "k"); !ok || !reflect.DeepEqual(got, v) {
+				t.Fatalf("TypedCache 读取到 %+v %v", got, ok)
+			}
+			raw, _ := c.Get(context.Background(), "k")
+			var got codecUser
+			if err := tt.check.Unmarshal(raw.b, &got); err != nil || !reflect.DeepEqual(got, v) {
+				t.Fatalf("缓存中的字节不是预期的格式: %v", err)
+			}
+		})
+	}
+
+	b, _ := MsgPackCodec{}.Marshal(v)
+	opt := DefaultCacheOptions()
+	opt.Codec = MsgPackCodec{}
+	g := NewGroup("codec-round-trip", opt, LoaderFunc(func(ctx context.Context, key string) ([]byte, error) {
+		return b, nil
+	}))
+	var out codecUser
+	if err := g.GetInto(context.Background(), "k", &out); err != nil || !reflect.DeepEqual(out, v) {
+		t.Fatalf("GetInto 解码得到 %+v %v", out, err)
+	}
+}
+
+// 节点之间原样传输 Codec 编码之后的值，远程节点取回的字节可以用同一个 Codec 解码
+func TestCodecBytesOnTheWire(t *testing.T) {
+	opt := DefaultCacheOptions()
+	opt.Codec = MsgPackCodec{}
+	c := NewCache(&opt)
+	defer c.Close()
+	v := codecUser{"a", []string{"x"}, 3}
+	if err := NewTypedCache[codecUser](c, nil, nil).Add("k", v); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(NewHTTPPool("self", c))
+	defer srv.Close()
+	b, err := (&httpGetter{baseURL: srv.URL + defaultBasePath}).Get(context.Background(), "", "k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got codecUser
+	if err := (MsgPackCodec{}).Unmarshal(b, &got); err != nil || !reflect.DeepEqual(got, v) {
+		t.Fatalf("远程节点取回的值无法用 MsgPack 解码: %+v %v", got, err)
+	}
+}
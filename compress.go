@@ -0,0 +1,103 @@
+package main
+
+import (
+	"Distributed-Cache-Go/lru"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"go.uber.org/zap"
+	"io"
+)
+
+// Compressor 压缩算法，CacheOptions.CompressThreshold 大于 0 时用于压缩较大的值
+type Compressor interface {
+	Compress(b []byte) ([]byte, error)
+	Decompress(b []byte) ([]byte, error)
+}
+
+// GzipCompressor 使用 gzip 压缩，Level 为 0 时使用 gzip.DefaultCompression
+type GzipCompressor struct {
+	Level int
+}
+
+func (g GzipCompressor) Compress(b []byte) ([]byte, error) {
+	level := g.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(b); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (g GzipCompressor) Decompress(b []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// compressedView 压缩后存储在底层 Store 中的值，Len 返回压缩后的大小，因此容量统计按照压缩后的大小计算
+type compressedView struct {
+	b []byte
+}
+
+func (v compressedView) Len() int {
+	return len(v.b)
+}
+
+// 返回实际使用的压缩算法，未配置时使用 gzip
+func (c *Cache) compressor() Compressor {
+	if c.cacheOptions.Compressor != nil {
+		return c.cacheOptions.Compressor
+	}
+	return GzipCompressor{}
+}
+
+// 将 ByteView 转换成写入底层 Store 的值，超过阈值的值会被压缩，压缩失败或者压缩后没有变小时按原样存储
+func (c *Cache) encodeValue(key string, value ByteView) lru.Value {
+	threshold := c.cacheOptions.CompressThreshold
+	if threshold <= 0 || value.Len() <= threshold {
+		return value
+	}
+	b, err := c.compressor().Compress(value.b)
+	if err != nil {
+		c.log.Error("压缩缓存数据失败", zap.String("key", key), zap.Error(err))
+		return value
+	}
+	if len(b) >= value.Len() {
+		return value
+	}
+	return compressedView{b: b}
+}
+
+// 将底层 Store 中的值还原成 ByteView，压缩过的值会被解压，负缓存的标记返回 ErrNotFound
+func (c *Cache) decodeValue(key string, value lru.Value) (ByteView, error) {
+	switch v := value.(type) {
+	case ByteView:
+		return v, nil
+	case compressedView:
+		b, err := c.compressor().Decompress(v.b)
+		if err != nil {
+			c.log.Error("解压缓存数据失败", zap.String("key", key), zap.Error(err))
+			return ByteView{}, fmt.Errorf("解压缓存数据失败:%v", err.Error())
+		}
+		return ByteView{b: b}, nil
+	case tombstone:
+		return ByteView{}, ErrNotFound
+	default:
+		c.log.Error("缓存中的值类型错误", zap.String("key", key), zap.String("type", fmt.Sprintf("%T", value)))
+		return ByteView{}, ErrTypeMismatch
+	}
+}
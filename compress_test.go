@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"strings"
+	"testing"
+)
+
+// 超过阈值并且可以压缩的值以压缩后的大小存储，读取、批量读取以及导出导入都透明地解压；小的值和压缩后没有变小的值按原样存储
+func TestCompressRoundTrip(t *testing.T) {
+	random := make([]byte, 1000)
+	rand.Read(random)
+	tests := []struct {
+		name           string
+		value          []byte
+		wantCompressed bool
+	}{
+		{"大于阈值", []byte(strings.Repeat("abc", 1000)), true},
+		{"小于阈值", []byte("hi"), false},
+		{"压缩后没有变小", random, false},
+	}
+	opt := DefaultCacheOptions()
+	opt.CompressThreshold = 100
+	c := NewCache(&opt)
+	defer c.Close()
+	ctx := context.Background()
+	var keys []string
+	for _, tt := range tests {
+		c.AddBytes(tt.name, tt.value)
+		keys = append(keys, tt.name)
+	}
+	multi, err := c.GetMulti(ctx, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var exported bytes.Buffer
+	if err := c.Export(&exported); err != nil {
+		t.Fatal(err)
+	}
+	plain := DefaultCacheOptions()
+	c2 := NewCache(&plain)
+	defer c2.Close()
+	if err := c2.Import(&exported); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		stored, _ := c.store.Peek(tt.name)
+		if _, ok := stored.(compressedView); ok != tt.wantCompressed {
+			t.Fatalf("%s: 存储的值为 %T", tt.name, stored)
+		}
+		if tt.wantCompressed && stored.Len() >= len(tt.value) {
+			t.Fatalf("%s: 压缩之后的大小 %d 没有小于原始大小 %d", tt.name, stored.Len(), len(tt.value))
+		}
+		if v, ok := c.Get(ctx, tt.name); !ok || !bytes.Equal(v.b, tt.value) {
+			t.Fatalf("%s: Get 读取到的值与写入的不同", tt.name)
+		}
+		if !bytes.Equal(multi[tt.name].b, tt.value) {
+			t.Fatalf("%s: GetMulti 读取到的值与写入的不同", tt.name)
+		}
+		if v, ok := c2.Get(ctx, tt.name); !ok || !bytes.Equal(v.b, tt.value) {
+			t.Fatalf("%s: 导入到没有开启压缩的缓存之后值与写入的不同", tt.name)
+		}
+	}
+	if s := c.Stats(); s.Bytes >= int64(3000+len(random)) {
+		t.Fatalf("容量统计应该按照压缩后的大小计算，实际为 %d", s.Bytes)
+	}
+}
@@ -0,0 +1,133 @@
+package consistenthash
+
+import (
+	"hash/crc32"
+	"sort"
+	"strconv"
+)
+
+// Hash 将字节数据映射为 uint32，允许替换为自定义的哈希函数，默认使用 crc32.ChecksumIEEE
+type Hash func(data []byte) uint32
+
+// Map 一致性哈希环，包含所有节点（以及每个节点对应的虚拟节点）
+type Map struct {
+	hash     Hash           // 哈希函数
+	replicas int            // 每个真实节点对应的虚拟节点个数
+	keys     []int          // 哈希环，有序
+	hashMap  map[int]string // 虚拟节点哈希值到真实节点名称的映射
+	vnodes   map[string]int // 每个真实节点实际创建的虚拟节点个数，带权重的节点为 replicas*weight
+}
+
+// 构造函数
+func New(replicas int, fn Hash) *Map {
+	m := &Map{
+		replicas: replicas,
+		hash:     fn,
+		hashMap:  make(map[int]string),
+		vnodes:   make(map[string]int),
+	}
+	if m.hash == nil {
+		m.hash = crc32.ChecksumIEEE
+	}
+	if m.replicas <= 0 {
+		m.replicas = 1
+	}
+	return m
+}
+
+// IsEmpty 哈希环上是否没有任何节点
+func (m *Map) IsEmpty() bool {
+	return len(m.keys) == 0
+}
+
+// Add 向哈希环中添加真实节点，每个真实节点会创建 replicas 个虚拟节点
+func (m *Map) Add(keys ...string) {
+	for _, key := range keys {
+		m.add(key, m.replicas)
+	}
+	sort.Ints(m.keys)
+}
+
+// AddWeighted 添加带权重的真实节点，创建 replicas*weight 个虚拟节点，
+// 所以权重为 2 的节点负责的key大约是权重为 1 的节点的两倍，适合容量不同的节点组成的集群
+// weight 小于等于 0 时按 1 处理；节点已经存在时先删除原来的虚拟节点再按新的权重添加
+func (m *Map) AddWeighted(key string, weight int) {
+	if weight <= 0 {
+		weight = 1
+	}
+	if _, ok := m.vnodes[key]; ok {
+		m.Remove(key)
+	}
+	m.add(key, m.replicas*weight)
+	sort.Ints(m.keys)
+}
+
+// 为节点创建 n 个虚拟节点，调用方负责排序
+func (m *Map) add(key string, n int) {
+	for i := 0; i < n; i++ {
+		hash := int(m.hash([]byte(strconv.Itoa(i) + key)))
+		m.keys = append(m.keys, hash)
+		m.hashMap[hash] = key
+	}
+	if n > m.vnodes[key] {
+		m.vnodes[key] = n
+	}
+}
+
+// Remove 从哈希环中删除真实节点及其所有虚拟节点
+func (m *Map) Remove(key string) {
+	n, ok := m.vnodes[key]
+	if !ok {
+		n = m.replicas
+	}
+	delete(m.vnodes, key)
+	removed := make(map[int]struct{}, n)
+	for i := 0; i < n; i++ {
+		hash := int(m.hash([]byte(strconv.Itoa(i) + key)))
+		// 只删除确实属于该节点的虚拟节点，避免哈希冲突时误删其他节点
+		if m.hashMap[hash] == key {
+			delete(m.hashMap, hash)
+			removed[hash] = struct{}{}
+		}
+	}
+	keys := m.keys[:0]
+	for _, k := range m.keys {
+		if _, ok := removed[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	m.keys = keys
+}
+
+// Get 返回 key 在哈希环上顺时针方向遇到的第一个节点，哈希环为空时返回 ""
+func (m *Map) Get(key string) string {
+	if len(m.keys) == 0 {
+		return ""
+	}
+	hash := int(m.hash([]byte(key)))
+	// 二分查找第一个大于等于 hash 的虚拟节点
+	idx := sort.SearchInts(m.keys, hash)
+	// idx == len(m.keys) 时说明需要回到环的起点
+	return m.hashMap[m.keys[idx%len(m.keys)]]
+}
+
+// GetN 沿哈希环顺时针返回负责 key 的前 n 个不同的真实节点，第一个与 Get 的结果相同
+// 真实节点不足 n 个时返回所有节点
+func (m *Map) GetN(key string, n int) []string {
+	if len(m.keys) == 0 || n <= 0 {
+		return nil
+	}
+	hash := int(m.hash([]byte(key)))
+	idx := sort.SearchInts(m.keys, hash)
+	nodes := make([]string, 0, n)
+	seen := make(map[string]struct{}, n)
+	for i := 0; i < len(m.keys) && len(nodes) < n; i++ {
+		node := m.hashMap[m.keys[(idx+i)%len(m.keys)]]
+		if _, ok := seen[node]; ok {
+			continue
+		}
+		seen[node] = struct{}{}
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
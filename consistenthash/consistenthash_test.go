@@ -0,0 +1,141 @@
+package consistenthash
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// 使用数字本身作为哈希值，方便推算每个key落在哪个节点上
+func numberHash(data []byte) uint32 {
+	n, _ := strconv.Atoi(string(data))
+	return uint32(n)
+}
+
+// 虚拟节点为 2、4、6、12、14、16、22、24、26，顺时针找到第一个不小于key的虚拟节点
+func TestGet(t *testing.T) {
+	m := New(3, numberHash)
+	if m.Get("x") != "" {
+		t.Fatal("空的哈希环应该返回空字符串")
+	}
+	m.Add("6", "4", "2")
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"2", "2"},
+		{"11", "2"},
+		{"23", "4"},
+		{"27", "2"}, // 超过最大的虚拟节点时回到环的起点
+	}
+	for _, tt := range tests {
+		if got := m.Get(tt.key); got != tt.want {
+			t.Fatalf("Get(%q) = %q，期望 %q", tt.key, got, tt.want)
+		}
+	}
+}
+
+// 添加或者删除一个节点时，只有该节点负责的key会重新映射，其他key的负责节点不变
+func TestAddRemoveRemapsOnlyThatNode(t *testing.T) {
+	m := New(50, nil)
+	m.Add("a", "b", "c")
+	before := make(map[string]string)
+	for i := 0; i < 10000; i++ {
+		key := "key" + strconv.Itoa(i)
+		before[key] = m.Get(key)
+	}
+
+	m.Add("d")
+	moved := 0
+	for key, owner := range before {
+		if got := m.Get(key); got != owner {
+			if got != "d" {
+				t.Fatalf("添加 d 之后 %s 从 %s 移动到了 %s", key, owner, got)
+			}
+			moved++
+		}
+	}
+	if moved == 0 {
+		t.Fatal("添加节点之后应该有一部分key移动到新节点")
+	}
+
+	m.Remove("d")
+	for key, owner := range before {
+		if got := m.Get(key); got != owner {
+			t.Fatalf("删除 d 之后 %s 应该回到 %s，实际为 %s", key, owner, got)
+		}
+	}
+	m.Remove("b")
+	for key, owner := range before {
+		if got := m.Get(key); owner != "b" && got != owner {
+			t.Fatalf("删除 b 之后不属于 b 的 %s 从 %s 移动到了 %s", key, owner, got)
+		}
+	}
+}
+
+// GetN 返回前 n 个不同的真实节点，第一个与 Get 相同，节点不足时返回所有节点
+func TestGetN(t *testing.T) {
+	m := New(3, numberHash)
+	if got := m.GetN("x", 2); len(got) != 0 {
+		t.Fatalf("空的哈希环应该返回空列表，实际为 %v", got)
+	}
+	m.Add("6", "4", "2")
+	tests := []struct {
+		key  string
+		n    int
+		want []string
+	}{
+		{"11", 1, []string{"2"}},
+		{"11", 2, []string{"2", "4"}},
+		{"23", 3, []string{"4", "6", "2"}},
+		{"27", 2, []string{"2", "4"}},
+		{"11", 5, []string{"2", "4", "6"}},
+	}
+	for _, tt := range tests {
+		got := m.GetN(tt.key, tt.n)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Fatalf("GetN(%q, %d) = %v，期望 %v", tt.key, tt.n, got, tt.want)
+		}
+		if got[0] != m.Get(tt.key) {
+			t.Fatalf("GetN(%q) 的第一个节点与 Get 不同", tt.key)
+		}
+	}
+}
+
+// 权重为 2 的节点负责的key大约是权重为 1 的节点的两倍；重新设置权重或者删除节点之后虚拟节点的数量随之变化
+func TestAddWeighted(t *testing.T) {
+	m := New(100, nil)
+	m.Add("a")
+	m.AddWeighted("b", 2)
+	m.Add("c")
+	counts := make(map[string]int)
+	for i := 0; i < 100000; i++ {
+		counts[m.Get("key"+strconv.Itoa(i))]++
+	}
+	if ratio := float64(counts["b"]) / (float64(counts["a"]+counts["c"]) / 2); ratio < 1.6 || ratio > 2.5 {
+		t.Fatalf("权重为 2 的节点分到的key是其他节点的 %.2f 倍: %v", ratio, counts)
+	}
+
+	tests := []struct {
+		name   string
+		update func()
+		want   int
+	}{
+		{"初始", func() {}, 400},
+		{"权重改为 1", func() { m.AddWeighted("b", 1) }, 300},
+		{"权重小于等于 0 按 1 处理", func() { m.AddWeighted("b", 0) }, 300},
+		{"权重改为 3", func() { m.AddWeighted("b", 3) }, 500},
+		{"删除节点", func() { m.Remove("b") }, 200},
+	}
+	for _, tt := range tests {
+		tt.update()
+		if len(m.keys) != tt.want || len(m.hashMap) != tt.want {
+			t.Fatalf("%s: 共有 %d 个虚拟节点，期望 %d", tt.name, len(m.keys), tt.want)
+		}
+	}
+	for _, node := range m.hashMap {
+		if node == "b" {
+			t.Fatal("删除之后哈希环上不应该还有 b 的虚拟节点")
+		}
+	}
+}
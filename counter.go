@@ -0,0 +1,58 @@
+package main
+
+import (
+	"Distributed-Cache-Go/lru"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+)
+
+// ErrNotInteger 缓存中的值不是十进制整数，无法执行 Increment/Decrement
+var ErrNotInteger = errors.New("缓存中的值不是整数")
+
+// Increment 将key对应的值解析为十进制整数并加上 delta，写回后返回新的值
+// key 不存在时以 delta 作为初始值并使用默认过期时间，已经存在时保留原来的过期时间
+// 同一个key上的 Increment/Decrement 以及 Add/Delete 通过 key 锁串行执行，不同key之间互不影响
+func (c *Cache) Increment(key string, delta int64) (int64, error) {
+	if atomic.LoadInt32(&c.closed) == 1 {
+		return 0, ErrCacheClosed
+	}
+	if !c.beginWrite() {
+		return 0, ErrCacheClosed
+	}
+	defer c.endWrite()
+	c.ensureInitialized()
+	c.keyLocks.Lock(key)
+	defer c.keyLocks.Unlock(key)
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var n int64
+	ttl := c.cacheOptions.DefaultTTL
+	// 使用 Peek 读取旧值，读取本身不应该影响淘汰顺序和命中统计，写回时会像普通写入一样更新它们
+	if val, ok := c.store.Peek(key); ok && !isTombstone(val) {
+		bv, err := c.decodeValue(key, val)
+		if err != nil {
+			return 0, err
+		}
+		n, err = strconv.ParseInt(bv.String(), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%w: key %q", ErrNotInteger, key)
+		}
+		// 写回时保留原来的绝对过期时间，按剩余时间重新写入会再次加上抖动，频繁递增的key可能永远不会过期
+		ttl = lru.KeepTTL
+	}
+	n += delta
+	value := ByteView{b: []byte(strconv.FormatInt(n, 10))}
+	if err := c.store.AddWithTTL(key, c.encodeValue(key, value), ttl); err != nil {
+		return 0, fmt.Errorf("Increment 写回失败:%v", err.Error())
+	}
+	c.persist(key, value.b)
+	return n, nil
+}
+
+// Decrement 等同于 Increment(key, -delta)
+func (c *Cache) Decrement(key string, delta int64) (int64, error) {
+	return c.Increment(key, -delta)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"fmt"
+)
+
+// GobValue 实现Value接口，保存任意 Go 值经过 gob 编码之后的字节，Len 返回编码后的大小
+// 与 ByteView 一样是不可变的，可以直接写入 lru 中的任意 Store，也可以通过 ByteView 转换后写入 Cache
+type GobValue struct {
+	b []byte
+}
+
+// NewGobValue 使用 gob 编码 v，v 中包含 gob 不支持的类型（例如 chan、func）时返回错误
+// 接口类型的字段需要事先通过 gob.Register 注册具体类型
+func NewGobValue(v interface{}) (GobValue, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return GobValue{}, fmt.Errorf("gob 编码失败:%v", err.Error())
+	}
+	return GobValue{b: buf.Bytes()}, nil
+}
+
+func (v GobValue) Len() int {
+	return len(v.b)
+}
+
+// Decode 将保存的值解码到 dst 中，dst 必须是指针
+func (v GobValue) Decode(dst interface{}) error {
+	if err := gob.NewDecoder(bytes.NewReader(v.b)).Decode(dst); err != nil {
+		return fmt.Errorf("gob 解码失败:%w", err)
+	}
+	return nil
+}
+
+// ByteView 返回编码后的字节视图，两者都不可变，所以共享底层数据
+func (v GobValue) ByteView() ByteView {
+	return ByteView{b: v.b}
+}
+
+// AddGob 将 v 编码为 GobValue 后写入缓存，编码失败时返回错误并且不会写入，写入失败时返回 Add 的错误
+func (c *Cache) AddGob(key string, v interface{}) error {
+	gv, err := NewGobValue(v)
+	if err != nil {
+		return fmt.Errorf("AddGob 编码失败:%v", err.Error())
+	}
+	return c.Add(key, gv.ByteView())
+}
+
+// GetGob 查找缓存并将 gob 数据解码到 dst 中，未命中时返回 false 和空错误
+// 命中但解码失败时返回 true 和解码的错误，与 GetJSON 一致
+func (c *Cache) GetGob(ctx context.Context, key string, dst interface{}) (bool, error) {
+	value, ok := c.Get(ctx, key)
+	if !ok {
+		return false, nil
+	}
+	if err := (GobValue{b: value.b}).Decode(dst); err != nil {
+		return true, fmt.Errorf("GetGob 解码失败:%w", err)
+	}
+	return true, nil
+}
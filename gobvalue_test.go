@@ -0,0 +1,76 @@
+package main
+
+import (
+	"Distributed-Cache-Go/lru"
+	"context"
+	"testing"
+)
+
+type gobUser struct {
+	Name string
+	Age  int
+}
+
+// GobValue 可以直接存入底层存储并解码回原来的结构体，Len 返回编码后的大小；gob 不支持的类型返回错误
+func TestGobValue(t *testing.T) {
+	gv, err := NewGobValue(gobUser{Name: "张三", Age: 3})
+	if err != nil || gv.Len() == 0 {
+		t.Fatalf("NewGobValue 返回 %d 字节 %v", gv.Len(), err)
+	}
+	s := lru.NewLruCache(&lru.Options{DisableBackgroundCleanup: true})
+	defer s.Close()
+	s.AddAndUpdateCache("u", gv)
+	if s.Bytes() != int64(len("u")+gv.Len()) {
+		t.Fatalf("占用 %d 字节，期望 %d", s.Bytes(), len("u")+gv.Len())
+	}
+	v, _ := s.FindCache("u")
+	var got gobUser
+	if err := v.(GobValue).Decode(&got); err != nil || got != (gobUser{Name: "张三", Age: 3}) {
+		t.Fatalf("解码得到 %+v %v", got, err)
+	}
+
+	if _, err := NewGobValue(struct{ C chan int }{make(chan int)}); err == nil {
+		t.Fatal("包含 chan 的值应该返回错误")
+	}
+}
+
+// AddGob/GetGob 往返结构体；命中但解码失败时返回 true 和错误，未命中时返回 false 和空错误
+func TestAddGetGob(t *testing.T) {
+	opt := DefaultCacheOptions()
+	c := NewCache(&opt)
+	defer c.Close()
+	ctx := context.Background()
+	if err := c.AddGob("u", gobUser{Name: "李四", Age: 4}); err != nil {
+		t.Fatal(err)
+	}
+	c.AddBytes("bad", []byte("x"))
+
+	tests := []struct {
+		name    string
+		key     string
+		wantOK  bool
+		wantErr bool
+		want    gobUser
+	}{
+		{"命中", "u", true, false, gobUser{Name: "李四", Age: 4}},
+		{"解码失败", "bad", true, true, gobUser{}},
+		{"未命中", "none", false, false, gobUser{}},
+	}
+	for _, tt := range tests {
+		var got gobUser
+		ok, err := c.GetGob(ctx, tt.key, &got)
+		if ok != tt.wantOK || (err != nil) != tt.wantErr {
+			t.Fatalf("%s: GetGob 返回 %v %v", tt.name, ok, err)
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Fatalf("%s: 解码得到 %+v，期望 %+v", tt.name, got, tt.want)
+		}
+	}
+
+	if err := c.AddGob("f", func() {}); err == nil {
+		t.Fatal("无法编码的值应该返回错误")
+	}
+	if _, ok := c.Get(ctx, "f"); ok {
+		t.Fatal("编码失败时不应该写入缓存")
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// 全局的 Group 注册表
+var (
+	groupsMu sync.RWMutex
+	groups   = make(map[string]*Group)
+)
+
+// Group 带命名空间的缓存，每个 Group 拥有独立的本地缓存和数据源
+// 多个 Group 可以共享同一个分布式层（HTTPPool），请求远程节点时会带上 Group 名称，
+// 因此不同 Group 中相同的 key 不会相互冲突
+type Group struct {
+	name  string
+	cache *Cache
+}
+
+// ErrEmptyKey key 为空
+var ErrEmptyKey = errors.New("key 不能为空")
+
+// NewGroup 创建一个 Group 并注册到全局注册表中，同名的 Group 会被覆盖
+func NewGroup(name string, opts CacheOptions, getter Getter) *Group {
+	if getter == nil {
+		panic("NewGroup 的 getter 不能为空")
+	}
+	opts.Getter = getter
+	g := &Group{
+		name:  name,
+		cache: NewCache(&opts),
+	}
+	g.cache.group = name
+	groupsMu.Lock()
+	defer groupsMu.Unlock()
+	groups[name] = g
+	return g
+}
+
+// GetGroup 根据名称获取已经注册的 Group，不存在时返回 nil
+func GetGroup(name string) *Group {
+	groupsMu.RLock()
+	defer groupsMu.RUnlock()
+	return groups[name]
+}
+
+// Name 返回 Group 的名称
+func (g *Group) Name() string {
+	return g.name
+}
+
+// Get 查找缓存，未命中时从远程节点或者本地数据源加载
+func (g *Group) Get(ctx context.Context, key string) (ByteView, error) {
+	if key == "" {
+		return ByteView{}, ErrEmptyKey
+	}
+	return g.cache.Load(ctx, key)
+}
+
+// GetInto 与 Get 相同，并使用 CacheOptions.Codec（默认为 gob）把数据解码到 dst 中，dst 必须是指针
+func (g *Group) GetInto(ctx context.Context, key string, dst interface{}) error {
+	value, err := g.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	return g.cache.codec().Unmarshal(value.b, dst)
+}
+
+// RegisterPeers 注册用于分布式查找的 PeerPicker
+func (g *Group) RegisterPeers(peers PeerPicker) {
+	g.cache.RegisterPeers(peers)
+}
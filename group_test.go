@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+)
+
+// 两个 Group 中相同的key互不影响，远程节点按照请求中的 Group 名称找到对应的 Group
+func TestGroupsDoNotCollide(t *testing.T) {
+	newGroup := func(name, value string) *Group {
+		return NewGroup(name, DefaultCacheOptions(), LoaderFunc(func(ctx context.Context, key string) ([]byte, error) {
+			return []byte(value + ":" + key), nil
+		}))
+	}
+	g1 := newGroup("collide-1", "one")
+	g2 := newGroup("collide-2", "two")
+	srv := httptest.NewServer(NewHTTPPool("self", nil))
+	defer srv.Close()
+	getter := &httpGetter{baseURL: srv.URL + defaultBasePath}
+	ctx := context.Background()
+
+	tests := []struct {
+		group *Group
+		want  string
+	}{
+		{g1, "one:k"},
+		{g2, "two:k"},
+		{g1, "one:k"},
+	}
+	for _, tt := range tests {
+		if GetGroup(tt.group.Name()) != tt.group {
+			t.Fatalf("GetGroup(%q) 没有返回注册的 Group", tt.group.Name())
+		}
+		if v, err := tt.group.Get(ctx, "k"); err != nil || v.String() != tt.want {
+			t.Fatalf("%s.Get(k) = %q %v，期望 %q", tt.group.Name(), v.String(), err, tt.want)
+		}
+		if b, err := getter.Get(ctx, tt.group.Name(), "k"); err != nil || string(b) != tt.want {
+			t.Fatalf("通过 HTTP 获取 %s 中的 k 返回 %q %v，期望 %q", tt.group.Name(), b, err, tt.want)
+		}
+	}
+	if GetGroup("collide-none") != nil {
+		t.Fatal("不存在的 Group 应该返回 nil")
+	}
+}
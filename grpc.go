@@ -0,0 +1,230 @@
+package main
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative cachepb/cache.proto
+
+import (
+	"Distributed-Cache-Go/cachepb"
+	"Distributed-Cache-Go/lru"
+	"context"
+	"errors"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// GRPCServer 将 *Cache 包装为 gRPC 服务，供其他节点通过 gRPC 读写当前节点的缓存
+type GRPCServer struct {
+	cachepb.UnimplementedCacheServer
+	cache *Cache
+	// 接受的最低协议版本，0 表示兼容没有版本的旧节点，只有使用了 UnaryInterceptor 时才会检查
+	minProtocolVersion int32
+}
+
+// 构造函数，cache 可以为空，此时只能通过 group 访问各个 Group
+func NewGRPCServer(cache *Cache) *GRPCServer {
+	return &GRPCServer{cache: cache}
+}
+
+// Register 将服务注册到 grpc.Server 上
+func (s *GRPCServer) Register(srv *grpc.Server) {
+	cachepb.RegisterCacheServer(srv, s)
+}
+
+// SetMinProtocolVersion 设置接受的最低协议版本，含义与 HTTPPool.SetMinProtocolVersion 相同，
+// 低于该版本的请求会被 UnaryInterceptor 拒绝并返回 FailedPrecondition
+func (s *GRPCServer) SetMinProtocolVersion(v int) {
+	if v < 0 {
+		v = 0
+	}
+	if v > protocolVersion {
+		v = protocolVersion
+	}
+	atomic.StoreInt32(&s.minProtocolVersion, int32(v))
+}
+
+// UnaryInterceptor 返回检查协议版本的拦截器，创建 grpc.Server 时通过 grpc.UnaryInterceptor 传入
+// 请求的 metadata 中带有对方支持的最高版本，版本不兼容时返回 FailedPrecondition，否则在响应的 header 中写入协商出的版本；
+// 同一个 grpc.Server 上其他服务的请求不受影响
+func (s *GRPCServer) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	prefix := "/" + cachepb.Cache_ServiceDesc.ServiceName + "/"
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if !strings.HasPrefix(info.FullMethod, prefix) {
+			return handler(ctx, req)
+		}
+		md, _ := metadata.FromIncomingContext(ctx)
+		v, err := parseProtocolVersion(firstMetadata(md, grpcProtocolKey))
+		if minVersion := int(atomic.LoadInt32(&s.minProtocolVersion)); err == nil && v < minVersion {
+			err = checkProtocolVersion(v, minVersion)
+		}
+		if err != nil {
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		}
+		// 对方版本更高时按照当前节点的版本回复，由对方决定是否接受
+		if v > protocolVersion {
+			v = protocolVersion
+		}
+		if v > 0 {
+			grpc.SetHeader(ctx, metadata.Pairs(grpcProtocolKey, strconv.Itoa(v)))
+		}
+		return handler(ctx, req)
+	}
+}
+
+// 根据请求中的 group 选择要访问的缓存，group 为空时访问 s.cache
+func (s *GRPCServer) lookup(name string) (*Cache, *Group, error) {
+	if name == "" {
+		if s.cache == nil {
+			return nil, nil, status.Error(codes.NotFound, "没有默认缓存")
+		}
+		return s.cache, nil, nil
+	}
+	group := GetGroup(name)
+	if group == nil {
+		return nil, nil, status.Error(codes.NotFound, "group 不存在: "+name)
+	}
+	return group.cache, group, nil
+}
+
+func (s *GRPCServer) Get(ctx context.Context, req *cachepb.GetRequest) (*cachepb.GetResponse, error) {
+	if req.GetKey() == "" {
+		return nil, status.Error(codes.InvalidArgument, "key 不能为空")
+	}
+	cache, group, err := s.lookup(req.GetGroup())
+	if err != nil {
+		return nil, err
+	}
+	// 访问 Group 时未命中会通过 Group 的数据源加载
+	if group != nil {
+		value, err := group.Get(ctx, req.GetKey())
+		if errors.Is(err, ErrNotFound) {
+			return nil, status.Error(codes.NotFound, err.Error())
+		}
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		return &cachepb.GetResponse{Value: value.ByteSlice()}, nil
+	}
+	value, ok := cache.Get(ctx, req.GetKey())
+	if !ok {
+		return nil, status.Error(codes.NotFound, "key 不存在")
+	}
+	return &cachepb.GetResponse{Value: value.ByteSlice()}, nil
+}
+
+func (s *GRPCServer) Set(ctx context.Context, req *cachepb.SetRequest) (*cachepb.SetResponse, error) {
+	if req.GetKey() == "" {
+		return nil, status.Error(codes.InvalidArgument, "key 不能为空")
+	}
+	cache, _, err := s.lookup(req.GetGroup())
+	if err != nil {
+		return nil, err
+	}
+	if err := cache.Add(req.GetKey(), NewByteView(req.GetValue())); err != nil {
+		switch {
+		case errors.Is(err, ErrCacheClosed):
+			return nil, status.Error(codes.Unavailable, err.Error())
+		case errors.Is(err, lru.ErrValueTooLarge):
+			return nil, status.Error(codes.ResourceExhausted, err.Error())
+		default:
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+	}
+	return &cachepb.SetResponse{}, nil
+}
+
+func (s *GRPCServer) Delete(ctx context.Context, req *cachepb.DeleteRequest) (*cachepb.DeleteResponse, error) {
+	if req.GetKey() == "" {
+		return nil, status.Error(codes.InvalidArgument, "key 不能为空")
+	}
+	cache, _, err := s.lookup(req.GetGroup())
+	if err != nil {
+		return nil, err
+	}
+	cache.Delete(req.GetKey())
+	return &cachepb.DeleteResponse{}, nil
+}
+
+// GRPCClient 通过 gRPC 访问远程节点，实现了 PeerGetter 接口
+// 每个请求的 metadata 中都带有当前节点的协议版本，与 HTTP 的 X-Cache-Protocol 头含义相同
+type GRPCClient struct {
+	client     cachepb.CacheClient
+	minVersion int // 接受的远程节点的最低协议版本
+}
+
+// 构造函数，conn 由调用方创建并负责关闭，需要失败重试时可以通过 NewRetryPeer 包装
+func NewGRPCClient(conn grpc.ClientConnInterface) *GRPCClient {
+	return &GRPCClient{client: cachepb.NewCacheClient(conn)}
+}
+
+// SetMinProtocolVersion 设置接受的远程节点的最低协议版本，远程节点的版本更低时请求返回 ErrProtocolVersion
+// 默认为 0，即兼容没有版本的旧节点；需要在开始使用客户端之前调用
+func (c *GRPCClient) SetMinProtocolVersion(v int) {
+	if v < 0 {
+		v = 0
+	}
+	if v > protocolVersion {
+		v = protocolVersion
+	}
+	c.minVersion = v
+}
+
+// Get 从远程节点获取数据，远程节点未命中时返回 ErrPeerNotFound
+func (c *GRPCClient) Get(ctx context.Context, group string, key string) ([]byte, error) {
+	var header metadata.MD
+	resp, err := c.client.Get(withProtocolVersion(ctx), &cachepb.GetRequest{Group: group, Key: key}, grpc.Header(&header))
+	if status.Code(err) == codes.NotFound {
+		return nil, ErrPeerNotFound
+	}
+	if err := c.checkProtocol(header, err); err != nil {
+		return nil, err
+	}
+	return resp.GetValue(), nil
+}
+
+// Set 向远程节点写入数据
+func (c *GRPCClient) Set(ctx context.Context, group string, key string, value []byte) error {
+	var header metadata.MD
+	_, err := c.client.Set(withProtocolVersion(ctx), &cachepb.SetRequest{Group: group, Key: key, Value: value}, grpc.Header(&header))
+	return c.checkProtocol(header, err)
+}
+
+// Delete 删除远程节点上的数据
+func (c *GRPCClient) Delete(ctx context.Context, group string, key string) error {
+	var header metadata.MD
+	_, err := c.client.Delete(withProtocolVersion(ctx), &cachepb.DeleteRequest{Group: group, Key: key}, grpc.Header(&header))
+	return c.checkProtocol(header, err)
+}
+
+// 在请求的 metadata 中带上当前节点支持的最高协议版本
+func withProtocolVersion(ctx context.Context) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, grpcProtocolKey, strconv.Itoa(protocolVersion))
+}
+
+// 检查请求的结果：远程节点因为版本不兼容拒绝请求时返回 ErrProtocolVersion，
+// 请求成功时检查响应 header 中远程节点的版本，没有版本的旧节点视为版本 0
+func (c *GRPCClient) checkProtocol(header metadata.MD, err error) error {
+	if status.Code(err) == codes.FailedPrecondition {
+		return fmt.Errorf("%w: 远程节点拒绝了请求:%s", ErrProtocolVersion, status.Convert(err).Message())
+	}
+	if err != nil {
+		return err
+	}
+	v, err := parseProtocolVersion(firstMetadata(header, grpcProtocolKey))
+	if err != nil {
+		return err
+	}
+	return checkProtocolVersion(v, c.minVersion)
+}
+
+// 返回 metadata 中 key 对应的第一个值，没有时返回空字符串
+func firstMetadata(md metadata.MD, key string) string {
+	if vals := md.Get(key); len(vals) > 0 {
+		return vals[0]
+	}
+	return ""
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"Distributed-Cache-Go/cachepb"
+	"context"
+	"errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"net"
+	"strings"
+	"testing"
+)
+
+// 通过 bufconn 在内存中启动 GRPCServer，返回连接到它的客户端
+func newBufconnClient(t *testing.T, cache *Cache) *GRPCClient {
+	t.Helper()
+	srv := grpc.NewServer()
+	NewGRPCServer(cache).Register(srv)
+	return NewGRPCClient(dialBufconn(t, srv))
+}
+
+// 通过 bufconn 在内存中启动 srv，返回连接到它的连接
+func dialBufconn(t *testing.T, srv *grpc.Server) *grpc.ClientConn {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("创建 gRPC 连接失败: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// 通过 bufconn 写入、读取、删除同一个key
+func TestGRPCRoundTrip(t *testing.T) {
+	opt := DefaultCacheOptions()
+	c := NewCache(&opt)
+	defer c.Close()
+	var client PeerGetter = newBufconnClient(t, c)
+	ctx := context.Background()
+	setter := client.(PeerSetter)
+	if err := setter.Set(ctx, "", "k", []byte("v")); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := client.Get(ctx, "", "k"); err != nil || string(b) != "v" {
+		t.Fatalf("读取到 %q %v", b, err)
+	}
+	if v, ok := c.Get(ctx, "k"); !ok || v.String() != "v" {
+		t.Fatal("写入应该保存在服务端的缓存中")
+	}
+	if err := client.(*GRPCClient).Delete(ctx, "", "k"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Get(ctx, "", "k"); !errors.Is(err, ErrPeerNotFound) {
+		t.Fatalf("删除之后应该返回 ErrPeerNotFound，实际为 %v", err)
+	}
+}
+
+// 远程 Group 的数据源返回 ErrNotFound 时，请求方应该得到 ErrPeerNotFound
+func TestGRPCGroupNotFound(t *testing.T) {
+	NewGroup("grpc-not-found", DefaultCacheOptions(), LoaderFunc(func(ctx context.Context, key string) ([]byte, error) {
+		return nil, ErrNotFound
+	}))
+	client := newBufconnClient(t, nil)
+	if _, err := client.Get(context.Background(), "grpc-not-found", "k"); !errors.Is(err, ErrPeerNotFound) {
+		t.Fatalf("应该返回 ErrPeerNotFound，实际为 %v", err)
+	}
+}
+
+// 协议版本不兼容时双方都得到明确的错误，而不是把请求当作普通的失败或者错误地解码
+func TestGRPCProtocolVersionMismatch(t *testing.T) {
+	opt := DefaultCacheOptions()
+	c := NewCache(&opt)
+	defer c.Close()
+	ctx := context.Background()
+
+	// 新的服务端要求最低版本 1：当前的客户端可以正常访问，不带版本的旧客户端被拒绝
+	server := NewGRPCServer(c)
+	server.SetMinProtocolVersion(protocolVersion)
+	srv := grpc.NewServer(grpc.UnaryInterceptor(server.UnaryInterceptor()))
+	server.Register(srv)
+	conn := dialBufconn(t, srv)
+	client := NewGRPCClient(conn)
+	client.SetMinProtocolVersion(protocolVersion)
+	if err := client.Set(ctx, "", "k", []byte("v")); err != nil {
+		t.Fatalf("版本兼容时写入失败: %v", err)
+	}
+	if b, err := client.Get(ctx, "", "k"); err != nil || string(b) != "v" {
+		t.Fatalf("版本兼容时读取到 %q %v", b, err)
+	}
+	_, err := cachepb.NewCacheClient(conn).Get(ctx, &cachepb.GetRequest{Key: "k"})
+	if status.Code(err) != codes.FailedPrecondition || !strings.Contains(status.Convert(err).Message(), ErrProtocolVersion.Error()) {
+		t.Fatalf("不带版本的旧客户端应该被拒绝，实际为 %v", err)
+	}
+
+	// 要求最低版本 1 的客户端访问不带版本的旧服务端时返回 ErrProtocolVersion，并且不会被重试
+	old := newBufconnClient(t, c)
+	old.SetMinProtocolVersion(protocolVersion)
+	if _, err := old.Get(ctx, "", "k"); !errors.Is(err, ErrProtocolVersion) {
+		t.Fatalf("访问旧服务端应该返回 ErrProtocolVersion，实际为 %v", err)
+	}
+	if err := old.Set(ctx, "", "k", []byte("v")); !errors.Is(err, ErrProtocolVersion) {
+		t.Fatalf("访问旧服务端应该返回 ErrProtocolVersion，实际为 %v", err)
+	}
+	// 默认兼容旧服务端
+	old.SetMinProtocolVersion(0)
+	if b, err := old.Get(ctx, "", "k"); err != nil || string(b) != "v" {
+		t.Fatalf("兼容旧服务端时读取到 %q %v", b, err)
+	}
+}
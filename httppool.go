@@ -0,0 +1,521 @@
+package main
+
+import (
+	"Distributed-Cache-Go/consistenthash"
+	"Distributed-Cache-Go/lru"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// 默认的缓存访问路径前缀
+	defaultBasePath = "/cache/"
+	// 健康检查的路径
+	healthPath = "/healthz"
+	// 列出当前所有key的调试路径
+	debugKeysPath = "/debug/keys"
+	// 一致性哈希环上每个节点默认的虚拟节点数
+	defaultReplicas = 50
+)
+
+// HTTPPool 为缓存提供 HTTP 访问能力，使其他节点可以远程读写当前节点的缓存
+// 同时它也实现了 PeerPicker，通过一致性哈希选择负责某个 key 的远程节点
+// 支持的路由：
+//
+//	GET    /cache/<key>  命中返回 200 和原始字节，未命中返回 404
+//	PUT    /cache/<key>  请求体即为缓存值，缓存已经关闭或者正在 Drain 时返回 503，值过大时返回 413
+//	DELETE /cache/<key>  删除对应的缓存
+//	GET    /healthz      健康检查，返回节点地址、条目数和命中率，不受并发限制
+//	GET    /debug/keys   以 JSON 数组返回所有未过期的key及其大小、剩余过期时间和淘汰顺序
+//
+// 带上 ?group=<name> 参数时访问的是对应 Group 的缓存，GET 未命中时会通过 Group 的数据源加载
+// 通过 SetMaxConcurrentRequests 限制并发请求数后，超出限制的请求直接返回 503，健康检查不受限制
+// 通过 SetAccessLog 可以按比例输出每个请求的访问日志
+// 节点之间的请求通过 X-Cache-Protocol 头协商协议版本，见 SetMinProtocolVersion
+//
+// HTTPPool 没有放在单独的 httppool 包中：它依赖 Cache 的未导出方法（例如 keys）和 Group 的注册表，
+// 而这些代码都在根目录的 main 包里，main 包不能被其他包导入。拆分之前需要先把缓存核心移到一个可以导入的包中。
+type HTTPPool struct {
+	self     string // 当前节点的地址，例如 "http://127.0.0.1:8001"
+	basePath string // 路由前缀
+	cache    *Cache
+	log      *zap.Logger
+	// 节点选择
+	mu          sync.Mutex             // 保护 peers 和 httpGetters
+	peers       *consistenthash.Map    // 一致性哈希环
+	httpGetters map[string]*httpGetter // 节点地址到对应客户端的映射
+	peerTimeout time.Duration          // 访问单个远程节点的超时时间，0 表示只受调用方 context 的限制
+	// 熔断器，breakerOpts 为空时不启用
+	breakerOpts *BreakerOptions
+	breakers    map[string]*circuitBreaker
+	// 失败重试，为空时不重试
+	retryOpts *RetryOptions
+	// 节点变化导致本地key的负责节点改变时的回调，为空时不计算
+	onRebalance func(movedKeys []string)
+	// 正在处理的请求的信号量，为空时不限制并发
+	inflight chan struct{}
+	// 访问日志的采样比例，0 表示关闭
+	accessLogRate float64
+	// 接受的最低协议版本，0 表示兼容没有版本头的旧节点
+	minProtocolVersion int
+}
+
+// 构造函数，cache 可以为空，此时只能通过 group 参数访问各个 Group
+func NewHTTPPool(self string, cache *Cache) *HTTPPool {
+	log := zap.NewNop()
+	if cache != nil {
+		log = cache.log
+	}
+	return &HTTPPool{
+		self:     self,
+		basePath: defaultBasePath,
+		cache:    cache,
+		log:      log,
+	}
+}
+
+// Set 设置集群中的所有节点（包括当前节点），节点地址格式与 self 一致
+func (p *HTTPPool) Set(peers ...string) {
+	p.UpdatePeers(peers)
+}
+
+// UpdatePeers 在运行时替换集群中的所有节点，用于节点的加入和离开
+// 新的哈希环在锁外构建完成后再一次性替换，并发的 PickPeer 只会看到旧的或者新的完整哈希环
+// 仍然存在的节点会保留原来的熔断器状态
+func (p *HTTPPool) UpdatePeers(peers []string) {
+	ring := consistenthash.New(defaultReplicas, nil)
+	ring.Add(peers...)
+
+	oldRing, onRebalance := p.swapPeers(ring, peers)
+	if onRebalance != nil && p.cache != nil {
+		if moved := p.movedKeys(oldRing, ring); len(moved) > 0 {
+			onRebalance(moved)
+		}
+	}
+}
+
+// swapPeers 替换哈希环和节点客户端，返回旧的哈希环以及当前注册的 OnRebalance 回调
+func (p *HTTPPool) swapPeers(ring *consistenthash.Map, peers []string) (*consistenthash.Map, func(movedKeys []string)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	oldRing := p.peers
+	getters := make(map[string]*httpGetter, len(peers))
+	for _, peer := range peers {
+		getters[peer] = &httpGetter{baseURL: peer + p.basePath, timeout: p.peerTimeout, minVersion: p.minProtocolVersion}
+	}
+	oldBreakers := p.breakers
+	p.peers = ring
+	p.httpGetters = getters
+	p.resetBreakers()
+	for peer, b := range oldBreakers {
+		if _, ok := p.breakers[peer]; ok {
+			p.breakers[peer] = b
+		}
+	}
+	return oldRing, p.onRebalance
+}
+
+// 返回哈希环由 oldRing 变为 newRing 之后，本地缓存中原来由当前节点负责、现在改由其他节点负责的key
+// 还没有设置过节点时认为所有key都由当前节点负责
+func (p *HTTPPool) movedKeys(oldRing, newRing *consistenthash.Map) []string {
+	owner := func(ring *consistenthash.Map, key string) string {
+		if ring == nil {
+			return p.self
+		}
+		if peer := ring.Get(key); peer != "" {
+			return peer
+		}
+		return p.self
+	}
+	var moved []string
+	for _, key := range p.cache.keys() {
+		if owner(oldRing, key) == p.self && owner(newRing, key) != p.self {
+			moved = append(moved, key)
+		}
+	}
+	return moved
+}
+
+// SetOnRebalance 设置节点变化时的回调，UpdatePeers（以及 Set）替换哈希环之后，
+// 如果绑定的缓存中有key的负责节点从当前节点变成了其他节点，就在锁外以这些key调用 fn，应用可以借此迁移或者删除这些数据
+// 只会检查构造 HTTPPool 时传入的缓存，不包括通过 group 参数访问的 Group
+func (p *HTTPPool) SetOnRebalance(fn func(movedKeys []string)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.onRebalance = fn
+}
+
+// SetCircuitBreaker 为每个远程节点启用熔断器，连续失败的节点在冷却期间会直接返回 ErrCircuitOpen
+func (p *HTTPPool) SetCircuitBreaker(opts BreakerOptions) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.breakerOpts = &opts
+	p.resetBreakers()
+}
+
+// 为当前的所有节点重新创建熔断器，调用此方法前必须持有锁
+func (p *HTTPPool) resetBreakers() {
+	if p.breakerOpts == nil {
+		return
+	}
+	p.breakers = make(map[string]*circuitBreaker, len(p.httpGetters))
+	for peer := range p.httpGetters {
+		p.breakers[peer] = newCircuitBreaker(*p.breakerOpts)
+	}
+}
+
+// SetRetry 访问远程节点失败时按照指数退避重试，受调用方 context 截止时间的限制
+// 同时启用熔断器时，一次带重试的调用在熔断器看来只算一次请求
+func (p *HTTPPool) SetRetry(opts RetryOptions) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	opts = opts.withDefault()
+	p.retryOpts = &opts
+}
+
+// 返回访问某个节点的客户端，启用重试和熔断器时依次包装，调用此方法前必须持有锁
+func (p *HTTPPool) getter(peer string) PeerGetter {
+	var getter PeerGetter = p.httpGetters[peer]
+	if p.retryOpts != nil {
+		getter = &retryPeer{peer: getter, opts: *p.retryOpts}
+	}
+	if b, ok := p.breakers[peer]; ok {
+		return &breakerPeer{peer: getter, breaker: b}
+	}
+	return getter
+}
+
+// SetPeerTimeout 设置访问单个远程节点的超时时间，与调用方 context 的截止时间取较早的一个
+func (p *HTTPPool) SetPeerTimeout(timeout time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.peerTimeout = timeout
+	// 正在使用中的 httpGetter 可能被其他协程读取，所以替换成新的对象而不是直接修改
+	for peer, getter := range p.httpGetters {
+		p.httpGetters[peer] = &httpGetter{baseURL: getter.baseURL, timeout: timeout, minVersion: getter.minVersion}
+	}
+}
+
+// SetMaxConcurrentRequests 限制同时处理的请求数，n 小于等于 0 时不限制
+// 达到限制时新的请求不会排队，而是立即返回 503 和 Retry-After，避免突发流量下积压的请求耗尽内存
+// 修改限制之前已经在处理的请求不计入新的限制
+func (p *HTTPPool) SetMaxConcurrentRequests(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if n <= 0 {
+		p.inflight = nil
+		return
+	}
+	p.inflight = make(chan struct{}, n)
+}
+
+// 尝试占用一个并发请求的名额，已经达到限制时返回 false，成功时返回释放名额的函数
+func (p *HTTPPool) acquireRequest() (func(), bool) {
+	p.mu.Lock()
+	sem := p.inflight
+	p.mu.Unlock()
+	if sem == nil {
+		return func() {}, true
+	}
+	select {
+	case sem <- struct{}{}:
+		return func() { <-sem }, true
+	default:
+		return nil, false
+	}
+}
+
+// PickPeer 实现 PeerPicker 接口，key 由当前节点负责时返回 false
+func (p *HTTPPool) PickPeer(key string) (PeerGetter, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.peers == nil {
+		return nil, false
+	}
+	if peer := p.peers.Get(key); peer != "" && peer != p.self {
+		return p.getter(peer), true
+	}
+	return nil, false
+}
+
+// PickReplicas 实现 ReplicaPicker 接口
+func (p *HTTPPool) PickReplicas(key string, n int) ([]PeerGetter, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.peers == nil {
+		return nil, false
+	}
+	var peers []PeerGetter
+	self := false
+	for _, peer := range p.peers.GetN(key, n) {
+		if peer == p.self {
+			self = true
+			continue
+		}
+		peers = append(peers, p.getter(peer))
+	}
+	return peers, self
+}
+
+// serve 处理请求，由 ServeHTTP 调用
+func (p *HTTPPool) serve(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == healthPath {
+		p.serveHealth(w, r)
+		return
+	}
+	release, ok := p.acquireRequest()
+	if !ok {
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "服务繁忙，请稍后重试", http.StatusServiceUnavailable)
+		return
+	}
+	defer release()
+	if r.URL.Path == debugKeysPath {
+		p.serveDebugKeys(w, r)
+		return
+	}
+	// 首先判断请求路径是否以路由前缀开头
+	if !strings.HasPrefix(r.URL.Path, p.basePath) {
+		http.NotFound(w, r)
+		return
+	}
+	key := strings.TrimPrefix(r.URL.Path, p.basePath)
+	if key == "" {
+		http.Error(w, "key 不能为空", http.StatusBadRequest)
+		return
+	}
+	if !p.negotiateProtocol(w, r) {
+		return
+	}
+
+	// 根据 group 参数选择要访问的缓存
+	cache := p.cache
+	var group *Group
+	if name := r.URL.Query().Get("group"); name != "" {
+		group = GetGroup(name)
+		if group == nil {
+			http.Error(w, "group 不存在: "+name, http.StatusNotFound)
+			return
+		}
+		cache = group.cache
+	}
+	if cache == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		var value ByteView
+		if group != nil {
+			// Group 未命中时会加载数据，所以先检查本地缓存中是否已经存在
+			markHit(w, cache.contains(key))
+			var err error
+			value, err = group.Get(r.Context(), key)
+			if errors.Is(err, ErrNotFound) {
+				// 数据源中不存在该key，返回 404 让请求方得到 ErrPeerNotFound，而不是把当前节点当作不可用
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		} else {
+			var ok bool
+			value, ok = cache.Get(r.Context(), key)
+			markHit(w, ok)
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		_, err := w.Write(value.ByteSlice())
+		if err != nil {
+			p.log.Error("写入响应失败", zap.String("key", key), zap.Error(err))
+		}
+	case http.MethodPut:
+		value, err := ReadByteView(r.Body)
+		if err != nil {
+			http.Error(w, "读取请求体失败", http.StatusBadRequest)
+			return
+		}
+		if err := cache.Add(key, value); err != nil {
+			http.Error(w, err.Error(), addErrorStatus(err))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	case http.MethodDelete:
+		cache.Delete(key)
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.Header().Set("Allow", "GET, PUT, DELETE")
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+	}
+}
+
+// 写入失败时返回的状态码：缓存已经关闭或者正在 Drain 时为 503，值过大时为 413
+func addErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrCacheClosed):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, lru.ErrValueTooLarge):
+		return http.StatusRequestEntityTooLarge
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// 健康检查的响应
+type healthResponse struct {
+	Node     string  `json:"node"`
+	Entries  int     `json:"entries"`
+	HitRatio float64 `json:"hit_ratio"`
+}
+
+// 处理健康检查请求，没有绑定缓存时条目数和命中率为 0
+func (p *HTTPPool) serveHealth(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+	resp := healthResponse{Node: p.self}
+	if p.cache != nil {
+		stats := p.cache.Stats()
+		resp.Entries = stats.Entries
+		resp.HitRatio = stats.HitRatio
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		p.log.Error("写入健康检查响应失败", zap.Error(err))
+	}
+}
+
+// /debug/keys 响应中的单个key
+type keyInfoResponse struct {
+	Key   string `json:"key"`
+	Size  int64  `json:"size"`
+	TTLMs int64  `json:"ttl_ms"` // 剩余的过期时间（毫秒），0 表示永不过期
+	Rank  int    `json:"rank"`
+}
+
+// 处理 /debug/keys 请求，带上 ?group=<name> 参数时列出对应 Group 的key
+func (p *HTTPPool) serveDebugKeys(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+	cache := p.cache
+	if name := r.URL.Query().Get("group"); name != "" {
+		group := GetGroup(name)
+		if group == nil {
+			http.Error(w, "group 不存在: "+name, http.StatusNotFound)
+			return
+		}
+		cache = group.cache
+	}
+	if cache == nil {
+		http.NotFound(w, r)
+		return
+	}
+	keys := cache.DumpKeys()
+	resp := make([]keyInfoResponse, len(keys))
+	for i, k := range keys {
+		resp[i] = keyInfoResponse{Key: k.Key, Size: k.Size, TTLMs: k.TTL.Milliseconds(), Rank: k.Rank}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		p.log.Error("写入 /debug/keys 响应失败", zap.Error(err))
+	}
+}
+
+// httpGetter 通过 HTTP 访问远程节点，实现了 PeerGetter 接口
+type httpGetter struct {
+	baseURL    string        // 远程节点的地址加上路由前缀，例如 "http://127.0.0.1:8002/cache/"
+	timeout    time.Duration // 单次请求的超时时间，0 表示不额外限制
+	minVersion int           // 接受的最低协议版本
+}
+
+// 在调用方 context 的基础上加上单次请求的超时时间
+func (h *httpGetter) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if h.timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, h.timeout)
+}
+
+// 拼接访问某个 key 的地址
+func (h *httpGetter) url(group string, key string) string {
+	u := h.baseURL + url.PathEscape(key)
+	if group != "" {
+		u += "?group=" + url.QueryEscape(group)
+	}
+	return u
+}
+
+// Get 从远程节点获取数据，远程节点未命中时返回 ErrPeerNotFound
+func (h *httpGetter) Get(ctx context.Context, group string, key string) ([]byte, error) {
+	ctx, cancel := h.withTimeout(ctx)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.url(group, key), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(protocolHeader, strconv.Itoa(protocolVersion))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := h.checkProtocol(resp); err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrPeerNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("远程节点返回错误状态码:%v", resp.Status)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取远程节点响应失败:%v", err)
+	}
+	return body, nil
+}
+
+// Set 向远程节点写入数据，实现了 PeerSetter 接口
+func (h *httpGetter) Set(ctx context.Context, group string, key string, value []byte) error {
+	ctx, cancel := h.withTimeout(ctx)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, h.url(group, key), bytes.NewReader(value))
+	if err != nil {
+		return err
+	}
+	req.Header.Set(protocolHeader, strconv.Itoa(protocolVersion))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := h.checkProtocol(resp); err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("远程节点返回错误状态码:%v", resp.Status)
+	}
+	return nil
+}
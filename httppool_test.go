@@ -0,0 +1,526 @@
+package main
+
+import (
+	"Distributed-Cache-Go/consistenthash"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 远程 Group 的数据源返回 ErrNotFound 时，请求方应该得到 ErrPeerNotFound，而不是把该节点当作不可用
+func TestHTTPGroupNotFound(t *testing.T) {
+	NewGroup("http-not-found", DefaultCacheOptions(), LoaderFunc(func(ctx context.Context, key string) ([]byte, error) {
+		return nil, ErrNotFound
+	}))
+	srv := httptest.NewServer(NewHTTPPool("self", nil))
+	defer srv.Close()
+	getter := &httpGetter{baseURL: srv.URL + defaultBasePath}
+	if _, err := getter.Get(context.Background(), "http-not-found", "k"); !errors.Is(err, ErrPeerNotFound) {
+		t.Fatalf("应该返回 ErrPeerNotFound，实际为 %v", err)
+	}
+}
+
+// 并发路由key的同时反复更新节点列表，路由结果总是完整的，使用 go test -race 运行可以检查数据竞争
+func TestUpdatePeersConcurrent(t *testing.T) {
+	p := NewHTTPPool("http://a", nil)
+	p.SetCircuitBreaker(BreakerOptions{})
+	p.Set("http://a", "http://b")
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; ; j++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				key := strconv.Itoa(j)
+				if g, ok := p.PickPeer(key); ok && g == nil {
+					t.Error("PickPeer 返回了空的 PeerGetter")
+				}
+				peers, _ := p.PickReplicas(key, 2)
+				for _, g := range peers {
+					if g == nil {
+						t.Error("PickReplicas 返回了空的 PeerGetter")
+					}
+				}
+			}
+		}()
+	}
+	for i := 0; i < 200; i++ {
+		if i%2 == 0 {
+			p.UpdatePeers([]string{"http://a", "http://b", "http://c"})
+		} else {
+			p.UpdatePeers([]string{"http://a", "http://c"})
+		}
+	}
+	close(stop)
+	wg.Wait()
+}
+
+// PUT 写入失败时返回对应的状态码，而不是总是返回 200
+func TestHTTPPutErrorStatus(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		close bool
+		want  int
+	}{
+		{"写入成功", "v", false, http.StatusOK},
+		{"值超过 MaxValueBytes", strings.Repeat("v", 100), false, http.StatusRequestEntityTooLarge},
+		{"缓存已经关闭", "v", true, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opt := DefaultCacheOptions()
+			opt.MaxValueBytes = 10
+			c := NewCache(&opt)
+			defer c.Close()
+			if tt.close {
+				c.Close()
+			}
+			srv := httptest.NewServer(NewHTTPPool("self", c))
+			defer srv.Close()
+			req, _ := http.NewRequest(http.MethodPut, srv.URL+defaultBasePath+"k", strings.NewReader(tt.value))
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.want {
+				t.Fatalf("PUT 返回 %d，期望 %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
+
+// HTTP 协议版本协商：旧节点和新节点可以互相访问，版本不兼容时得到 ErrProtocolVersion，而不是把错误信息当作缓存值
+func TestHTTPProtocolVersionMismatch(t *testing.T) {
+	opt := DefaultCacheOptions()
+	c := NewCache(&opt)
+	defer c.Close()
+	c.AddBytes("k", []byte("v"))
+	pool := NewHTTPPool("self", c)
+	srv := httptest.NewServer(pool)
+	defer srv.Close()
+	ctx := context.Background()
+	if b, err := (&httpGetter{baseURL: srv.URL + defaultBasePath}).Get(ctx, "", "k"); err != nil || string(b) != "v" {
+		t.Fatalf("读取到 %q %v", b, err)
+	}
+	get := func(version string) *http.Response {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+defaultBasePath+"k", nil)
+		if version != "" {
+			req.Header.Set(protocolHeader, version)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+	// 不带版本头的旧客户端默认可以访问，回复中也不带版本头
+	if resp := get(""); resp.StatusCode != http.StatusOK || resp.Header.Get(protocolHeader) != "" {
+		t.Fatalf("旧客户端得到 %d %q", resp.StatusCode, resp.Header.Get(protocolHeader))
+	}
+	// 更新的客户端按照当前节点的版本得到回复
+	if resp := get("7"); resp.StatusCode != http.StatusOK || resp.Header.Get(protocolHeader) != strconv.Itoa(protocolVersion) {
+		t.Fatalf("更新的客户端得到 %d %q", resp.StatusCode, resp.Header.Get(protocolHeader))
+	}
+	pool.SetMinProtocolVersion(protocolVersion)
+	if resp := get(""); resp.StatusCode != http.StatusBadRequest || resp.Header.Get(protocolHeader) != strconv.Itoa(protocolVersion) {
+		t.Fatalf("提高最低版本之后旧客户端得到 %d", resp.StatusCode)
+	}
+
+	// 不带版本头的旧服务端：默认兼容，要求最低版本时返回 ErrProtocolVersion
+	legacy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("old")) }))
+	defer legacy.Close()
+	if b, err := (&httpGetter{baseURL: legacy.URL + defaultBasePath}).Get(ctx, "", "k"); err != nil || string(b) != "old" {
+		t.Fatalf("兼容旧服务端时读取到 %q %v", b, err)
+	}
+	if _, err := (&httpGetter{baseURL: legacy.URL + defaultBasePath, minVersion: 1}).Get(ctx, "", "k"); !errors.Is(err, ErrProtocolVersion) {
+		t.Fatalf("访问旧服务端应该返回 ErrProtocolVersion，实际为 %v", err)
+	}
+	// 版本更高的服务端：返回 ErrProtocolVersion 并且不会被重试
+	future := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(protocolHeader, "9")
+		w.Write([]byte("x"))
+	}))
+	defer future.Close()
+	err := (&httpGetter{baseURL: future.URL + defaultBasePath}).Set(ctx, "", "k", []byte("x"))
+	if !errors.Is(err, ErrProtocolVersion) || isRetryable(ctx, err) {
+		t.Fatalf("访问版本更高的服务端应该返回不可重试的 ErrProtocolVersion，实际为 %v", err)
+	}
+}
+
+// 通过 httptest 依次 PUT、GET、DELETE 同一个key，未命中和删除之后的 GET 返回 404
+func TestHTTPRoundTrip(t *testing.T) {
+	opt := DefaultCacheOptions()
+	c := NewCache(&opt)
+	defer c.Close()
+	srv := httptest.NewServer(NewHTTPPool("self", c))
+	defer srv.Close()
+	do := func(method, key, body string) (int, string) {
+		req, _ := http.NewRequest(method, srv.URL+defaultBasePath+key, strings.NewReader(body))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(b)
+	}
+	tests := []struct {
+		method, key, body string
+		wantCode          int
+		wantBody          string
+	}{
+		{http.MethodGet, "missing", "", http.StatusNotFound, ""},
+		{http.MethodPut, "k", "hello", http.StatusOK, ""},
+		{http.MethodGet, "k", "", http.StatusOK, "hello"},
+		{http.MethodPut, "k", "world", http.StatusOK, ""},
+		{http.MethodGet, "k", "", http.StatusOK, "world"},
+		{http.MethodDelete, "k", "", http.StatusOK, ""},
+		{http.MethodGet, "k", "", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		code, body := do(tt.method, tt.key, tt.body)
+		if code != tt.wantCode || (tt.wantBody != "" && body != tt.wantBody) {
+			t.Fatalf("%s %s 返回 %d %q，期望 %d %q", tt.method, tt.key, code, body, tt.wantCode, tt.wantBody)
+		}
+	}
+	if _, ok := c.Get(context.Background(), "k"); ok {
+		t.Fatal("DELETE 之后本地缓存中不应该还有 k")
+	}
+}
+
+// 注册了 PeerPicker 之后，由远程节点负责的key通过该节点的 PeerGetter 获取，其余key在本地加载
+func TestRegisterPeersFetchesFromOwner(t *testing.T) {
+	optB := DefaultCacheOptions()
+	b := NewCache(&optB)
+	defer b.Close()
+	srvB := httptest.NewServer(NewHTTPPool("B", b))
+	defer srvB.Close()
+
+	optA := DefaultCacheOptions()
+	a := NewCache(&optA)
+	defer a.Close()
+	poolA := NewHTTPPool("http://a", a)
+	poolA.Set("http://a", srvB.URL)
+	a.RegisterPeers(poolA)
+	local := func(ctx context.Context, key string) ([]byte, error) {
+		return []byte("local"), nil
+	}
+
+	remote, self := 0, 0
+	for i := 0; i < 50; i++ {
+		key := "k" + strconv.Itoa(i)
+		b.AddBytes(key, []byte("remote"))
+		_, owned := poolA.PickPeer(key)
+		want := "local"
+		if owned {
+			want = "remote"
+			remote++
+		} else {
+			self++
+		}
+		v, err := a.GetOrLoad(context.Background(), key, local)
+		if err != nil || v.String() != want {
+			t.Fatalf("%s 返回 %q %v，期望 %q", key, v.String(), err, want)
+		}
+	}
+	if remote == 0 || self == 0 {
+		t.Fatalf("50 个key应该分布在两个节点上，远程 %d 个，本地 %d 个", remote, self)
+	}
+}
+
+// 远程节点很慢时，请求在单个节点的超时时间和调用方截止时间中较早的那一个返回超时错误
+func TestHTTPPeerTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(5 * time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	tests := []struct {
+		name        string
+		peerTimeout time.Duration
+		ctxTimeout  time.Duration
+	}{
+		{"只设置节点超时", 100 * time.Millisecond, 0},
+		{"只有调用方截止时间", 0, 100 * time.Millisecond},
+		{"调用方截止时间更早", 5 * time.Second, 100 * time.Millisecond},
+		{"节点超时更早", 100 * time.Millisecond, 5 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool := NewHTTPPool("self", nil)
+			pool.Set(srv.URL)
+			pool.SetPeerTimeout(tt.peerTimeout)
+			peer, ok := pool.PickPeer("k")
+			if !ok {
+				t.Fatal("应该选中远程节点")
+			}
+			ctx := context.Background()
+			if tt.ctxTimeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.ctxTimeout)
+				defer cancel()
+			}
+			start := time.Now()
+			_, err := peer.Get(ctx, "", "k")
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("应该返回超时错误，实际为 %v", err)
+			}
+			if d := time.Since(start); d > time.Second {
+				t.Fatalf("请求在 %v 之后才返回", d)
+			}
+		})
+	}
+}
+
+// /healthz 返回 200 和包含节点地址、条目数、命中率的 JSON，没有绑定缓存时条目数和命中率为 0
+func TestHTTPHealthz(t *testing.T) {
+	opt := DefaultCacheOptions()
+	c := NewCache(&opt)
+	defer c.Close()
+	c.AddBytes("a", []byte("1"))
+	c.Get(context.Background(), "a")
+	c.Get(context.Background(), "b")
+
+	tests := []struct {
+		name   string
+		cache  *Cache
+		method string
+		status int
+		want   map[string]interface{}
+	}{
+		{"绑定了缓存", c, http.MethodGet, http.StatusOK, map[string]interface{}{"node": "node1", "entries": 1.0, "hit_ratio": 0.5}},
+		{"没有绑定缓存", nil, http.MethodGet, http.StatusOK, map[string]interface{}{"node": "node1", "entries": 0.0, "hit_ratio": 0.0}},
+		{"不支持的请求方法", c, http.MethodPost, http.StatusMethodNotAllowed, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(NewHTTPPool("node1", tt.cache))
+			defer srv.Close()
+			req, _ := http.NewRequest(tt.method, srv.URL+healthPath, nil)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tt.status {
+				t.Fatalf("状态码为 %d，期望 %d", resp.StatusCode, tt.status)
+			}
+			if tt.want == nil {
+				return
+			}
+			if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+				t.Fatalf("Content-Type 为 %q", ct)
+			}
+			var got map[string]interface{}
+			if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("响应为 %v，期望 %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// /debug/keys 以 JSON 数组返回所有key，顺序与淘汰顺序一致；带上 group 参数时列出对应 Group 的key
+func TestHTTPDebugKeys(t *testing.T) {
+	opt := DefaultCacheOptions()
+	c := NewCache(&opt)
+	defer c.Close()
+	c.AddBytes("a", []byte("1"))
+	c.AddBytes("b", []byte("1"))
+	c.Get(context.Background(), "a")
+	NewGroup("debug-keys", DefaultCacheOptions(), LoaderFunc(func(ctx context.Context, key string) ([]byte, error) {
+		return []byte("v"), nil
+	})).Get(context.Background(), "g")
+	p := NewHTTPPool("http://x", c)
+
+	tests := []struct {
+		path   string
+		status int
+		want   []string
+	}{
+		{debugKeysPath, http.StatusOK, []string{"b", "a"}},
+		{debugKeysPath + "?group=debug-keys", http.StatusOK, []string{"g"}},
+		{debugKeysPath + "?group=none", http.StatusNotFound, nil},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if rec.Code != tt.status {
+			t.Fatalf("%s 返回 %d，期望 %d", tt.path, rec.Code, tt.status)
+		}
+		if tt.want == nil {
+			continue
+		}
+		var out []keyInfoResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+			t.Fatalf("%s 的响应不是 JSON: %v", tt.path, err)
+		}
+		var keys []string
+		for i, k := range out {
+			if k.Rank != i {
+				t.Fatalf("%s 第 %d 个key的 Rank 为 %d", tt.path, i, k.Rank)
+			}
+			keys = append(keys, k.Key)
+		}
+		if !reflect.DeepEqual(keys, tt.want) {
+			t.Fatalf("%s 返回的key为 %v，期望 %v", tt.path, keys, tt.want)
+		}
+	}
+}
+
+// 增加节点之后，本地缓存中改由新节点负责的key通过 OnRebalance 报告；负责节点没有离开当前节点时不会触发
+func TestOnRebalance(t *testing.T) {
+	opt := DefaultCacheOptions()
+	c := NewCache(&opt)
+	defer c.Close()
+	p := NewHTTPPool("http://a", c)
+	var calls int
+	var got []string
+	p.SetOnRebalance(func(moved []string) {
+		calls++
+		got = append([]string(nil), moved...)
+	})
+	p.Set("http://a", "http://b")
+	if calls != 0 {
+		t.Fatal("缓存为空时不应该触发 OnRebalance")
+	}
+
+	ring := func(peers ...string) *consistenthash.Map {
+		m := consistenthash.New(defaultReplicas, nil)
+		m.Add(peers...)
+		return m
+	}
+	before, after := ring("http://a", "http://b"), ring("http://a", "http://b", "http://c")
+	var want []string
+	for i := 0; i < 200; i++ {
+		key := "k" + strconv.Itoa(i)
+		if before.Get(key) != "http://a" {
+			continue
+		}
+		c.AddBytes(key, []byte("v"))
+		if after.Get(key) != "http://a" {
+			want = append(want, key)
+		}
+	}
+	if len(want) == 0 {
+		t.Fatal("增加节点之后应该有key离开当前节点")
+	}
+
+	tests := []struct {
+		name      string
+		peers     []string
+		wantCalls int
+		want      []string
+	}{
+		{"增加节点", []string{"http://a", "http://b", "http://c"}, 1, want},
+		{"节点不变", []string{"http://a", "http://b", "http://c"}, 1, want},
+		{"删除节点，key回到当前节点", []string{"http://a", "http://b"}, 1, want},
+	}
+	for _, tt := range tests {
+		p.Set(tt.peers...)
+		sort.Strings(got)
+		sort.Strings(tt.want)
+		if calls != tt.wantCalls || !reflect.DeepEqual(got, tt.want) {
+			t.Fatalf("%s: OnRebalance 调用了 %d 次，收到 %v，期望 %v", tt.name, calls, got, tt.want)
+		}
+	}
+}
+
+// 并发请求数达到 SetMaxConcurrentRequests 的限制时，新的请求立即返回 503 和 Retry-After，健康检查不受限制；名额释放之后恢复正常
+func TestHTTPMaxConcurrentRequests(t *testing.T) {
+	const limit = 3
+	block := make(chan struct{})
+	NewGroup("max-concurrent", DefaultCacheOptions(), LoaderFunc(func(ctx context.Context, key string) ([]byte, error) {
+		<-block
+		return []byte("v"), nil
+	}))
+	opt := DefaultCacheOptions()
+	c := NewCache(&opt)
+	defer c.Close()
+	c.AddBytes("k", []byte("x"))
+	pool := NewHTTPPool("self", c)
+	pool.SetMaxConcurrentRequests(limit)
+	srv := httptest.NewServer(pool)
+	defer srv.Close()
+
+	var wg sync.WaitGroup
+	codes := make(chan int, limit)
+	for i := 0; i < limit; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resp, err := http.Get(srv.URL + defaultBasePath + "slow" + strconv.Itoa(i) + "?group=max-concurrent")
+			if err != nil {
+				codes <- 0
+				return
+			}
+			resp.Body.Close()
+			codes <- resp.StatusCode
+		}(i)
+	}
+	// 等待慢请求占满所有名额
+	deadline := time.Now().Add(time.Second)
+	for len(pool.inflight) < limit {
+		if time.Now().After(deadline) {
+			t.Fatal("慢请求没有占满并发名额")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	tests := []struct {
+		path       string
+		status     int
+		retryAfter bool
+	}{
+		{defaultBasePath + "k", http.StatusServiceUnavailable, true},
+		{debugKeysPath, http.StatusServiceUnavailable, true},
+		{healthPath, http.StatusOK, false},
+	}
+	for _, tt := range tests {
+		resp, err := http.Get(srv.URL + tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.status || (resp.Header.Get("Retry-After") != "") != tt.retryAfter {
+			t.Fatalf("%s 返回 %d，Retry-After 为 %q", tt.path, resp.StatusCode, resp.Header.Get("Retry-After"))
+		}
+	}
+
+	close(block)
+	wg.Wait()
+	close(codes)
+	for code := range codes {
+		if code != http.StatusOK {
+			t.Fatalf("占用名额的请求返回 %d", code)
+		}
+	}
+	resp, err := http.Get(srv.URL + defaultBasePath + "k")
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("名额释放之后请求失败: %v", err)
+	}
+	resp.Body.Close()
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// AddJSON 将 v 编码为 JSON 后写入缓存，编码失败时返回错误并且不会写入，写入失败时返回 Add 的错误
+func (c *Cache) AddJSON(key string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("AddJSON 编码失败:%v", err.Error())
+	}
+	return c.Add(key, ByteView{b: b})
+}
+
+// GetJSON 查找缓存并将 JSON 解码到 dst 中，未命中时返回 false 和空错误
+// 命中但解码失败时返回 true 和解码的错误，而不是当作未命中处理
+func (c *Cache) GetJSON(ctx context.Context, key string, dst interface{}) (bool, error) {
+	value, ok := c.Get(ctx, key)
+	if !ok {
+		return false, nil
+	}
+	if err := json.Unmarshal(value.b, dst); err != nil {
+		return true, fmt.Errorf("GetJSON 解码失败:%w", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+type jsonUser struct {
+	Name string   `json:"name"`
+	Tags []string `json:"tags"`
+}
+
+// AddJSON/GetJSON 往返结构体；命中但解码失败时返回 true 和错误，未命中时返回 false 和空错误
+func TestAddGetJSON(t *testing.T) {
+	opt := DefaultCacheOptions()
+	c := NewCache(&opt)
+	defer c.Close()
+	ctx := context.Background()
+	if err := c.AddJSON("u", jsonUser{Name: "张三", Tags: []string{"a"}}); err != nil {
+		t.Fatal(err)
+	}
+	c.AddBytes("bad", []byte("{"))
+
+	tests := []struct {
+		name    string
+		key     string
+		wantOK  bool
+		wantErr bool
+		want    jsonUser
+	}{
+		{"命中", "u", true, false, jsonUser{Name: "张三", Tags: []string{"a"}}},
+		{"解码失败", "bad", true, true, jsonUser{}},
+		{"未命中", "none", false, false, jsonUser{}},
+	}
+	for _, tt := range tests {
+		var got jsonUser
+		ok, err := c.GetJSON(ctx, tt.key, &got)
+		if ok != tt.wantOK || (err != nil) != tt.wantErr {
+			t.Fatalf("%s: GetJSON 返回 %v %v", tt.name, ok, err)
+		}
+		if !tt.wantErr && (got.Name != tt.want.Name || len(got.Tags) != len(tt.want.Tags)) {
+			t.Fatalf("%s: 解码得到 %+v，期望 %+v", tt.name, got, tt.want)
+		}
+	}
+	var syntaxErr *json.SyntaxError
+	if _, err := c.GetJSON(ctx, "bad", &jsonUser{}); !errors.As(err, &syntaxErr) {
+		t.Fatalf("解码错误应该保留 json 的错误类型，实际为 %v", err)
+	}
+
+	if err := c.AddJSON("f", func() {}); err == nil {
+		t.Fatal("无法编码的值应该返回错误")
+	}
+	if _, ok := c.Get(ctx, "f"); ok {
+		t.Fatal("编码失败时不应该写入缓存")
+	}
+}
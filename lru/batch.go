@@ -0,0 +1,166 @@
+package lru
+
+import (
+	"Distributed-Cache-Go/metrics"
+	"go.uber.org/zap"
+	"sync/atomic"
+)
+
+// BatchStore 支持批量读写的 Store，整个批次只获取一次锁（Sharded 类型每个分片获取一次），LRU 和 Sharded 类型实现了该接口
+type BatchStore interface {
+	// GetMany 批量查找，效果与对每个key调用 FindCache 相同，返回的结果中只包含命中的key
+	GetMany(keys []string) map[string]Value
+	// AddMany 批量新增/更新，过期时间使用默认的 defaultTTL，返回写入失败的key及其错误，全部成功时返回空
+	AddMany(items map[string]Value) map[string]error
+}
+
+// GetMany 底层存储实现了 BatchStore 时批量查找，否则逐个调用 FindCache
+func GetMany(s Store, keys []string) map[string]Value {
+	if b, ok := s.(BatchStore); ok {
+		return b.GetMany(keys)
+	}
+	result := make(map[string]Value, len(keys))
+	for _, key := range keys {
+		if value, ok := s.FindCache(key); ok {
+			result[key] = value
+		}
+	}
+	return result
+}
+
+// AddMany 底层存储实现了 BatchStore 时批量写入，否则逐个调用 AddAndUpdateCache
+func AddMany(s Store, items map[string]Value) map[string]error {
+	if b, ok := s.(BatchStore); ok {
+		return b.AddMany(items)
+	}
+	var errs map[string]error
+	for key, value := range items {
+		if err := s.AddAndUpdateCache(key, value); err != nil {
+			if errs == nil {
+				errs = make(map[string]error)
+			}
+			errs[key] = err
+		}
+	}
+	return errs
+}
+
+// GetMany 实现 BatchStore，在一次写锁中完成所有查找、过期删除和链表移动
+// 命中统计、热点key统计和滑动过期的处理与 FindCache 一致
+func (c *LruCache) GetMany(keys []string) map[string]Value {
+	result := make(map[string]Value, len(keys))
+	if len(keys) == 0 {
+		return result
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.clk.Now()
+	for _, key := range keys {
+		elem, ok := c.items[key]
+		if !ok {
+			atomic.AddInt64(&c.misses, 1)
+			continue
+		}
+		if t, ok := c.expires[key]; ok && now.After(t) {
+			atomic.AddInt64(&c.misses, 1)
+			if err := c.removeCache(elem, ReasonExpired); err != nil {
+				c.log.Error("GetMany 删除过期数据报错", zap.String("key", key), zap.Error(err))
+			}
+			metrics.Evictions.Inc()
+			continue
+		}
+		entry := elem.Value.(*LruEntry)
+		atomic.AddInt64(&c.hits, 1)
+		atomic.AddInt64(&entry.hits, 1)
+		atomic.StoreInt64(&entry.lastAccess, now.UnixNano())
+		if c.samples > 0 {
+			atomic.StoreInt64(&entry.accessed, c.tick())
+		} else {
+			c.list.MoveToBack(elem)
+		}
+		if ttl, ok := c.ttls[key]; ok && c.sliding {
+			c.createExpires(key, ttl)
+		}
+		if c.hotKeys != nil {
+			c.hotKeys.touch(key)
+		}
+		result[key] = entry.value
+	}
+	return result
+}
+
+// AddMany 实现 BatchStore，超过 MaxValueBytes 的键值对在加锁之前被拒绝，其余的在一次写锁中写入
+func (c *LruCache) AddMany(items map[string]Value) map[string]error {
+	var errs map[string]error
+	fail := func(key string, err error) {
+		if errs == nil {
+			errs = make(map[string]error)
+		}
+		errs[key] = err
+	}
+	valid := make(map[string]Value, len(items))
+	for key, value := range items {
+		if value == nil {
+			continue
+		}
+		if err := checkValueSize(key, c.sizeOf(key, value), c.maxValueBytes); err != nil {
+			fail(key, err)
+			continue
+		}
+		valid[key] = value
+	}
+	if len(valid) == 0 {
+		return errs
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, value := range valid {
+		if err := c.set(key, value, c.defaultTTL); err != nil {
+			fail(key, err)
+		}
+	}
+	return errs
+}
+
+// 把key按照所在的分片分组
+func (c *ShardedCache) groupKeys(keys []string) map[*LruCache][]string {
+	groups := make(map[*LruCache][]string)
+	for _, key := range keys {
+		shard := c.shard(key)
+		groups[shard] = append(groups[shard], key)
+	}
+	return groups
+}
+
+// GetMany 实现 BatchStore，按分片分组之后每个分片只获取一次锁
+func (c *ShardedCache) GetMany(keys []string) map[string]Value {
+	result := make(map[string]Value, len(keys))
+	for shard, group := range c.groupKeys(keys) {
+		for key, value := range shard.GetMany(group) {
+			result[key] = value
+		}
+	}
+	return result
+}
+
+// AddMany 实现 BatchStore，按分片分组之后每个分片只获取一次锁
+func (c *ShardedCache) AddMany(items map[string]Value) map[string]error {
+	groups := make(map[*LruCache]map[string]Value)
+	for key, value := range items {
+		shard := c.shard(key)
+		if groups[shard] == nil {
+			groups[shard] = make(map[string]Value)
+		}
+		groups[shard][key] = value
+	}
+	var errs map[string]error
+	for shard, group := range groups {
+		for key, err := range shard.AddMany(group) {
+			if errs == nil {
+				errs = make(map[string]error)
+			}
+			errs[key] = err
+		}
+	}
+	return errs
+}
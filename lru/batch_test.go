@@ -0,0 +1,74 @@
+package lru
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLruGetMany(t *testing.T) {
+	clk := NewFakeClock(time.Unix(0, 0))
+	c := NewLruCache(&Options{MaxEntries: 3, Clock: clk, DisableBackgroundCleanup: true})
+	defer c.Close()
+	c.AddAndUpdateCache("a", testValue("1"))
+	c.AddAndUpdateCache("b", testValue("2"))
+	c.AddWithTTL("e", testValue("3"), time.Second)
+	clk.Advance(2 * time.Second)
+
+	got := c.GetMany([]string{"a", "e", "missing"})
+	if len(got) != 1 || got["a"] != testValue("1") {
+		t.Fatalf("GetMany 的结果为 %v", got)
+	}
+	// 过期的key在查找时被删除
+	if c.Len() != 2 {
+		t.Fatalf("过期的key应该被删除，当前条目数为 %d", c.Len())
+	}
+	stats := c.Stats()
+	if stats.Hits != 1 || stats.Misses != 2 {
+		t.Fatalf("命中统计错误: %+v", stats)
+	}
+	// a 被移动到队尾，容量不足时先淘汰 b
+	c.AddAndUpdateCache("c", testValue("4"))
+	c.AddAndUpdateCache("d", testValue("5"))
+	if !c.Contains("a") || c.Contains("b") {
+		t.Fatal("GetMany 应该与 FindCache 一样更新淘汰顺序")
+	}
+}
+
+func TestLruAddMany(t *testing.T) {
+	c := NewLruCache(&Options{MaxBytes: 100, MaxValueBytes: 4, DisableBackgroundCleanup: true})
+	defer c.Close()
+	errs := c.AddMany(map[string]Value{"a": testValue("1"), "b": testValue("2"), "big": testValue("12345")})
+	if len(errs) != 1 || !errors.Is(errs["big"], ErrValueTooLarge) {
+		t.Fatalf("AddMany 返回的错误为 %v", errs)
+	}
+	if v, ok := c.Peek("a"); !ok || v != testValue("1") {
+		t.Fatal("a 应该写入成功")
+	}
+	if c.Len() != 2 {
+		t.Fatalf("条目数应该为 2，实际为 %d", c.Len())
+	}
+}
+
+func TestShardedBatch(t *testing.T) {
+	c := NewShardedCache(&Options{MaxBytes: 1 << 20, ShardCount: 4, DisableBackgroundCleanup: true})
+	defer c.Close()
+	items := make(map[string]Value)
+	var keys []string
+	for _, k := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+		items[k] = testValue(k)
+		keys = append(keys, k)
+	}
+	if errs := c.AddMany(items); errs != nil {
+		t.Fatal(errs)
+	}
+	got := c.GetMany(append(keys, "missing"))
+	if len(got) != len(keys) {
+		t.Fatalf("应该命中 %d 个key，实际为 %d", len(keys), len(got))
+	}
+	for _, k := range keys {
+		if got[k] != testValue(k) {
+			t.Fatalf("key %q 的值为 %v", k, got[k])
+		}
+	}
+}
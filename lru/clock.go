@@ -0,0 +1,50 @@
+package lru
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock 时间来源，过期时间的计算和判断都通过它获取当前时间，测试时可以替换为 FakeClock
+type Clock interface {
+	Now() time.Time
+}
+
+// realClock 使用系统时间，是默认的 Clock
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// FakeClock 手动推进的时钟，用于编写不依赖 sleep 的确定性 TTL 测试，零值从 Unix 零点开始
+// 只影响过期时间的判断，后台清理协程的触发间隔仍然使用真实时间
+type FakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewFakeClock 创建从 now 开始的 FakeClock
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance 将时钟向前推进 d
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// Set 将时钟设置为 t
+func (c *FakeClock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = t
+}
@@ -0,0 +1,33 @@
+package lru
+
+import (
+	"testing"
+	"time"
+)
+
+// 推进 FakeClock 就可以触发过期，不需要 sleep
+func TestFakeClockExpiry(t *testing.T) {
+	for _, ct := range []CacheType{LRU, LFU, FIFO, Sharded, TwoQueue} {
+		t.Run(string(ct), func(t *testing.T) {
+			clock := NewFakeClock(time.Unix(1000, 0))
+			c := NewStore(ct, &Options{Clock: clock, DisableBackgroundCleanup: true})
+			defer c.Close()
+			c.AddWithTTL("a", testValue("1"), time.Hour)
+			c.AddWithTTL("b", testValue("1"), 2*time.Hour)
+			clock.Advance(59 * time.Minute)
+			if _, ok := c.FindCache("a"); !ok {
+				t.Fatal("a 还没有过期")
+			}
+			if ttl, _ := c.TTL("a"); ttl != time.Minute {
+				t.Fatalf("a 剩余的过期时间应该为 1m，实际为 %v", ttl)
+			}
+			clock.Advance(2 * time.Minute)
+			if _, ok := c.FindCache("a"); ok {
+				t.Fatal("a 应该已经过期")
+			}
+			if _, ok := c.FindCache("b"); !ok {
+				t.Fatal("b 还没有过期")
+			}
+		})
+	}
+}
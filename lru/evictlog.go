@@ -0,0 +1,61 @@
+package lru
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// 默认的淘汰日志汇总间隔
+const defaultEvictionLogInterval = 10 * time.Second
+
+// evictionLog 汇总输出淘汰日志，持续超出容量时每次写入都可能淘汰数据，逐条输出会刷屏，
+// 所以只累计淘汰的条目数和字节数，每个间隔最多输出一条汇总日志
+type evictionLog struct {
+	log      *zap.Logger
+	clk      Clock
+	interval time.Duration // 小于 0 时不输出日志
+	mu       sync.Mutex
+	count    int       // 上次输出之后累计淘汰的条目数
+	bytes    int64     // 上次输出之后累计回收的字节数
+	last     time.Time // 上次输出（或者创建）的时间
+}
+
+func newEvictionLog(opt *Options) *evictionLog {
+	return &evictionLog{
+		log:      opt.Logger,
+		clk:      opt.Clock,
+		interval: opt.EvictionLogInterval,
+		last:     opt.Clock.Now(),
+	}
+}
+
+// record 累计一次淘汰，距离上次输出已经超过间隔时输出汇总日志
+func (l *evictionLog) record(count int, bytes int64) {
+	if count <= 0 || l.interval < 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.count += count
+	l.bytes += bytes
+	if now := l.clk.Now(); now.Sub(l.last) >= l.interval {
+		l.flushLocked(now)
+	}
+}
+
+// flush 输出还没有输出的汇总，关闭缓存时调用
+func (l *evictionLog) flush() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.flushLocked(l.clk.Now())
+}
+
+func (l *evictionLog) flushLocked(now time.Time) {
+	if l.count > 0 {
+		l.log.Info("淘汰数据汇总", zap.Int("count", l.count), zap.Int64("bytes", l.bytes),
+			zap.Duration("elapsed", now.Sub(l.last)))
+	}
+	l.count, l.bytes, l.last = 0, 0, now
+}
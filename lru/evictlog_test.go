@@ -0,0 +1,63 @@
+package lru
+
+import (
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// 持续超出容量时每个间隔最多输出一条汇总日志，Close 时输出剩余的汇总，所有汇总的条目数之和等于淘汰的条目数
+func TestEvictionLogSummary(t *testing.T) {
+	tests := []struct {
+		name     string
+		typ      CacheType
+		maxLines int // 5 个间隔加上 Close 时的一条，分片缓存的每个分片各自汇总
+	}{
+		{"LRU", LRU, 6},
+		{"LFU", LFU, 6},
+		{"FIFO", FIFO, 6},
+		{"2Q", TwoQueue, 6},
+		{"Sharded", Sharded, 12},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			core, logs := observer.New(zap.InfoLevel)
+			clk := NewFakeClock(time.Unix(0, 0))
+			c := NewStore(tt.typ, &Options{MaxEntries: 10, ShardCount: 2, Logger: zap.New(core), Clock: clk,
+				EvictionLogInterval: time.Second, DisableBackgroundCleanup: true})
+			for i := 0; i < 10000; i++ {
+				c.AddAndUpdateCache(strconv.Itoa(i), testValue("v"))
+				if i%1000 == 999 {
+					clk.Advance(500 * time.Millisecond)
+				}
+			}
+			kept := c.Len()
+			c.Close()
+			entries := logs.FilterMessage("淘汰数据汇总").All()
+			if len(entries) == 0 || len(entries) > tt.maxLines {
+				t.Fatalf("输出了 %d 条汇总日志，期望 1 到 %d 条", len(entries), tt.maxLines)
+			}
+			var count int64
+			for _, e := range entries {
+				count += e.ContextMap()["count"].(int64)
+			}
+			if count != int64(10000-kept) {
+				t.Fatalf("汇总的淘汰条目数为 %d，期望 %d", count, 10000-kept)
+			}
+		})
+	}
+}
+
+// EvictionLogInterval 小于 0 时不输出淘汰日志
+func TestEvictionLogDisabled(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	c := NewLruCache(&Options{MaxEntries: 1, Logger: zap.New(core), EvictionLogInterval: -1, DisableBackgroundCleanup: true})
+	c.AddAndUpdateCache("a", testValue("v"))
+	c.AddAndUpdateCache("b", testValue("v"))
+	c.Close()
+	if n := logs.FilterMessage("淘汰数据汇总").Len(); n != 0 {
+		t.Fatalf("关闭淘汰日志之后仍然输出了 %d 条", n)
+	}
+}
@@ -0,0 +1,63 @@
+package lru
+
+import (
+	"container/heap"
+	"time"
+)
+
+// 过期堆中的一项
+type expiryItem struct {
+	key string
+	at  time.Time
+}
+
+// expiryHeap 按过期时间排序的最小堆，堆顶是最早过期的key
+// 更新或删除key时不会同步修改堆，旧的记录在弹出时与 expires 比对后丢弃（延迟删除）
+type expiryHeap []expiryItem
+
+func (h expiryHeap) Len() int           { return len(h) }
+func (h expiryHeap) Less(i, j int) bool { return h[i].at.Before(h[j].at) }
+func (h expiryHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *expiryHeap) Push(x interface{}) {
+	*h = append(*h, x.(expiryItem))
+}
+
+func (h *expiryHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
+
+// 根据 expires 重新建堆，丢弃所有过时的记录
+func (h *expiryHeap) rebuild(expires map[string]time.Time) {
+	items := make(expiryHeap, 0, len(expires))
+	for key, at := range expires {
+		items = append(items, expiryItem{key: key, at: at})
+	}
+	heap.Init(&items)
+	*h = items
+}
+
+// push 记录key新的过期时间，调用前 expires 中必须已经是新的值
+// 频繁更新过期时间会在堆中留下大量过时的记录，超过一定比例时重新建堆
+func (h *expiryHeap) push(expires map[string]time.Time, key string, at time.Time) {
+	heap.Push(h, expiryItem{key: key, at: at})
+	if len(*h) > 2*len(expires)+64 {
+		h.rebuild(expires)
+	}
+}
+
+// popExpired 从堆顶弹出一个在 now 之前已经过期的key，过时的记录（key被删除或者过期时间被刷新）直接丢弃
+// 没有已经过期的key时返回 false，只会访问已经过期的部分
+func (h *expiryHeap) popExpired(expires map[string]time.Time, now time.Time) (string, bool) {
+	for h.Len() > 0 && now.After((*h)[0].at) {
+		item := heap.Pop(h).(expiryItem)
+		if t, ok := expires[item.key]; ok && t.Equal(item.at) {
+			return item.key, true
+		}
+	}
+	return "", false
+}
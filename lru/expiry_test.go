@@ -0,0 +1,347 @@
+package lru
+
+import (
+	"Distributed-Cache-Go/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"math"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// 清理只删除真正过期的key，刷新过过期时间或者重新写入为永不过期的key不受堆中旧记录的影响
+func TestEvictExpired(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	c := NewLruCache(&Options{MaxBytes: 1 << 30, Clock: clock, DisableBackgroundCleanup: true})
+	defer c.Close()
+	for i := 0; i < 100; i++ {
+		c.AddWithTTL(strconv.Itoa(i), testValue("v"), time.Second)
+	}
+	for i := 0; i < 50; i++ {
+		c.AddWithTTL(strconv.Itoa(i), testValue("v"), time.Hour)
+	}
+	c.AddWithTTL("x", testValue("v"), time.Second)
+	c.DeleteCache("x")
+	c.AddAndUpdateCache("x", testValue("v"))
+	clock.Advance(2 * time.Second)
+
+	c.mu.Lock()
+	n, _, err := c.evict()
+	c.mu.Unlock()
+	if err != nil || n != 50 || c.Len() != 51 {
+		t.Fatalf("evict() 删除了 %d 个，剩余 %d 个，err=%v", n, c.Len(), err)
+	}
+	// 反复刷新同一个key时，堆中的旧记录会被压缩，不会无限增长
+	for i := 0; i < 1000; i++ {
+		c.AddWithTTL("r", testValue("v"), time.Hour)
+	}
+	if len(c.expiryHeap) > 2*len(c.expires)+64 {
+		t.Fatalf("堆中有 %d 条记录，expires 只有 %d 个", len(c.expiryHeap), len(c.expires))
+	}
+}
+
+// LFU、FIFO、2Q 写入时同样通过过期堆删除过期的key，刷新过过期时间的key不会被堆中的旧记录误删
+func TestStoreEvictExpired(t *testing.T) {
+	tests := []struct {
+		name  string
+		store func(opt *Options) Store
+	}{
+		{"lfu", func(opt *Options) Store { return NewLfuCache(opt) }},
+		{"fifo", func(opt *Options) Store { return NewFifoCache(opt) }},
+		{"twoqueue", func(opt *Options) Store { return NewTwoQueueCache(opt) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := NewFakeClock(time.Unix(0, 0))
+			c := tt.store(&Options{MaxBytes: 1 << 30, Clock: clock, DisableBackgroundCleanup: true})
+			defer c.Close()
+			for i := 0; i < 10; i++ {
+				c.AddWithTTL(strconv.Itoa(i), testValue("v"), time.Second)
+			}
+			for i := 0; i < 5; i++ {
+				c.AddWithTTL(strconv.Itoa(i), testValue("v"), time.Hour)
+			}
+			clock.Advance(2 * time.Second)
+			// 下一次写入触发清理
+			c.AddAndUpdateCache("x", testValue("v"))
+			if c.Len() != 6 {
+				t.Fatalf("清理后剩余 %d 个，期望 6 个", c.Len())
+			}
+			for i := 0; i < 5; i++ {
+				if _, ok := c.Peek(strconv.Itoa(i)); !ok {
+					t.Fatalf("刷新过过期时间的 %d 不应该被删除", i)
+				}
+			}
+		})
+	}
+}
+
+// 每个key按照自己的过期时间过期，与清理间隔无关；AddAndUpdateCache 使用 DefaultTTL，NoExpiration 表示永不过期
+func TestPerKeyTTL(t *testing.T) {
+	tests := []struct {
+		name        string
+		defaultTTL  time.Duration
+		wantDefault bool // 两小时之后 AddAndUpdateCache 写入的key是否仍然存在
+	}{
+		{"永不过期", NoExpiration, true},
+		{"默认一小时", time.Hour, false},
+	}
+	for _, tt := range tests {
+		for _, ct := range []CacheType{LRU, LFU, FIFO, Sharded, TwoQueue} {
+			t.Run(tt.name+"/"+string(ct), func(t *testing.T) {
+				clock := NewFakeClock(time.Unix(0, 0))
+				c := NewStore(ct, &Options{Clock: clock, CleanupInterval: 24 * time.Hour, DefaultTTL: tt.defaultTTL, DisableBackgroundCleanup: true})
+				defer c.Close()
+				c.AddWithTTL("short", testValue("1"), time.Second)
+				c.AddWithTTL("long", testValue("1"), time.Minute)
+				c.AddAndUpdateCache("default", testValue("1"))
+
+				clock.Advance(2 * time.Second)
+				if _, ok := c.FindCache("short"); ok {
+					t.Fatal("short 应该在 1s 之后过期")
+				}
+				if _, ok := c.FindCache("long"); !ok {
+					t.Fatal("long 还没有过期")
+				}
+				clock.Advance(time.Minute)
+				if _, ok := c.FindCache("long"); ok {
+					t.Fatal("long 应该在 1m 之后过期")
+				}
+				clock.Advance(2 * time.Hour)
+				if _, ok := c.FindCache("default"); ok != tt.wantDefault {
+					t.Fatalf("default 是否存在为 %v，期望 %v", ok, tt.wantDefault)
+				}
+			})
+		}
+	}
+}
+
+// 后台清理回收过期的key之后，在 debug 日志和 Prometheus 指标中报告回收的条目数和容量
+func TestCleanupReportsReclaimed(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	clock := NewFakeClock(time.Unix(0, 0))
+	c := NewLruCache(&Options{CleanupInterval: 5 * time.Millisecond, Clock: clock, Logger: zap.New(core)})
+	defer c.Close()
+	reclaimed := testutil.ToFloat64(metrics.CleanupReclaimed)
+	reclaimedBytes := testutil.ToFloat64(metrics.CleanupReclaimedBytes)
+	c.AddWithTTL("a", testValue("xx"), time.Second)
+	c.AddWithTTL("b", testValue("yy"), time.Second)
+	c.AddAndUpdateCache("c", testValue("z"))
+	clock.Advance(2 * time.Second)
+
+	deadline := time.Now().Add(time.Second)
+	for logs.FilterMessage("后台清理回收数据").Len() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("后台清理没有报告回收的数据")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	fields := logs.FilterMessage("后台清理回收数据").All()[0].ContextMap()
+	if fields["count"] != int64(2) || fields["bytes"] != int64(6) {
+		t.Fatalf("日志中报告的回收数据为 %v", fields)
+	}
+	if d := testutil.ToFloat64(metrics.CleanupReclaimed) - reclaimed; d < 2 {
+		t.Fatalf("回收条目数的指标增加了 %v", d)
+	}
+	if d := testutil.ToFloat64(metrics.CleanupReclaimedBytes) - reclaimedBytes; d < 6 {
+		t.Fatalf("回收容量的指标增加了 %v", d)
+	}
+	if c.Len() != 1 || c.Stats().Expirations != 2 {
+		t.Fatalf("清理之后剩余 %d 个，过期统计为 %d", c.Len(), c.Stats().Expirations)
+	}
+}
+
+// NoExpiration 写入的key经过多次后台清理仍然存在，同时写入的短期key被清理掉
+func TestNoExpirationSurvivesCleanup(t *testing.T) {
+	for _, ct := range []CacheType{LRU, LFU, FIFO, Sharded, TwoQueue} {
+		t.Run(string(ct), func(t *testing.T) {
+			clock := NewFakeClock(time.Unix(0, 0))
+			c := NewStore(ct, &Options{CleanupInterval: time.Millisecond, DefaultTTL: time.Second, Clock: clock})
+			defer c.Close()
+			c.AddWithTTL("forever", testValue("x"), NoExpiration)
+			c.AddAndUpdateCache("short", testValue("y"))
+			for i := 0; i < 5; i++ {
+				clock.Advance(time.Hour)
+				// 等待至少一次清理
+				time.Sleep(10 * time.Millisecond)
+			}
+			// LFU、FIFO、2Q 在写入时清理过期的key
+			c.AddAndUpdateCache("trigger", testValue("z"))
+			if !c.Contains("forever") || c.Contains("short") || c.Len() != 2 {
+				t.Fatalf("永不过期的key应该保留，短期的key应该被清理，剩余 %d 个", c.Len())
+			}
+			if ttl, ok := c.TTL("forever"); !ok || ttl != NoExpiration {
+				t.Fatalf("forever 的剩余时间为 %v %v", ttl, ok)
+			}
+		})
+	}
+}
+
+// 同一时间写入的相同 TTL 的key，过期时间分散在 [TTL-TTLJitter, TTL+TTLJitter] 之间；抖动不会让 TTL 小于等于 0
+func TestTTLJitter(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	c := NewLruCache(&Options{TTLJitter: 10 * time.Second, Clock: clock, DisableBackgroundCleanup: true})
+	defer c.Close()
+	for i := 0; i < 200; i++ {
+		c.AddWithTTL(strconv.Itoa(i), testValue("v"), time.Minute)
+	}
+	lo, hi := time.Duration(math.MaxInt64), time.Duration(0)
+	for i := 0; i < 200; i++ {
+		ttl, _ := c.TTL(strconv.Itoa(i))
+		lo, hi = min(lo, ttl), max(hi, ttl)
+	}
+	if lo < 50*time.Second || hi > 70*time.Second || hi-lo < 15*time.Second {
+		t.Fatalf("过期时间分布在 [%v, %v]，应该覆盖 [50s, 70s] 的大部分", lo, hi)
+	}
+
+	tests := []struct {
+		ttl, jitter time.Duration
+	}{
+		{time.Second, time.Hour},
+		{time.Nanosecond, time.Second},
+		{time.Minute, 0},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 100; i++ {
+			if got := jitterTTL(tt.ttl, tt.jitter); got <= 0 || got > tt.ttl+tt.jitter {
+				t.Fatalf("jitterTTL(%v, %v) = %v", tt.ttl, tt.jitter, got)
+			}
+		}
+	}
+}
+
+// 开启滑动过期时每次命中都会刷新过期时间，key在原来的过期时间之后仍然存在；未开启时按照写入时的绝对时间过期
+func TestSlidingExpiration(t *testing.T) {
+	tests := []struct {
+		name     string
+		opt      Options
+		survives bool
+	}{
+		{"绝对过期", Options{}, false},
+		{"滑动过期", Options{SlidingExpiration: true}, true},
+		{"滑动过期并且采样淘汰", Options{SlidingExpiration: true, EvictionSamples: 5}, true},
+		{"滑动过期并且延迟提升", Options{SlidingExpiration: true, PromotionBuffer: 4}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := NewFakeClock(time.Unix(0, 0))
+			opt := tt.opt
+			opt.Clock = clock
+			opt.DisableBackgroundCleanup = true
+			c := NewLruCache(&opt)
+			defer c.Close()
+			c.AddWithTTL("a", testValue("v"), 10*time.Second)
+			// 每 6s 访问一次，共 60s，远超过原来 10s 的过期时间
+			for i := 0; i < 10; i++ {
+				clock.Advance(6 * time.Second)
+				c.FindCache("a")
+			}
+			if c.Contains("a") != tt.survives {
+				t.Fatalf("60s 之后 a 是否存在为 %v，期望 %v", !tt.survives, tt.survives)
+			}
+			if tt.survives {
+				clock.Advance(11 * time.Second)
+				if c.Contains("a") {
+					t.Fatal("停止访问之后 a 应该在 10s 之后过期")
+				}
+			}
+		})
+	}
+}
+
+// DeleteExpired 立即删除所有已经过期的条目并返回删除的数量，分批清理时也会一直清理到没有过期的条目
+func TestDeleteExpired(t *testing.T) {
+	type sweeper interface {
+		Store
+		DeleteExpired() int
+	}
+	tests := []struct {
+		name string
+		new  func(clk Clock) sweeper
+	}{
+		{"LruCache", func(clk Clock) sweeper {
+			return NewLruCache(&Options{Clock: clk, DisableBackgroundCleanup: true})
+		}},
+		{"EvictionBatchSize=3", func(clk Clock) sweeper {
+			return NewLruCache(&Options{Clock: clk, DisableBackgroundCleanup: true, EvictionBatchSize: 3})
+		}},
+		{"ShardedCache", func(clk Clock) sweeper {
+			return NewShardedCache(&Options{Clock: clk, DisableBackgroundCleanup: true, ShardCount: 4})
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := NewFakeClock(time.Unix(0, 0))
+			c := tt.new(clock)
+			defer c.Close()
+			for i := 0; i < 10; i++ {
+				c.AddWithTTL(strconv.Itoa(i), testValue("v"), time.Second)
+			}
+			for i := 10; i < 15; i++ {
+				c.AddWithTTL(strconv.Itoa(i), testValue("v"), time.Hour)
+			}
+			if n := c.DeleteExpired(); n != 0 {
+				t.Fatalf("没有过期的条目时删除了 %d 个", n)
+			}
+			clock.Advance(2 * time.Second)
+			if n := c.DeleteExpired(); n != 10 || c.Len() != 5 {
+				t.Fatalf("DeleteExpired() = %d，剩余 %d 个，期望 10 和 5", n, c.Len())
+			}
+			if n := c.DeleteExpired(); n != 0 {
+				t.Fatalf("再次清理删除了 %d 个", n)
+			}
+		})
+	}
+}
+
+// 10 万个条目中只有少量过期时，最小堆只需要查看过期的部分；fullScan 为遍历整个 expires 的做法，作为对照
+func BenchmarkCleanup(b *testing.B) {
+	const entries, expiring = 100000, 10
+	setup := func(b *testing.B) (*LruCache, *FakeClock) {
+		clock := NewFakeClock(time.Unix(0, 0))
+		c := NewLruCache(&Options{MaxBytes: 1 << 30, Clock: clock, DisableBackgroundCleanup: true})
+		for i := 0; i < entries; i++ {
+			c.AddWithTTL(strconv.Itoa(i), testValue("v"), time.Hour)
+		}
+		return c, clock
+	}
+	b.Run("heap", func(b *testing.B) {
+		c, clock := setup(b)
+		defer c.Close()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			for j := 0; j < expiring; j++ {
+				c.AddWithTTL("e"+strconv.Itoa(j), testValue("v"), time.Second)
+			}
+			clock.Advance(2 * time.Second)
+			b.StartTimer()
+			c.mu.Lock()
+			c.evict()
+			c.mu.Unlock()
+		}
+	})
+	b.Run("fullScan", func(b *testing.B) {
+		c, clock := setup(b)
+		defer c.Close()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			for j := 0; j < expiring; j++ {
+				c.AddWithTTL("e"+strconv.Itoa(j), testValue("v"), time.Second)
+			}
+			clock.Advance(2 * time.Second)
+			b.StartTimer()
+			c.mu.Lock()
+			now := c.clk.Now()
+			for key, t := range c.expires {
+				if now.After(t) {
+					c.removeCache(c.items[key], ReasonExpired)
+				}
+			}
+			c.mu.Unlock()
+		}
+	})
+}
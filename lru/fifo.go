@@ -0,0 +1,379 @@
+package lru
+
+import (
+	"Distributed-Cache-Go/metrics"
+	"container/list"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+// FifoCache 是一个 FIFO（先进先出）缓存实现，实现了 Store 接口。
+// 容量不足时严格按照插入顺序淘汰数据，访问和更新都不会改变元素在链表中的位置。
+// 过期机制与 LruCache 保持一致。
+type FifoCache struct {
+	// 1.核心功能：数据存储、容量控制、并发控制
+	list          *list.List               // 双向链表，头部是最早插入的元素
+	items         map[string]*list.Element // 键到链表节点的映射
+	maxBytes      int64                    // 最大容量
+	maxEntries    int64                    // 最大条目数，0 表示不限制
+	maxValueBytes int64                    // 单个键值对的最大大小，0 表示不限制
+	currentBytes  int64                    // 当前已经使用的容量
+	mu            sync.RWMutex             // 读写锁
+	// 2.扩展功能：淘汰策略、过期机制
+	onEvicted  func(key string, value Value, reason EvictReason)
+	expires    map[string]time.Time
+	expiryHeap expiryHeap // 按过期时间排序的最小堆，清理时只需要查看已经过期的部分
+	defaultTTL time.Duration
+	ttlJitter  time.Duration
+	// 3.优化功能：后台清理协程、优雅关闭
+	cleanupInterval time.Duration
+	cleanTicker     *time.Ticker
+	closeChan       chan struct{}
+	closeOnce       sync.Once
+	wg              sync.WaitGroup // 后台清理协程，Close 等待它退出
+	// 日志输出
+	log *zap.Logger
+	// 时间来源，默认为系统时间
+	clk Clock
+	// 汇总输出淘汰日志
+	evictLog *evictionLog
+	// 自定义容量计算，为空时使用 len(key)+value.Len()
+	weigher func(key string, value Value) int64
+}
+
+// 内层条目结构体
+type FifoEntry struct {
+	key   string
+	value Value
+}
+
+// 构造函数
+func NewFifoCache(opt *Options) *FifoCache {
+	withDefault(opt)
+	cache := &FifoCache{
+		list:            list.New(),
+		items:           make(map[string]*list.Element),
+		maxBytes:        opt.MaxBytes,
+		maxEntries:      opt.MaxEntries,
+		maxValueBytes:   opt.MaxValueBytes,
+		onEvicted:       opt.OnEvicted,
+		expires:         make(map[string]time.Time),
+		defaultTTL:      opt.DefaultTTL,
+		ttlJitter:       opt.TTLJitter,
+		cleanupInterval: opt.CleanupInterval,
+		closeChan:       make(chan struct{}),
+		log:             opt.Logger,
+		clk:             opt.Clock,
+		evictLog:        newEvictionLog(opt),
+		weigher:         opt.Weigher,
+	}
+	if !opt.DisableBackgroundCleanup {
+		cache.startCleanUpRoutine()
+	}
+	return cache
+}
+
+func (c *FifoCache) startCleanUpRoutine() {
+	c.cleanTicker = time.NewTicker(c.cleanupInterval)
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		c.cleanupLoop()
+	}()
+}
+
+// 1.向缓存中新增/更新数据，过期时间使用默认的 defaultTTL
+func (c *FifoCache) AddAndUpdateCache(key string, value Value) error {
+	return c.AddWithTTL(key, value, c.defaultTTL)
+}
+
+// AddWithTTL 向缓存中新增/更新数据，并为该key单独设置过期时间，ttl<=0 表示永不过期
+// 更新已经存在的key不会改变它的插入顺序
+func (c *FifoCache) AddWithTTL(key string, value Value, ttl time.Duration) error {
+	if value == nil {
+		return nil
+	}
+	if err := checkValueSize(key, c.sizeOf(key, value), c.maxValueBytes); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.set(key, value, ttl)
+}
+
+// AddIfAbsent 只有key不存在或者已经过期时才写入，检查和写入在同一次加写锁中完成，返回是否写入
+func (c *FifoCache) AddIfAbsent(key string, value Value, ttl time.Duration) (bool, error) {
+	if value == nil {
+		return false, nil
+	}
+	if err := checkValueSize(key, c.sizeOf(key, value), c.maxValueBytes); err != nil {
+		return false, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok {
+		if t, ok := c.expires[key]; !ok || !c.clk.Now().After(t) {
+			return false, nil
+		}
+		// 已经过期的旧数据先删除，再作为新的key写入
+		c.removeCache(elem, ReasonExpired)
+		metrics.Evictions.Inc()
+	}
+	if err := c.set(key, value, ttl); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// 新增/更新数据，调用此方法前必须持有锁
+func (c *FifoCache) set(key string, value Value, ttl time.Duration) error {
+	if elem, ok := c.items[key]; ok {
+		entry := elem.Value.(*FifoEntry)
+		if err := checkCapacity(key, c.sizeOf(key, value), c.maxBytes); err != nil {
+			return err
+		}
+		delta := c.sizeOf(key, value) - c.sizeOf(entry.key, entry.value)
+		c.currentBytes += delta
+		metrics.Bytes.Add(float64(delta))
+		entry.value = value
+	} else {
+		// 新的key没有可以保留的过期时间，使用默认过期时间
+		if ttl == KeepTTL {
+			ttl = c.defaultTTL
+		}
+		c.items[key] = c.list.PushBack(&FifoEntry{key: key, value: value})
+		size := c.sizeOf(key, value)
+		c.currentBytes += size
+		metrics.Entries.Inc()
+		metrics.Bytes.Add(float64(size))
+	}
+	if ttl != KeepTTL {
+		c.createExpires(key, ttl)
+	}
+	c.evict()
+	return nil
+}
+
+// 创建元素的超时时间，ttl<=0 时不记录过期时间，即永不过期
+func (c *FifoCache) createExpires(key string, ttl time.Duration) {
+	if ttl <= 0 {
+		delete(c.expires, key)
+		return
+	}
+	at := c.clk.Now().Add(jitterTTL(ttl, c.ttlJitter))
+	c.expires[key] = at
+	c.expiryHeap.push(c.expires, key, at)
+}
+
+// 2.根据key删除缓存中的数据
+func (c *FifoCache) DeleteCache(key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok {
+		c.removeCache(elem, ReasonDeleted)
+	}
+	return nil
+}
+
+// 3.查询缓存中的数据，访问不会改变淘汰顺序，过期的数据会被同步删除
+func (c *FifoCache) FindCache(key string) (Value, bool) {
+	c.mu.RLock()
+	elem, ok := c.items[key]
+	if !ok {
+		c.mu.RUnlock()
+		return nil, false
+	}
+	if t, ok := c.expires[key]; ok && c.clk.Now().After(t) {
+		c.mu.RUnlock()
+		c.mu.Lock()
+		// 再次检查，获取写锁期间该key可能已经被删除或者被重新写入
+		if e, ok := c.items[key]; ok && e == elem {
+			if t, ok := c.expires[key]; ok && c.clk.Now().After(t) {
+				c.removeCache(e, ReasonExpired)
+				metrics.Evictions.Inc()
+			}
+		}
+		c.mu.Unlock()
+		return nil, false
+	}
+	value := elem.Value.(*FifoEntry).value
+	c.mu.RUnlock()
+	return value, true
+}
+
+// Peek 查询缓存中的数据，FIFO 中访问本身就不会影响淘汰顺序，与 FindCache 的区别是不会删除过期数据
+func (c *FifoCache) Peek(key string) (Value, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	if t, ok := c.expires[key]; ok && c.clk.Now().After(t) {
+		return nil, false
+	}
+	return elem.Value.(*FifoEntry).value, true
+}
+
+// Contains 判断key是否存在并且没有过期
+func (c *FifoCache) Contains(key string) bool {
+	_, ok := c.Peek(key)
+	return ok
+}
+
+// TTL 返回key剩余的过期时间，0 表示永不过期，key不存在或者已经过期时返回 false，不会影响淘汰顺序
+func (c *FifoCache) TTL(key string) (time.Duration, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if _, ok := c.items[key]; !ok {
+		return 0, false
+	}
+	t, ok := c.expires[key]
+	if !ok {
+		return 0, true
+	}
+	remaining := t.Sub(c.clk.Now())
+	if remaining <= 0 {
+		return 0, false
+	}
+	return remaining, true
+}
+
+// Range 遍历所有未过期的数据，f 返回 false 时停止遍历，按照插入顺序
+// 遍历期间持有读锁，f 中不能再调用该缓存的任何方法，否则可能造成死锁
+func (c *FifoCache) Range(f func(key string, value Value) bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	now := c.clk.Now()
+	for elem := c.list.Front(); elem != nil; elem = elem.Next() {
+		entry := elem.Value.(*FifoEntry)
+		if t, ok := c.expires[entry.key]; ok && now.After(t) {
+			continue
+		}
+		if !f(entry.key, entry.value) {
+			return
+		}
+	}
+}
+
+func (c *FifoCache) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.list.Len()
+}
+
+// Bytes 返回当前已经使用的容量
+func (c *FifoCache) Bytes() int64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.currentBytes
+}
+
+// 删除缓存中的数据，调用此方法前必须持有锁
+func (c *FifoCache) removeCache(elem *list.Element, reason EvictReason) {
+	entry := elem.Value.(*FifoEntry)
+	c.list.Remove(elem)
+	delete(c.items, entry.key)
+	delete(c.expires, entry.key)
+	size := c.sizeOf(entry.key, entry.value)
+	c.currentBytes -= size
+	metrics.Entries.Dec()
+	metrics.Bytes.Sub(float64(size))
+	if c.onEvicted != nil {
+		c.onEvicted(entry.key, entry.value, reason)
+	}
+}
+
+// Clear 清空缓存中的所有数据，每个被删除的元素都会触发 onEvicted 回调
+func (c *FifoCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.onEvicted != nil {
+		for elem := c.list.Front(); elem != nil; elem = elem.Next() {
+			entry := elem.Value.(*FifoEntry)
+			c.onEvicted(entry.key, entry.value, ReasonCleared)
+		}
+	}
+	metrics.Entries.Sub(float64(c.list.Len()))
+	metrics.Bytes.Sub(float64(c.currentBytes))
+	c.list = list.New()
+	c.items = make(map[string]*list.Element)
+	c.expires = make(map[string]time.Time)
+	c.expiryHeap = nil
+	c.currentBytes = 0
+}
+
+// Resize 运行时修改最大容量，如果新的容量更小会立即淘汰数据，返回被淘汰的条目数
+func (c *FifoCache) Resize(maxBytes int64) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.maxBytes = maxBytes
+	before := c.list.Len()
+	c.evict()
+	return before - c.list.Len()
+}
+
+// 定期清理缓存的方法
+func (c *FifoCache) cleanupLoop() {
+	for {
+		select {
+		case <-c.cleanTicker.C:
+			c.mu.Lock()
+			count, bytes := c.evict()
+			c.mu.Unlock()
+			reportReclaimed(c.log, count, bytes)
+		case <-c.closeChan:
+			return
+		}
+	}
+}
+
+// evict 清理过期和超出容量限制的缓存，返回被清理的条目数和回收的字节数，调用此方法前必须持有锁
+func (c *FifoCache) evict() (int, int64) {
+	before, beforeBytes := c.list.Len(), c.currentBytes
+	// 从过期堆中只取出已经过期的key，不需要遍历所有设置了过期时间的key
+	now := c.clk.Now()
+	for {
+		key, ok := c.expiryHeap.popExpired(c.expires, now)
+		if !ok {
+			break
+		}
+		if elem, ok := c.items[key]; ok {
+			c.removeCache(elem, ReasonExpired)
+			metrics.Evictions.Inc()
+		} else {
+			delete(c.expires, key)
+		}
+	}
+	// 从链表头部（最早插入）开始淘汰，直到满足容量限制
+	for c.list.Len() > 0 {
+		overBytes := c.maxBytes > 0 && c.currentBytes > c.maxBytes
+		overEntries := c.maxEntries > 0 && int64(c.list.Len()) > c.maxEntries
+		if !overBytes && !overEntries {
+			break
+		}
+		c.removeCache(c.list.Front(), ReasonCapacity)
+		metrics.Evictions.Inc()
+	}
+	count, bytes := before-c.list.Len(), beforeBytes-c.currentBytes
+	c.evictLog.record(count, bytes)
+	return count, bytes
+}
+
+// Close 关闭缓存，停止清理协程
+func (c *FifoCache) Close() {
+	c.closeOnce.Do(func() {
+		if c.cleanTicker != nil {
+			c.cleanTicker.Stop()
+		}
+		close(c.closeChan)
+		c.wg.Wait()
+		c.evictLog.flush()
+	})
+}
+
+// 键值对占用的容量
+func (c *FifoCache) sizeOf(key string, value Value) int64 {
+	return entrySize(c.weigher, key, value)
+}
@@ -0,0 +1,32 @@
+package lru
+
+import "testing"
+
+// 容量不足时 FIFO 按照插入顺序淘汰，读取过的key仍然最先被淘汰；LRU 中读取过的key会被保留
+func TestFifoEvictionOrder(t *testing.T) {
+	tests := []struct {
+		ct      CacheType
+		evicted string
+	}{
+		{FIFO, "a"},
+		{LRU, "b"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.ct), func(t *testing.T) {
+			s := NewStore(tt.ct, &Options{MaxEntries: 2, DisableBackgroundCleanup: true})
+			defer s.Close()
+			s.AddAndUpdateCache("a", testValue("1"))
+			s.AddAndUpdateCache("b", testValue("1"))
+			s.FindCache("a")
+			// 更新同样不会改变 FIFO 中的插入顺序
+			s.AddAndUpdateCache("a", testValue("2"))
+			s.AddAndUpdateCache("c", testValue("1"))
+			if s.Contains(tt.evicted) {
+				t.Fatalf("%s 应该被淘汰", tt.evicted)
+			}
+			if s.Len() != 2 {
+				t.Fatalf("淘汰之后剩余 %d 个", s.Len())
+			}
+		})
+	}
+}
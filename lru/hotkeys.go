@@ -0,0 +1,182 @@
+package lru
+
+import (
+	"container/heap"
+	"github.com/cespare/xxhash/v2"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// KeyCount 一个key以及它被访问的次数
+type KeyCount struct {
+	Key   string
+	Count int64
+}
+
+const (
+	sketchDepth     = 4    // count-min sketch 的行数
+	sketchWidth     = 2048 // 每一行的计数器个数，必须是 2 的幂
+	hotKeysCapacity = 128  // 最多跟踪的热点key数量，TopK 最多返回这么多个key
+)
+
+// hotKeys 统计key被访问的次数，用于找出热点key
+// 访问次数记录在固定大小的 count-min sketch 中，计数器通过原子操作更新，不需要加锁，内存占用与key的数量无关；
+// 另外用一个容量为 hotKeysCapacity 的最小堆保存当前的候选热点key，只有不在候选中并且估计次数超过堆顶的key才需要加锁，
+// 所以稳定之后绝大多数访问都不会加锁。count-min sketch 的估计值只会偏大，次数是近似值
+type hotKeys struct {
+	counters  []int64    // sketchDepth 行、每行 sketchWidth 个计数器，原子读写
+	threshold int64      // 候选已满时为堆顶的次数，否则为 0，估计次数不超过它的key不需要加锁，原子读写
+	members   sync.Map   // 候选key的集合，供无锁判断
+	mu        sync.Mutex // 保护 heap
+	heap      hotKeyHeap // 候选key的最小堆
+}
+
+func newHotKeys() *hotKeys {
+	return &hotKeys{
+		counters: make([]int64, sketchDepth*sketchWidth),
+		heap:     hotKeyHeap{index: make(map[string]int)},
+	}
+}
+
+// 计算key在每一行中的位置，使用双重哈希从一个 64 位哈希值派生出多个哈希函数
+func sketchSlots(key string) [sketchDepth]int {
+	h := xxhash.Sum64String(key)
+	h1, h2 := uint32(h), uint32(h>>32)|1
+	var slots [sketchDepth]int
+	for i := range slots {
+		slots[i] = i*sketchWidth + int((h1+uint32(i)*h2)&(sketchWidth-1))
+	}
+	return slots
+}
+
+// 返回key的估计访问次数
+func (h *hotKeys) estimate(key string) int64 {
+	var est int64 = -1
+	for _, slot := range sketchSlots(key) {
+		if v := atomic.LoadInt64(&h.counters[slot]); est < 0 || v < est {
+			est = v
+		}
+	}
+	return est
+}
+
+// 记录一次访问
+func (h *hotKeys) touch(key string) {
+	var est int64 = -1
+	for _, slot := range sketchSlots(key) {
+		if v := atomic.AddInt64(&h.counters[slot], 1); est < 0 || v < est {
+			est = v
+		}
+	}
+	if est <= atomic.LoadInt64(&h.threshold) {
+		return
+	}
+	// 已经是候选的key，次数在 topK 时从 sketch 中重新读取
+	if _, ok := h.members.Load(key); ok {
+		return
+	}
+	h.mu.Lock()
+	h.offer(key, est)
+	h.mu.Unlock()
+}
+
+// offer 尝试把key加入候选，候选已满时替换次数最少的一个，调用此方法前必须持有锁
+func (h *hotKeys) offer(key string, est int64) {
+	if i, ok := h.heap.index[key]; ok {
+		h.heap.items[i].Count = est
+		heap.Fix(&h.heap, i)
+		return
+	}
+	if len(h.heap.items) < hotKeysCapacity {
+		heap.Push(&h.heap, KeyCount{Key: key, Count: est})
+	} else {
+		// 堆中记录的次数可能已经过时，先刷新堆顶，直到堆顶的次数是最新的
+		for {
+			fresh := h.estimate(h.heap.items[0].Key)
+			if fresh == h.heap.items[0].Count {
+				break
+			}
+			h.heap.items[0].Count = fresh
+			heap.Fix(&h.heap, 0)
+		}
+		if est <= h.heap.items[0].Count {
+			atomic.StoreInt64(&h.threshold, h.heap.items[0].Count)
+			return
+		}
+		h.members.Delete(heap.Pop(&h.heap).(KeyCount).Key)
+		heap.Push(&h.heap, KeyCount{Key: key, Count: est})
+	}
+	h.members.Store(key, struct{}{})
+	if len(h.heap.items) == hotKeysCapacity {
+		atomic.StoreInt64(&h.threshold, h.heap.items[0].Count)
+	}
+}
+
+// 返回访问次数最多的 n 个key，按访问次数从高到低排列，n 小于 0 时返回所有候选
+func (h *hotKeys) topK(n int) []KeyCount {
+	h.mu.Lock()
+	result := make([]KeyCount, 0, len(h.heap.items))
+	for _, kc := range h.heap.items {
+		result = append(result, KeyCount{Key: kc.Key, Count: h.estimate(kc.Key)})
+	}
+	h.mu.Unlock()
+	return topKeyCounts(result, n)
+}
+
+// 清空统计
+func (h *hotKeys) reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i := range h.counters {
+		atomic.StoreInt64(&h.counters[i], 0)
+	}
+	for _, kc := range h.heap.items {
+		h.members.Delete(kc.Key)
+	}
+	h.heap = hotKeyHeap{index: make(map[string]int)}
+	atomic.StoreInt64(&h.threshold, 0)
+}
+
+// hotKeyHeap 按访问次数排序的最小堆，同时维护 key 在堆中的下标
+type hotKeyHeap struct {
+	items []KeyCount
+	index map[string]int
+}
+
+func (h hotKeyHeap) Len() int           { return len(h.items) }
+func (h hotKeyHeap) Less(i, j int) bool { return h.items[i].Count < h.items[j].Count }
+
+func (h hotKeyHeap) Swap(i, j int) {
+	h.items[i], h.items[j] = h.items[j], h.items[i]
+	h.index[h.items[i].Key] = i
+	h.index[h.items[j].Key] = j
+}
+
+func (h *hotKeyHeap) Push(x interface{}) {
+	kc := x.(KeyCount)
+	h.index[kc.Key] = len(h.items)
+	h.items = append(h.items, kc)
+}
+
+func (h *hotKeyHeap) Pop() interface{} {
+	n := len(h.items)
+	x := h.items[n-1]
+	h.items = h.items[:n-1]
+	delete(h.index, x.Key)
+	return x
+}
+
+// 按访问次数从高到低排序后返回前 n 个，次数相同时按 key 排序保证结果稳定
+func topKeyCounts(counts []KeyCount, n int) []KeyCount {
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].Key < counts[j].Key
+	})
+	if n >= 0 && n < len(counts) {
+		counts = counts[:n]
+	}
+	return counts
+}
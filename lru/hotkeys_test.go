@@ -0,0 +1,69 @@
+package lru
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// 偏斜的访问模式下，访问最多的key排在第一位，并且候选数量不会超过上限
+func TestTopKSkewed(t *testing.T) {
+	c := NewLruCache(&Options{MaxBytes: 1 << 20, TrackHotKeys: true, DisableBackgroundCleanup: true})
+	defer c.Close()
+	for i := 0; i < 2000; i++ {
+		c.AddAndUpdateCache("k"+strconv.Itoa(i), testValue("v"))
+	}
+	c.AddAndUpdateCache("hot", testValue("v"))
+	c.AddAndUpdateCache("warm", testValue("v"))
+	for round := 0; round < 20; round++ {
+		for i := 0; i < 2000; i++ {
+			c.FindCache("k" + strconv.Itoa(i))
+		}
+		for i := 0; i < 50; i++ {
+			c.FindCache("hot")
+			c.FindCache("warm")
+		}
+		c.FindCache("hot")
+	}
+	top := c.TopK(2)
+	if len(top) != 2 || top[0].Key != "hot" || top[1].Key != "warm" {
+		t.Fatalf("TopK 的结果为 %v", top)
+	}
+	// count-min sketch 的估计值只会偏大
+	if top[0].Count < 1020 {
+		t.Fatalf("hot 的次数为 %d，至少应该为 1020", top[0].Count)
+	}
+	if n := len(c.TopK(-1)); n > hotKeysCapacity {
+		t.Fatalf("候选数量为 %d，超过了上限 %d", n, hotKeysCapacity)
+	}
+	c.ResetTopK()
+	if top := c.TopK(10); len(top) != 0 {
+		t.Fatalf("ResetTopK 之后应该为空，实际为 %v", top)
+	}
+}
+
+// 与 FindCache 并发调用时没有数据竞争，需要 -race 运行
+func TestTopKConcurrent(t *testing.T) {
+	c := NewShardedCache(&Options{MaxBytes: 1 << 20, TrackHotKeys: true, DisableBackgroundCleanup: true})
+	defer c.Close()
+	for i := 0; i < 300; i++ {
+		c.AddAndUpdateCache("k"+strconv.Itoa(i), testValue("v"))
+	}
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 3000; i++ {
+				c.FindCache("k" + strconv.Itoa((i*g)%300))
+				if i%500 == 0 {
+					c.TopK(5)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	if top := c.TopK(1); len(top) != 1 || top[0].Key != "k0" {
+		t.Fatalf("TopK 的结果为 %v", top)
+	}
+}
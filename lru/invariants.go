@@ -0,0 +1,54 @@
+package lru
+
+import "fmt"
+
+// checkInvariants 检查 LruCache 内部数据结构的一致性，发现问题时返回描述第一个问题的错误
+// 检查的内容：链表长度与 map 长度相等、每个链表节点都能通过 map 找到自身、currentBytes 等于所有条目大小之和、
+// expires 和 ttls 中没有不存在的key、标签反向索引与条目上的标签一致
+// 需要遍历所有条目，只用于测试和排查问题
+func (c *LruCache) checkInvariants() error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.list.Len() != len(c.items) {
+		return fmt.Errorf("链表长度 %d 与 map 长度 %d 不一致", c.list.Len(), len(c.items))
+	}
+	var bytes int64
+	tagged := 0
+	for elem := c.list.Front(); elem != nil; elem = elem.Next() {
+		entry := elem.Value.(*LruEntry)
+		if c.items[entry.key] != elem {
+			return fmt.Errorf("key %q 在 map 中对应的不是当前链表节点", entry.key)
+		}
+		bytes += c.sizeOf(entry.key, entry.value)
+		for _, tag := range entry.tags {
+			if _, ok := c.tagIndex[tag][entry.key]; !ok {
+				return fmt.Errorf("key %q 的标签 %q 不在反向索引中", entry.key, tag)
+			}
+			tagged++
+		}
+	}
+	if bytes != c.currentBytes {
+		return fmt.Errorf("currentBytes 为 %d，实际条目大小之和为 %d", c.currentBytes, bytes)
+	}
+	for key := range c.expires {
+		if _, ok := c.items[key]; !ok {
+			return fmt.Errorf("expires 中的 key %q 不存在", key)
+		}
+	}
+	for key := range c.ttls {
+		if _, ok := c.items[key]; !ok {
+			return fmt.Errorf("ttls 中的 key %q 不存在", key)
+		}
+	}
+	indexed := 0
+	for tag, keys := range c.tagIndex {
+		if len(keys) == 0 {
+			return fmt.Errorf("标签 %q 的key集合为空但没有删除", tag)
+		}
+		indexed += len(keys)
+	}
+	if indexed != tagged {
+		return fmt.Errorf("反向索引中有 %d 个标签记录，条目上有 %d 个", indexed, tagged)
+	}
+	return nil
+}
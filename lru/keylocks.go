@@ -0,0 +1,61 @@
+package lru
+
+import (
+	"sort"
+	"sync"
+)
+
+// 默认的锁分段数量
+const defaultKeyLockStripes = 256
+
+// KeyLocks 按 key 的哈希值分段的互斥锁，相同 key 的操作总是落在同一把锁上因而串行执行，
+// 不同的 key 大概率落在不同的锁上可以并发执行。分段数量是 2 的幂，与 ShardedCache 使用相同的 FNV-1a 哈希选择分段
+type KeyLocks struct {
+	locks []sync.Mutex
+	mask  uint32
+}
+
+// NewKeyLocks 创建 n 段锁，n 小于等于 0 时使用默认值 256，不是 2 的幂时向上取整
+func NewKeyLocks(n int) *KeyLocks {
+	if n <= 0 {
+		n = defaultKeyLockStripes
+	}
+	n = nextPowerOfTwo(n)
+	return &KeyLocks{
+		locks: make([]sync.Mutex, n),
+		mask:  uint32(n - 1),
+	}
+}
+
+// Lock 锁住 key 所在的分段
+func (l *KeyLocks) Lock(key string) {
+	l.locks[fnv32a(key)&l.mask].Lock()
+}
+
+// Unlock 释放 key 所在的分段
+func (l *KeyLocks) Unlock(key string) {
+	l.locks[fnv32a(key)&l.mask].Unlock()
+}
+
+// LockKeys 锁住多个 key 所在的全部分段，每个分段只锁一次，并且总是按照分段下标从小到大的顺序加锁，
+// 所以并发的 LockKeys 之间不会死锁；返回的函数用于释放这些分段
+func (l *KeyLocks) LockKeys(keys []string) (unlock func()) {
+	seen := make(map[uint32]bool, len(keys))
+	stripes := make([]int, 0, len(keys))
+	for _, key := range keys {
+		i := fnv32a(key) & l.mask
+		if !seen[i] {
+			seen[i] = true
+			stripes = append(stripes, int(i))
+		}
+	}
+	sort.Ints(stripes)
+	for _, i := range stripes {
+		l.locks[i].Lock()
+	}
+	return func() {
+		for j := len(stripes) - 1; j >= 0; j-- {
+			l.locks[stripes[j]].Unlock()
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package lru
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestNewKeyLocksRoundsUp(t *testing.T) {
+	if n := len(NewKeyLocks(10).locks); n != 16 {
+		t.Fatalf("分段数量应该向上取整为 16，实际为 %d", n)
+	}
+	if n := len(NewKeyLocks(0).locks); n != defaultKeyLockStripes {
+		t.Fatalf("默认分段数量应该为 %d，实际为 %d", defaultKeyLockStripes, n)
+	}
+}
+
+// 同一个key的读取、修改、写回在锁内串行执行，不会丢失更新；不同的key可以并发执行，使用 go test -race 运行
+func TestKeyLocksSerializeSameKey(t *testing.T) {
+	l := NewKeyLocks(8)
+	counts := make(map[string]int)
+	var mu sync.Mutex // 只保护 map 本身，计数的读取和写回之间不持有它
+	get := func(key string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return counts[key]
+	}
+	set := func(key string, n int) {
+		mu.Lock()
+		defer mu.Unlock()
+		counts[key] = n
+	}
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				for _, key := range []string{"hot", "k" + strconv.Itoa(g)} {
+					l.Lock(key)
+					set(key, get(key)+1)
+					l.Unlock(key)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	if n := get("hot"); n != 8*500 {
+		t.Fatalf("hot 应该被增加 %d 次，实际为 %d", 8*500, n)
+	}
+	for g := 0; g < 8; g++ {
+		if n := get("k" + strconv.Itoa(g)); n != 500 {
+			t.Fatalf("k%d 应该被增加 500 次，实际为 %d", g, n)
+		}
+	}
+}
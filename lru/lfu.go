@@ -0,0 +1,436 @@
+package lru
+
+import (
+	"Distributed-Cache-Go/metrics"
+	"container/list"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+// LfuCache 是一个 LFU（最不经常使用）缓存实现，实现了 Store 接口。
+// 每个访问频次对应一个双向链表，同一频次下链表头部是最久未被访问的元素，
+// 因此容量不足时淘汰访问次数最少的元素，访问次数相同时淘汰最久未被访问的元素。
+// 过期机制与 LruCache 保持一致。
+type LfuCache struct {
+	// 1.核心功能：数据存储、容量控制、并发控制
+	items         map[string]*list.Element // 键到链表节点的映射
+	freqs         map[int64]*list.List     // 访问频次到该频次链表的映射
+	minFreq       int64                    // 当前最小的访问频次
+	maxBytes      int64                    // 最大容量
+	maxEntries    int64                    // 最大条目数，0 表示不限制
+	maxValueBytes int64                    // 单个键值对的最大大小，0 表示不限制
+	currentBytes  int64                    // 当前已经使用的容量
+	mu            sync.RWMutex             // 读写锁
+	// 2.扩展功能：淘汰策略、过期机制
+	onEvicted  func(key string, value Value, reason EvictReason)
+	expires    map[string]time.Time
+	expiryHeap expiryHeap // 按过期时间排序的最小堆，清理时只需要查看已经过期的部分
+	defaultTTL time.Duration
+	ttlJitter  time.Duration
+	// 3.优化功能：后台清理协程、优雅关闭
+	cleanupInterval time.Duration
+	cleanTicker     *time.Ticker
+	closeChan       chan struct{}
+	closeOnce       sync.Once
+	wg              sync.WaitGroup // 后台清理协程，Close 等待它退出
+	// 日志输出
+	log *zap.Logger
+	// 时间来源，默认为系统时间
+	clk Clock
+	// 汇总输出淘汰日志
+	evictLog *evictionLog
+	// 自定义容量计算，为空时使用 len(key)+value.Len()
+	weigher func(key string, value Value) int64
+}
+
+// 内层条目结构体
+type LfuEntry struct {
+	key   string
+	value Value
+	freq  int64 // 访问次数
+}
+
+// 构造函数
+func NewLfuCache(opt *Options) *LfuCache {
+	withDefault(opt)
+	cache := &LfuCache{
+		items:           make(map[string]*list.Element),
+		freqs:           make(map[int64]*list.List),
+		maxBytes:        opt.MaxBytes,
+		maxEntries:      opt.MaxEntries,
+		maxValueBytes:   opt.MaxValueBytes,
+		onEvicted:       opt.OnEvicted,
+		expires:         make(map[string]time.Time),
+		defaultTTL:      opt.DefaultTTL,
+		ttlJitter:       opt.TTLJitter,
+		cleanupInterval: opt.CleanupInterval,
+		closeChan:       make(chan struct{}),
+		log:             opt.Logger,
+		clk:             opt.Clock,
+		evictLog:        newEvictionLog(opt),
+		weigher:         opt.Weigher,
+	}
+	if !opt.DisableBackgroundCleanup {
+		cache.startCleanUpRoutine()
+	}
+	return cache
+}
+
+func (c *LfuCache) startCleanUpRoutine() {
+	c.cleanTicker = time.NewTicker(c.cleanupInterval)
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		c.cleanupLoop()
+	}()
+}
+
+// 1.向缓存中新增/更新数据，过期时间使用默认的 defaultTTL
+func (c *LfuCache) AddAndUpdateCache(key string, value Value) error {
+	return c.AddWithTTL(key, value, c.defaultTTL)
+}
+
+// AddWithTTL 向缓存中新增/更新数据，并为该key单独设置过期时间，ttl<=0 表示永不过期
+func (c *LfuCache) AddWithTTL(key string, value Value, ttl time.Duration) error {
+	if value == nil {
+		return nil
+	}
+	if err := checkValueSize(key, c.sizeOf(key, value), c.maxValueBytes); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.set(key, value, ttl)
+}
+
+// AddIfAbsent 只有key不存在或者已经过期时才写入，检查和写入在同一次加写锁中完成，返回是否写入
+func (c *LfuCache) AddIfAbsent(key string, value Value, ttl time.Duration) (bool, error) {
+	if value == nil {
+		return false, nil
+	}
+	if err := checkValueSize(key, c.sizeOf(key, value), c.maxValueBytes); err != nil {
+		return false, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok {
+		if t, ok := c.expires[key]; !ok || !c.clk.Now().After(t) {
+			return false, nil
+		}
+		// 已经过期的旧数据先删除，再作为新的key写入
+		c.removeCache(elem, ReasonExpired)
+		metrics.Evictions.Inc()
+	}
+	if err := c.set(key, value, ttl); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// 新增/更新数据，调用此方法前必须持有锁
+func (c *LfuCache) set(key string, value Value, ttl time.Duration) error {
+	// key 已经存在时更新值，并且算作一次访问
+	if elem, ok := c.items[key]; ok {
+		entry := elem.Value.(*LfuEntry)
+		if err := checkCapacity(key, c.sizeOf(key, value), c.maxBytes); err != nil {
+			return err
+		}
+		delta := c.sizeOf(key, value) - c.sizeOf(entry.key, entry.value)
+		c.currentBytes += delta
+		metrics.Bytes.Add(float64(delta))
+		entry.value = value
+		c.increment(elem)
+	} else {
+		// 新的key没有可以保留的过期时间，使用默认过期时间
+		if ttl == KeepTTL {
+			ttl = c.defaultTTL
+		}
+		// 先为新元素腾出空间，避免刚插入的元素（访问次数为 1）被立即淘汰
+		c.evictCapacity(c.sizeOf(key, value), 1)
+		entry := &LfuEntry{key: key, value: value, freq: 1}
+		c.items[key] = c.freqList(1).PushBack(entry)
+		c.minFreq = 1
+		size := c.sizeOf(key, value)
+		c.currentBytes += size
+		metrics.Entries.Inc()
+		metrics.Bytes.Add(float64(size))
+	}
+	if ttl != KeepTTL {
+		c.createExpires(key, ttl)
+	}
+	c.evict()
+	return nil
+}
+
+// 获取某个频次对应的链表，不存在时创建
+func (c *LfuCache) freqList(freq int64) *list.List {
+	l, ok := c.freqs[freq]
+	if !ok {
+		l = list.New()
+		c.freqs[freq] = l
+	}
+	return l
+}
+
+// 将元素的访问次数加一，并移动到对应频次链表的尾部
+func (c *LfuCache) increment(elem *list.Element) {
+	entry := elem.Value.(*LfuEntry)
+	old := c.freqs[entry.freq]
+	old.Remove(elem)
+	if old.Len() == 0 {
+		delete(c.freqs, entry.freq)
+		if c.minFreq == entry.freq {
+			c.minFreq++
+		}
+	}
+	entry.freq++
+	c.items[entry.key] = c.freqList(entry.freq).PushBack(entry)
+}
+
+// 创建元素的超时时间，ttl<=0 时不记录过期时间，即永不过期
+func (c *LfuCache) createExpires(key string, ttl time.Duration) {
+	if ttl <= 0 {
+		delete(c.expires, key)
+		return
+	}
+	at := c.clk.Now().Add(jitterTTL(ttl, c.ttlJitter))
+	c.expires[key] = at
+	c.expiryHeap.push(c.expires, key, at)
+}
+
+// 2.根据key删除缓存中的数据
+func (c *LfuCache) DeleteCache(key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok {
+		c.removeCache(elem, ReasonDeleted)
+	}
+	return nil
+}
+
+// 3.查询缓存中的数据，命中时访问次数加一
+func (c *LfuCache) FindCache(key string) (Value, bool) {
+	// 访问会修改频次链表，所以直接使用写锁
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	if t, ok := c.expires[key]; ok && c.clk.Now().After(t) {
+		c.removeCache(elem, ReasonExpired)
+		metrics.Evictions.Inc()
+		return nil, false
+	}
+	c.increment(elem)
+	return elem.Value.(*LfuEntry).value, true
+}
+
+// Peek 查询缓存中的数据，但不会增加访问次数
+func (c *LfuCache) Peek(key string) (Value, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	if t, ok := c.expires[key]; ok && c.clk.Now().After(t) {
+		return nil, false
+	}
+	return elem.Value.(*LfuEntry).value, true
+}
+
+// Contains 判断key是否存在并且没有过期，不会影响淘汰顺序
+func (c *LfuCache) Contains(key string) bool {
+	_, ok := c.Peek(key)
+	return ok
+}
+
+// TTL 返回key剩余的过期时间，0 表示永不过期，key不存在或者已经过期时返回 false，不会影响淘汰顺序
+func (c *LfuCache) TTL(key string) (time.Duration, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if _, ok := c.items[key]; !ok {
+		return 0, false
+	}
+	t, ok := c.expires[key]
+	if !ok {
+		return 0, true
+	}
+	remaining := t.Sub(c.clk.Now())
+	if remaining <= 0 {
+		return 0, false
+	}
+	return remaining, true
+}
+
+// Range 遍历所有未过期的数据，f 返回 false 时停止遍历，遍历顺序不固定
+// 遍历期间持有读锁，f 中不能再调用该缓存的任何方法，否则可能造成死锁
+func (c *LfuCache) Range(f func(key string, value Value) bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	now := c.clk.Now()
+	for _, elem := range c.items {
+		entry := elem.Value.(*LfuEntry)
+		if t, ok := c.expires[entry.key]; ok && now.After(t) {
+			continue
+		}
+		if !f(entry.key, entry.value) {
+			return
+		}
+	}
+}
+
+func (c *LfuCache) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.items)
+}
+
+// Bytes 返回当前已经使用的容量
+func (c *LfuCache) Bytes() int64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.currentBytes
+}
+
+// 删除缓存中的数据，调用此方法前必须持有锁
+func (c *LfuCache) removeCache(elem *list.Element, reason EvictReason) {
+	entry := elem.Value.(*LfuEntry)
+	l := c.freqs[entry.freq]
+	l.Remove(elem)
+	if l.Len() == 0 {
+		delete(c.freqs, entry.freq)
+	}
+	delete(c.items, entry.key)
+	delete(c.expires, entry.key)
+	size := c.sizeOf(entry.key, entry.value)
+	c.currentBytes -= size
+	metrics.Entries.Dec()
+	metrics.Bytes.Sub(float64(size))
+	if c.onEvicted != nil {
+		c.onEvicted(entry.key, entry.value, reason)
+	}
+}
+
+// Clear 清空缓存中的所有数据，每个被删除的元素都会触发 onEvicted 回调
+func (c *LfuCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.onEvicted != nil {
+		for _, elem := range c.items {
+			entry := elem.Value.(*LfuEntry)
+			c.onEvicted(entry.key, entry.value, ReasonCleared)
+		}
+	}
+	metrics.Entries.Sub(float64(len(c.items)))
+	metrics.Bytes.Sub(float64(c.currentBytes))
+	c.items = make(map[string]*list.Element)
+	c.freqs = make(map[int64]*list.List)
+	c.expires = make(map[string]time.Time)
+	c.expiryHeap = nil
+	c.minFreq = 0
+	c.currentBytes = 0
+}
+
+// Resize 运行时修改最大容量，如果新的容量更小会立即淘汰数据，返回被淘汰的条目数
+func (c *LfuCache) Resize(maxBytes int64) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.maxBytes = maxBytes
+	before := len(c.items)
+	c.evict()
+	return before - len(c.items)
+}
+
+// 定期清理缓存的方法
+func (c *LfuCache) cleanupLoop() {
+	for {
+		select {
+		case <-c.cleanTicker.C:
+			c.mu.Lock()
+			count, bytes := c.evict()
+			c.mu.Unlock()
+			reportReclaimed(c.log, count, bytes)
+		case <-c.closeChan:
+			return
+		}
+	}
+}
+
+// evict 清理过期和超出内存限制的缓存，返回被清理的条目数和回收的字节数，调用此方法前必须持有锁
+func (c *LfuCache) evict() (int, int64) {
+	// 首先处理过期数据
+	before, beforeBytes := len(c.items), c.currentBytes
+	// 从过期堆中只取出已经过期的key，不需要遍历所有设置了过期时间的key
+	now := c.clk.Now()
+	for {
+		key, ok := c.expiryHeap.popExpired(c.expires, now)
+		if !ok {
+			break
+		}
+		if elem, ok := c.items[key]; ok {
+			c.removeCache(elem, ReasonExpired)
+			metrics.Evictions.Inc()
+		} else {
+			delete(c.expires, key)
+		}
+	}
+	// 容量淘汰的部分由 evictCapacity 自己记录
+	c.evictLog.record(before-len(c.items), beforeBytes-c.currentBytes)
+	c.evictCapacity(0, 0)
+	return before - len(c.items), beforeBytes - c.currentBytes
+}
+
+// 淘汰访问次数最少的数据，直到再加入 extraBytes 字节、extraEntries 个条目后不超过容量限制，调用此方法前必须持有锁
+func (c *LfuCache) evictCapacity(extraBytes, extraEntries int64) {
+	before, beforeBytes := len(c.items), c.currentBytes
+	defer func() {
+		c.evictLog.record(before-len(c.items), beforeBytes-c.currentBytes)
+	}()
+	for len(c.items) > 0 {
+		overBytes := c.maxBytes > 0 && c.currentBytes+extraBytes > c.maxBytes
+		overEntries := c.maxEntries > 0 && int64(len(c.items))+extraEntries > c.maxEntries
+		if !overBytes && !overEntries {
+			return
+		}
+		l, ok := c.freqs[c.minFreq]
+		if !ok {
+			// 过期清理或删除可能使 minFreq 失效，需要重新计算
+			c.minFreq = c.findMinFreq()
+			l = c.freqs[c.minFreq]
+		}
+		c.removeCache(l.Front(), ReasonCapacity)
+		metrics.Evictions.Inc()
+	}
+}
+
+// 重新计算当前最小的访问频次
+func (c *LfuCache) findMinFreq() int64 {
+	var min int64 = -1
+	for freq := range c.freqs {
+		if min == -1 || freq < min {
+			min = freq
+		}
+	}
+	return min
+}
+
+// Close 关闭缓存，停止清理协程
+func (c *LfuCache) Close() {
+	// 使用 sync.Once 保证重复调用 Close 不会重复关闭 closeChan 导致 panic
+	c.closeOnce.Do(func() {
+		if c.cleanTicker != nil {
+			c.cleanTicker.Stop()
+		}
+		close(c.closeChan)
+		c.wg.Wait()
+		c.evictLog.flush()
+	})
+}
+
+// 键值对占用的容量
+func (c *LfuCache) sizeOf(key string, value Value) int64 {
+	return entrySize(c.weigher, key, value)
+}
@@ -0,0 +1,45 @@
+package lru
+
+import (
+	"Distributed-Cache-Go/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"testing"
+	"time"
+)
+
+// 容量不足时淘汰访问次数最少的key
+func TestLfuEviction(t *testing.T) {
+	c := NewLfuCache(&Options{MaxBytes: 6, DisableBackgroundCleanup: true})
+	defer c.Close()
+	for _, key := range []string{"a", "b", "c"} {
+		c.AddAndUpdateCache(key, testValue("1"))
+	}
+	c.FindCache("a")
+	c.FindCache("a")
+	c.FindCache("c")
+	c.AddAndUpdateCache("d", testValue("1"))
+	if _, ok := c.FindCache("b"); ok {
+		t.Fatal("访问次数最少的 b 应该被淘汰")
+	}
+	for _, key := range []string{"a", "c", "d"} {
+		if _, ok := c.FindCache(key); !ok {
+			t.Fatalf("%s 不应该被淘汰", key)
+		}
+	}
+}
+
+// FindCache 删除已经过期的key时与其他 Store 一样计入淘汰指标
+func TestLfuExpiredFindCountsEviction(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	c := NewLfuCache(&Options{MaxBytes: 100, Clock: clock, DisableBackgroundCleanup: true})
+	defer c.Close()
+	c.AddWithTTL("a", testValue("1"), time.Second)
+	clock.Advance(2 * time.Second)
+	before := testutil.ToFloat64(metrics.Evictions)
+	if _, ok := c.FindCache("a"); ok {
+		t.Fatal("已经过期的key不应该命中")
+	}
+	if n := testutil.ToFloat64(metrics.Evictions) - before; n != 1 {
+		t.Fatalf("淘汰指标应该增加 1，实际增加了 %v", n)
+	}
+}
@@ -1,10 +1,15 @@
 package lru
 
 import (
+	"Distributed-Cache-Go/metrics"
+	"container/heap"
 	"container/list"
 	"fmt"
 	"go.uber.org/zap"
+	"runtime"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -18,42 +23,112 @@ import (
 // 外层容器结构体
 type LruCache struct {
 	// 1.首先是核心功能：数据存储、容量控制、并发控制
-	list         *list.List               // 双向链表，用于维护lru顺序
-	items        map[string]*list.Element // 键到链表节点的映射
-	maxBytes     int64                    // 最大容量
-	currentBytes int64                    // 当前已经使用的容量
-	mu           sync.RWMutex             // 读写锁
+	list          *list.List               // 双向链表，用于维护lru顺序
+	items         map[string]*list.Element // 键到链表节点的映射
+	maxBytes      int64                    // 最大容量
+	maxEntries    int64                    // 最大条目数，0 表示不限制
+	maxValueBytes int64                    // 单个键值对的最大大小，0 表示不限制
+	currentBytes  int64                    // 当前已经使用的容量
+	mu            sync.RWMutex             // 读写锁
 	// 2.其次是扩展功能：淘汰策略、过期机制
-	onEvicted func(key string, value Value) // 作为扩展点，初期可以设置为nil，后续按需实现
-	expires   map[string]time.Time          // 为每个键值对存储过期时间，支持自动清理（TTL）
+	onEvicted  func(key string, value Value, reason EvictReason) // 作为扩展点，初期可以设置为nil，后续按需实现
+	expires    map[string]time.Time                              // 为每个键值对存储过期时间，支持自动清理（TTL），永不过期的键不在其中
+	expiryHeap expiryHeap                                        // 按过期时间排序的最小堆，清理时只需要查看已经过期的部分
+	defaultTTL time.Duration                                     // 未单独指定过期时间的键值对使用的过期时间，NoExpiration 表示永不过期
+	ttlJitter  time.Duration                                     // 过期时间的随机抖动范围
+	sliding    bool                                              // 是否开启滑动过期，开启后每次命中都会刷新过期时间
+	ttls       map[string]time.Duration                          // 滑动过期模式下每个键值对的 ttl，用于命中时刷新过期时间
+	tagIndex   map[string]map[string]struct{}                    // 标签到key集合的反向索引，只包含带标签的key
 	// 3.最后是优化功能：后台清理协程、优雅关闭、监控统计（命中率、吞吐量）
-	cleanupInterval time.Duration // 后台自动清理过期键值对 的时间间隔参数
-	cleanTicker     *time.Ticker  // 自动清理过期键值对的定时
-	closeChan       chan struct{} // 用于优雅关闭清理协程
+	cleanupInterval time.Duration      // 后台自动清理过期键值对 的时间间隔参数
+	cleanTicker     *time.Ticker       // 自动清理过期键值对的定时
+	closeChan       chan struct{}      // 用于优雅关闭清理协程
+	closeOnce       sync.Once          // 保证 Close 只执行一次
+	wg              sync.WaitGroup     // 后台清理协程和延迟提升协程，Close 等待它们退出
+	hotKeys         *hotKeys           // 热点key统计，没有开启 TrackHotKeys 时为空
+	samples         int                // 采样淘汰每次随机查看的条目数，0 表示严格按照 LRU 顺序淘汰
+	clock           int64              // 采样淘汰使用的逻辑时钟，每次访问加一，比 time.Now 开销更小
+	promotions      chan *list.Element // 延迟提升模式下等待移动到队尾的元素，为空表示每次命中都立即移动
+	hits            int64              // FindCache 的命中次数，需要原子读写
+	misses          int64              // FindCache 的未命中次数（包括已经过期的key），需要原子读写
+	expirations     int64              // 因为过期被删除的条目数，在写锁下修改，Stats 原子读取
+	version         uint64             // 最近一次分配的版本号，每次写入加一，在写锁下修改
 	// 日志输出
 	log *zap.Logger
+	// 时间来源，默认为系统时间
+	clk Clock
+	// 汇总输出淘汰日志
+	evictLog *evictionLog
+	// 自定义容量计算，为空时使用 len(key)+value.Len()
+	weigher func(key string, value Value) int64
+	// 后台清理和 Resize 每次获取写锁最多删除的条目数，0 表示不限制
+	evictBatch int
+	// Scan 使用的按哈希值排序的索引，由 scanMu 保护；scanDirty 表示之后有新增的key，在写锁下设置
+	scanMu    sync.Mutex
+	scanIndex []scanItem
+	scanDirty bool
 }
 
 // 内层条目结构体
 type LruEntry struct {
-	key   string
-	value Value
+	key      string
+	value    Value
+	accessed int64 // 最近一次访问的逻辑时间，只在采样淘汰模式下使用，需要原子读写
+	// 统计信息，通过 Stat 查询，lastAccess 和 hits 在读锁下更新，需要原子读写
+	insertedAt time.Time // 插入的时间，更新值不会改变
+	lastAccess int64     // 最近一次命中的时间（Unix 纳秒），0 表示还没有被访问过
+	hits       int64     // 命中次数
+	version    uint64    // 版本号，每次写入都会分配一个新的、单调递增的版本号，用于 CompareAndSwap
+	hash       uint32    // key 的 FNV-1a 哈希值，Scan 按照它的顺序遍历
+	tags       []string  // 写入时附加的标签，InvalidateTag 通过反向索引找到它
 }
 
 // 构造函数
 func NewLruCache(opt *Options) *LruCache {
+	return newLruCache(opt, 0)
+}
+
+// newLruCache 创建 LruCache，后台清理协程在 cleanupDelay 之后才开始按 CleanupInterval 定期清理，
+// ShardedCache 通过它错开各个分片的清理时间
+func newLruCache(opt *Options, cleanupDelay time.Duration) *LruCache {
 	withDefault(opt)
 	cache := &LruCache{
 		list:            list.New(),
 		items:           make(map[string]*list.Element),
 		maxBytes:        opt.MaxBytes,
+		maxEntries:      opt.MaxEntries,
+		maxValueBytes:   opt.MaxValueBytes,
 		currentBytes:    0,
 		onEvicted:       opt.OnEvicted,
 		expires:         make(map[string]time.Time),
+		defaultTTL:      opt.DefaultTTL,
+		ttlJitter:       opt.TTLJitter,
+		samples:         opt.EvictionSamples,
+		sliding:         opt.SlidingExpiration,
+		ttls:            make(map[string]time.Duration),
+		tagIndex:        make(map[string]map[string]struct{}),
 		cleanupInterval: opt.CleanupInterval,
 		closeChan:       make(chan struct{}),
+		log:             opt.Logger,
+		clk:             opt.Clock,
+		evictLog:        newEvictionLog(opt),
+		weigher:         opt.Weigher,
+		evictBatch:      opt.EvictionBatchSize,
+	}
+	if opt.TrackHotKeys {
+		cache.hotKeys = newHotKeys()
+	}
+	if opt.PromotionBuffer > 0 {
+		cache.promotions = make(chan *list.Element, opt.PromotionBuffer)
+		cache.wg.Add(1)
+		go func() {
+			defer cache.wg.Done()
+			cache.promotionLoop()
+		}()
+	}
+	if !opt.DisableBackgroundCleanup {
+		cache.startCleanUpRoutine(cleanupDelay)
 	}
-	cache.startCleanUpRoutine()
 	return cache
 }
 func withDefault(opt *Options) {
@@ -61,14 +136,38 @@ func withDefault(opt *Options) {
 		opt.CleanupInterval = time.Minute
 	}
 	if opt.MaxBytes <= 0 {
-		opt.MaxBytes = 8 * 1024 * 1024
+		opt.MaxBytes = defaultMaxBytes
+	}
+	if opt.Logger == nil {
+		opt.Logger = zap.NewNop()
+	}
+	if opt.Clock == nil {
+		opt.Clock = realClock{}
+	}
+	if opt.EvictionLogInterval == 0 {
+		opt.EvictionLogInterval = defaultEvictionLogInterval
 	}
 }
 
-func (c *LruCache) startCleanUpRoutine() {
+func (c *LruCache) startCleanUpRoutine(delay time.Duration) {
 	// 启动定期清理数据协程
 	c.cleanTicker = time.NewTicker(c.cleanupInterval)
+	c.wg.Add(1)
 	go func() {
+		defer c.wg.Done()
+		if delay > 0 {
+			select {
+			case <-time.After(delay):
+			case <-c.closeChan:
+				return
+			}
+			// 从现在开始重新计时，丢弃等待期间可能已经触发的那一次
+			c.cleanTicker.Reset(c.cleanupInterval)
+			select {
+			case <-c.cleanTicker.C:
+			default:
+			}
+		}
 		err := c.cleanupLoop()
 		if err != nil {
 			c.log.Error(err.Error())
@@ -76,32 +175,208 @@ func (c *LruCache) startCleanUpRoutine() {
 	}()
 }
 
-// 1.向缓存中新增/更新数据
-// 分为两种情况：一种是需要更新 一种是需要添加
+// 1.向缓存中新增/更新数据，过期时间使用默认的 defaultTTL
 func (c *LruCache) AddAndUpdateCache(key string, value Value) error {
+	return c.AddWithTTL(key, value, c.defaultTTL)
+}
+
+// AddWithTTL 向缓存中新增/更新数据，并为该key单独设置过期时间，ttl<=0（NoExpiration）表示永不过期
+// 分为两种情况：一种是需要更新 一种是需要添加
+func (c *LruCache) AddWithTTL(key string, value Value, ttl time.Duration) error {
 	if value == nil {
 		return nil
 	}
+	if err := checkValueSize(key, c.sizeOf(key, value), c.maxValueBytes); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.set(key, value, ttl)
+}
+
+// AddIfAbsent 只有key不存在或者已经过期时才写入，检查和写入在同一次加写锁中完成，返回是否写入
+func (c *LruCache) AddIfAbsent(key string, value Value, ttl time.Duration) (bool, error) {
+	if value == nil {
+		return false, nil
+	}
+	if err := checkValueSize(key, c.sizeOf(key, value), c.maxValueBytes); err != nil {
+		return false, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok {
+		if t, ok := c.expires[key]; !ok || !c.clk.Now().After(t) {
+			return false, nil
+		}
+		// 已经过期的旧数据先删除，再作为新的key写入
+		_ = c.removeCache(elem, ReasonExpired)
+		metrics.Evictions.Inc()
+	}
+	if err := c.set(key, value, ttl); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// GetSet 写入新的值并返回旧的值，读取和替换在同一次加写锁中完成，过期时间使用默认的 defaultTTL
+// key 不存在或者已经过期时 existed 返回 false；写入失败（例如超过 MaxValueBytes）时返回错误，
+// 此时缓存中仍然是旧的值，old 和 existed 描述的就是这个仍然存在的旧值
+func (c *LruCache) GetSet(key string, value Value) (old Value, existed bool, err error) {
+	if value == nil {
+		return nil, false, nil
+	}
+	sizeErr := checkValueSize(key, c.sizeOf(key, value), c.maxValueBytes)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok {
+		if t, ok := c.expires[key]; ok && c.clk.Now().After(t) {
+			_ = c.removeCache(elem, ReasonExpired)
+			metrics.Evictions.Inc()
+		} else {
+			old, existed = elem.Value.(*LruEntry).value, true
+		}
+	}
+	if sizeErr != nil {
+		return old, existed, sizeErr
+	}
+	if err := c.set(key, value, c.defaultTTL); err != nil {
+		return old, existed, err
+	}
+	return old, existed, nil
+}
+
+// LoadOrStore 与 sync.Map.LoadOrStore 类似：key 存在并且没有过期时返回已有的值，loaded 为 true；
+// 否则写入 value（过期时间使用默认的 defaultTTL）并返回 value，loaded 为 false。查找和写入在同一次加写锁中完成
+// value 超过 MaxValueBytes 等原因写入失败时只记录日志，仍然返回 value 和 false
+func (c *LruCache) LoadOrStore(key string, value Value) (actual Value, loaded bool) {
+	if value == nil {
+		return nil, false
+	}
+	if err := checkValueSize(key, c.sizeOf(key, value), c.maxValueBytes); err != nil {
+		c.log.Error("LoadOrStore 写入失败", zap.String("key", key), zap.Error(err))
+		return value, false
+	}
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok {
+		if t, ok := c.expires[key]; !ok || !c.clk.Now().After(t) {
+			if c.samples == 0 {
+				c.list.MoveToBack(elem)
+			}
+			return elem.Value.(*LruEntry).value, true
+		}
+		_ = c.removeCache(elem, ReasonExpired)
+		metrics.Evictions.Inc()
+	}
+	if err := c.set(key, value, c.defaultTTL); err != nil {
+		c.log.Error("LoadOrStore 写入失败", zap.String("key", key), zap.Error(err))
+	}
+	return value, false
+}
+
+// GetWithVersion 返回key的值以及当前的版本号，不会影响淘汰顺序和命中统计，key不存在或者已经过期时返回 false
+func (c *LruCache) GetWithVersion(key string) (Value, uint64, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, 0, false
+	}
+	if t, ok := c.expires[key]; ok && c.clk.Now().After(t) {
+		return nil, 0, false
+	}
+	entry := elem.Value.(*LruEntry)
+	return entry.value, entry.version, true
+}
+
+// CompareAndSwap 只有key存在、没有过期并且版本号仍然等于 expectedVersion 时才写入 value，返回是否写入
+// 写入成功后版本号会变化，过期时间使用默认的 defaultTTL；比较和写入在同一次加写锁中完成
+func (c *LruCache) CompareAndSwap(key string, expectedVersion uint64, value Value) bool {
+	if value == nil {
+		return false
+	}
+	if err := checkValueSize(key, c.sizeOf(key, value), c.maxValueBytes); err != nil {
+		c.log.Error("CompareAndSwap 写入失败", zap.String("key", key), zap.Error(err))
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.items[key]
+	if !ok || elem.Value.(*LruEntry).version != expectedVersion {
+		return false
+	}
+	if t, ok := c.expires[key]; ok && c.clk.Now().After(t) {
+		return false
+	}
+	if err := c.set(key, value, c.defaultTTL); err != nil {
+		c.log.Error("CompareAndSwap 写入失败", zap.String("key", key), zap.Error(err))
+		return false
+	}
+	return true
+}
+
+// RemoveOldest 删除并返回链表头部（最近最少使用）的条目，缓存为空时返回 false，用于在外部实现自定义的淘汰策略
+// 头部已经过期的条目会先以 ReasonExpired 删除并跳过，返回的条目以 ReasonCapacity 触发 onEvicted
+// 采样淘汰模式下链表顺序只代表写入顺序
+func (c *LruCache) RemoveOldest() (key string, value Value, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.promotions != nil {
+		c.drainPromotions()
+	}
+	now := c.clk.Now()
+	for elem := c.list.Front(); elem != nil; elem = c.list.Front() {
+		entry := elem.Value.(*LruEntry)
+		if t, ok := c.expires[entry.key]; ok && now.After(t) {
+			_ = c.removeCache(elem, ReasonExpired)
+			metrics.Evictions.Inc()
+			continue
+		}
+		_ = c.removeCache(elem, ReasonCapacity)
+		metrics.Evictions.Inc()
+		return entry.key, entry.value, true
+	}
+	return "", nil, false
+}
+
+// 新增/更新数据，调用此方法前必须持有锁
+func (c *LruCache) set(key string, value Value, ttl time.Duration) error {
 	// 首先应该先判断key是否在缓存中已经存在了，如果存在了，则更新该key的内容
 	if elem, ok := c.items[key]; ok {
 		err := c.update(elem, value)
 		if err != nil {
 			c.log.Error(err.Error())
-			return fmt.Errorf("AddAndUpdateCache 更新失败:%v", err.Error())
+			return fmt.Errorf("AddAndUpdateCache 更新失败:%w", err)
+		}
+		// 覆盖写入时原来的标签失效，需要标签时由 AddWithTags 重新设置
+		c.setTags(elem.Value.(*LruEntry), nil)
+		// 更新数据的同时刷新过期时间，KeepTTL 时保留原来的过期时间
+		if ttl != KeepTTL {
+			c.createExpires(key, ttl)
+		}
+		// 更新后的值可能更大，需要从list头部淘汰较旧的数据
+		_, _, err = c.evict()
+		if err != nil {
+			c.log.Error(err.Error())
+			return fmt.Errorf("AddAndUpdateCache 删除超过容量或者过期的数据报错:%v", err.Error())
 		}
 		return nil
 	}
 
 	// 如果不存在话，将新数据添加到缓存中
+	if ttl == KeepTTL {
+		ttl = c.defaultTTL
+	}
 	c.add(key, value)
 	// 更新一下当前的容量
-	c.currentBytes += int64(len(key) + value.Len())
+	size := c.sizeOf(key, value)
+	c.currentBytes += size
+	metrics.Entries.Inc()
+	metrics.Bytes.Add(float64(size))
 	// 重新设置该key对应的失效时间映射关系
-	c.createExpires(key)
+	c.createExpires(key, ttl)
 	// 清理一下超时的缓存数据和处理一下存储空间不足的问题
-	err := c.evict()
+	_, _, err := c.evict()
 	if err != nil {
 		c.log.Error(err.Error())
 		return fmt.Errorf("AddAndUpdateCache 删除超过容量或者过期的数据报错:%v", err.Error())
@@ -110,33 +385,53 @@ func (c *LruCache) AddAndUpdateCache(key string, value Value) error {
 }
 func (c *LruCache) add(key string, value Value) {
 	// 首先我需要将该元素插入到list的尾部
+	c.version++
 	entry := &LruEntry{
-		key:   key,
-		value: value,
+		key:        key,
+		value:      value,
+		accessed:   c.tick(),
+		insertedAt: c.clk.Now(),
+		version:    c.version,
+		hash:       fnv32a(key),
 	}
-	backElem := c.list.PushBack(&entry)
+	backElem := c.list.PushBack(entry)
+	c.scanDirty = true
 	// 然后获取这个元素插入到map映射中
 	c.items[key] = backElem
 }
 
 // 更新key对应的值，并且将该元素放到list的尾部
+// 更新后总容量超出最大容量时，由调用方执行 evict 从list头部淘汰较旧的数据来腾出空间，
+// 只有这一个键值对本身就超过最大容量时才拒绝更新，此时保留旧值并且 currentBytes 不变
 func (c *LruCache) update(elem *list.Element, value Value) error {
 	entry := elem.Value.(*LruEntry)
-	// 首先需要判断一下更新后的容量大小是否已经超过了最大容量
-	cbytes := c.currentBytes + int64(value.Len()-entry.value.Len())
-	if cbytes > c.maxBytes {
-		return fmt.Errorf("update 更新过后的存储大小超过最大容量，无法更新")
+	if err := checkCapacity(entry.key, c.sizeOf(entry.key, value), c.maxBytes); err != nil {
+		return err
 	}
-	c.currentBytes += int64(value.Len() - entry.value.Len())
+	delta := c.sizeOf(entry.key, value) - c.sizeOf(entry.key, entry.value)
+	c.currentBytes += delta
+	metrics.Bytes.Add(float64(delta))
 	entry.value = value
+	c.version++
+	entry.version = c.version
+	atomic.StoreInt64(&entry.accessed, c.tick())
 	c.list.MoveToBack(elem)
 	return nil
 }
 
-// 创建元素的超时时间  什么时候超时
-func (c *LruCache) createExpires(key string) {
-	resultExp := time.Now().Add(c.cleanupInterval)
+// 创建元素的超时时间  什么时候超时，ttl<=0 时不记录过期时间，即永不过期
+func (c *LruCache) createExpires(key string, ttl time.Duration) {
+	if ttl <= 0 {
+		delete(c.expires, key)
+		delete(c.ttls, key)
+		return
+	}
+	if c.sliding {
+		c.ttls[key] = ttl
+	}
+	resultExp := c.clk.Now().Add(jitterTTL(ttl, c.ttlJitter))
 	c.expires[key] = resultExp
+	c.expiryHeap.push(c.expires, key, resultExp)
 }
 
 // 2.根据key删除缓存中的数据
@@ -144,7 +439,7 @@ func (c *LruCache) DeleteCache(key string) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	if element, ok := c.items[key]; ok {
-		err := c.removeCache(element)
+		err := c.removeCache(element, ReasonDeleted)
 		if err != nil {
 			c.log.Error("DeleteCache 删除节点报错")
 			return fmt.Errorf("DeleteCache 删除节点报错:%v", err.Error())
@@ -153,74 +448,407 @@ func (c *LruCache) DeleteCache(key string) error {
 	return nil
 }
 
+// DeletePrefix 删除所有以 prefix 开头的key，每个被删除的元素都会触发 onEvicted 回调，返回删除的条目数
+// 整个过程持有写锁，需要遍历所有的key
+func (c *LruCache) DeletePrefix(prefix string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for key, elem := range c.items {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		if err := c.removeCache(elem, ReasonDeleted); err != nil {
+			c.log.Error("DeletePrefix 删除节点报错", zap.String("key", key), zap.Error(err))
+			continue
+		}
+		n++
+	}
+	return n
+}
+
 // 4.查询缓存中的数据
 func (c *LruCache) FindCache(key string) (Value, bool) {
+	value, _, ok := c.find(key, true)
+	return value, ok
+}
+
+// GetNoPromote 查询缓存中的数据，与 FindCache 一样计入命中统计（Stats、Stat 和 TopK），但不会把元素移动到队尾，
+// 也不会刷新滑动过期时间，适合不应该打乱淘汰顺序的大批量分析扫描；与 Peek 的区别是 Peek 不计入任何统计
+func (c *LruCache) GetNoPromote(key string) (Value, bool) {
+	value, _, ok := c.find(key, false)
+	return value, ok
+}
+
+// find 查询缓存中的数据并记录命中统计，promote 为 false 时不影响淘汰顺序
+// 同时返回与 value 在同一次加锁中读取的剩余过期时间，0 表示永不过期；滑动过期刷新之后返回刷新后的剩余时间
+func (c *LruCache) find(key string, promote bool) (Value, time.Duration, bool) {
 	// 首先应该先确认key是否存在并且判断key是否超时了，如果存在且没有超时则取出来，并且将该元素放到列表尾部，如果不存在或者超时了，则查询数据库
 	c.mu.RLock()
 	element, ok := c.items[key]
 	if !ok {
 		c.mu.RUnlock()
-		return nil, false
+		atomic.AddInt64(&c.misses, 1)
+		return nil, 0, false
 	}
 	// 判断该元素是否超时
 	// 获取超时时间与当前时间作比较
-	if t, ok := c.expires[key]; ok && time.Now().After(t) {
+	if t, ok := c.expires[key]; ok && c.clk.Now().After(t) {
 		c.mu.RUnlock()
-		// 直接删除这个key并且返回
-		go func() {
-			err := c.DeleteCache(key)
-			if err != nil {
-				return
-			}
-		}()
+		// 已经过期，返回未命中之前同步删除这个key
+		atomic.AddInt64(&c.misses, 1)
+		c.expireOnRead(key, element)
+		return nil, 0, false
+	}
+	entry := element.Value.(*LruEntry)
+	value := entry.value
+	var remaining time.Duration
+	if t, ok := c.expires[key]; ok {
+		// 还没有过期但剩余时间恰好为 0 时返回最小的正数，避免与永不过期混淆
+		if remaining = t.Sub(c.clk.Now()); remaining <= 0 {
+			remaining = time.Nanosecond
+		}
+	}
+	atomic.AddInt64(&c.hits, 1)
+	atomic.AddInt64(&entry.hits, 1)
+	atomic.StoreInt64(&entry.lastAccess, c.clk.Now().UnixNano())
+	if c.samples > 0 && promote {
+		// 采样淘汰模式下只记录访问时间，不需要获取写锁移动链表节点
+		atomic.StoreInt64(&entry.accessed, c.tick())
 	}
-	value := element.Value.(LruEntry).value
 	c.mu.RUnlock()
+	if c.hotKeys != nil {
+		c.hotKeys.touch(key)
+	}
+	if !promote {
+		return value, remaining, true
+	}
+	if c.samples > 0 && !c.sliding {
+		return value, remaining, true
+	}
+	// 延迟提升模式下只把元素放入队列，由后台协程批量移动，读取时不需要获取写锁
+	if c.promotions != nil && !c.sliding {
+		select {
+		case c.promotions <- element:
+		default:
+			// 队列已满时丢弃这次提升，只会让淘汰顺序稍有偏差
+		}
+		return value, remaining, true
+	}
 	// 将当前访问到的元素移动到list的队尾，移动时，需要设置写锁
 	c.mu.Lock()
 	// 再次检查元素是否仍然存在（可能在获取写锁期间被其他协程删除）
-	if _, ok := c.items[key]; ok {
-		c.list.MoveToBack(element)
+	if elem, ok := c.items[key]; ok && elem == element {
+		if c.samples == 0 {
+			c.list.MoveToBack(element)
+		}
+		// 滑动过期模式下，每次命中都把过期时间重新设置为 now + ttl
+		if ttl, ok := c.ttls[key]; ok && c.sliding {
+			c.createExpires(key, ttl)
+			remaining = c.expires[key].Sub(c.clk.Now())
+		}
 	}
 	c.mu.Unlock()
-	return value, true
+	return value, remaining, true
+}
+
+// Touch 把已经存在的key的过期时间重新设置为 now + ttl，ttl<=0（NoExpiration）表示永不过期，同时把该key移动到队尾
+// 不会读取或者返回value，也不计入命中统计；key不存在或者已经过期时返回 false
+func (c *LruCache) Touch(key string, ttl time.Duration) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.items[key]
+	if !ok {
+		return false
+	}
+	if t, ok := c.expires[key]; ok && c.clk.Now().After(t) {
+		return false
+	}
+	c.createExpires(key, ttl)
+	if c.samples > 0 {
+		atomic.StoreInt64(&elem.Value.(*LruEntry).accessed, c.tick())
+	} else {
+		c.list.MoveToBack(elem)
+	}
+	return true
+}
+
+// expireOnRead 删除读取时发现已经过期的元素，调用方不能持有锁
+// 读锁不能直接升级为写锁，所以获取写锁后再次检查：只有该key对应的仍然是同一个元素并且确实已经过期时才删除，
+// 这样并发读取同一个过期key时只会删除一次，也不会误删在此期间被重新写入的新值
+func (c *LruCache) expireOnRead(key string, element *list.Element) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok && elem == element {
+		if t, ok := c.expires[key]; ok && c.clk.Now().After(t) {
+			_ = c.removeCache(elem, ReasonExpired)
+			metrics.Evictions.Inc()
+		}
+	}
+}
+
+// GetWithTTL 查询缓存中的数据并返回剩余的过期时间，永不过期的key返回 0，对淘汰顺序的影响与 FindCache 一致
+// 值和过期时间在同一次加锁中读取，不会出现读到值之后key被删除、返回永不过期的情况
+func (c *LruCache) GetWithTTL(key string) (Value, time.Duration, bool) {
+	return c.find(key, true)
+}
+
+// TopK 返回自上次 ResetTopK 以来通过 FindCache 命中次数最多的 n 个key，按次数从高到低排列
+// 次数是 count-min sketch 的估计值，可能略微偏大；最多跟踪 128 个热点key，n 大于它时只返回这么多
+// 没有开启 Options.TrackHotKeys 时返回空
+func (c *LruCache) TopK(n int) []KeyCount {
+	if c.hotKeys == nil {
+		return nil
+	}
+	return c.hotKeys.topK(n)
+}
+
+// ResetTopK 清空热点key统计
+func (c *LruCache) ResetTopK() {
+	if c.hotKeys != nil {
+		c.hotKeys.reset()
+	}
 }
+
+// DumpKeys 在读锁下对所有未过期的key做一次快照，按照从最早被淘汰到最晚被淘汰的顺序排列，用于运维排查
+// 采样淘汰模式下链表顺序只代表写入顺序，Rank 不再等同于淘汰顺序
+func (c *LruCache) DumpKeys() []KeyInfo {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	now := c.clk.Now()
+	keys := make([]KeyInfo, 0, c.list.Len())
+	for elem := c.list.Front(); elem != nil; elem = elem.Next() {
+		entry := elem.Value.(*LruEntry)
+		info := KeyInfo{
+			Key:  entry.key,
+			Size: c.sizeOf(entry.key, entry.value),
+			Rank: len(keys),
+		}
+		if t, ok := c.expires[entry.key]; ok {
+			info.TTL = t.Sub(now)
+			if info.TTL <= 0 {
+				continue
+			}
+		}
+		keys = append(keys, info)
+	}
+	return keys
+}
+
+// Stats 返回 FindCache 的命中统计、过期删除的条目数以及当前的条目数和容量
+func (c *LruCache) Stats() CacheStats {
+	stats := CacheStats{
+		Hits:        atomic.LoadInt64(&c.hits),
+		Misses:      atomic.LoadInt64(&c.misses),
+		Expirations: atomic.LoadInt64(&c.expirations),
+	}
+	stats.computeHitRatio()
+	c.mu.RLock()
+	stats.Entries = c.list.Len()
+	stats.Bytes = c.currentBytes
+	c.mu.RUnlock()
+	return stats
+}
+
+// ResetStats 将命中、未命中和过期删除的次数清零，返回清零之前的统计信息
+func (c *LruCache) ResetStats() CacheStats {
+	stats := CacheStats{
+		Hits:        atomic.SwapInt64(&c.hits, 0),
+		Misses:      atomic.SwapInt64(&c.misses, 0),
+		Expirations: atomic.SwapInt64(&c.expirations, 0),
+	}
+	stats.computeHitRatio()
+	c.mu.RLock()
+	stats.Entries = c.list.Len()
+	stats.Bytes = c.currentBytes
+	c.mu.RUnlock()
+	return stats
+}
+
+// Stat 返回key的统计信息，不会影响淘汰顺序，key不存在或者已经过期时返回 false
+func (c *LruCache) Stat(key string) (EntryStat, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	elem, ok := c.items[key]
+	if !ok {
+		return EntryStat{}, false
+	}
+	entry := elem.Value.(*LruEntry)
+	stat := EntryStat{
+		Size:       c.sizeOf(entry.key, entry.value),
+		InsertedAt: entry.insertedAt,
+		Hits:       atomic.LoadInt64(&entry.hits),
+	}
+	if at := atomic.LoadInt64(&entry.lastAccess); at > 0 {
+		stat.LastAccess = time.Unix(0, at)
+	}
+	if t, ok := c.expires[key]; ok {
+		stat.TTL = t.Sub(c.clk.Now())
+		if stat.TTL <= 0 {
+			return EntryStat{}, false
+		}
+	}
+	return stat, true
+}
+
+// Peek 查询缓存中的数据，但不会将元素移动到list的队尾，不影响淘汰顺序
+func (c *LruCache) Peek(key string) (Value, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	element, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	// 已经过期的元素视为不存在，删除交给清理协程或者下一次 FindCache
+	if t, ok := c.expires[key]; ok && c.clk.Now().After(t) {
+		return nil, false
+	}
+	return element.Value.(*LruEntry).value, true
+}
+
+// Contains 判断key是否存在并且没有过期，不会影响淘汰顺序
+func (c *LruCache) Contains(key string) bool {
+	_, ok := c.Peek(key)
+	return ok
+}
+
+// TTL 返回key剩余的过期时间，0 表示永不过期，key不存在或者已经过期时返回 false，不会影响淘汰顺序
+func (c *LruCache) TTL(key string) (time.Duration, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if _, ok := c.items[key]; !ok {
+		return 0, false
+	}
+	t, ok := c.expires[key]
+	if !ok {
+		return 0, true
+	}
+	remaining := t.Sub(c.clk.Now())
+	if remaining <= 0 {
+		return 0, false
+	}
+	return remaining, true
+}
+
+// Range 遍历所有未过期的数据，f 返回 false 时停止遍历，按照从最久未使用到最近使用的顺序
+// 遍历期间持有读锁，f 中不能再调用该缓存的任何方法，否则可能造成死锁
+func (c *LruCache) Range(f func(key string, value Value) bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	now := c.clk.Now()
+	for elem := c.list.Front(); elem != nil; elem = elem.Next() {
+		entry := elem.Value.(*LruEntry)
+		if t, ok := c.expires[entry.key]; ok && now.After(t) {
+			continue
+		}
+		if !f(entry.key, entry.value) {
+			return
+		}
+	}
+}
+
 func (c *LruCache) Len() int {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 	return c.list.Len()
 }
 
+// Bytes 返回当前已经使用的容量
+func (c *LruCache) Bytes() int64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.currentBytes
+}
+
 // 5.删除缓存中的数据
-func (c *LruCache) removeCache(elem *list.Element) error {
+func (c *LruCache) removeCache(elem *list.Element, reason EvictReason) error {
 	// 1.从缓存中删除传进来的元素
 	// 1.1.首先删除list中的数据
 	entry := elem.Value.(*LruEntry)
 	c.list.Remove(elem)
 	// 1.2.再删除掉map中的映射关系
 	delete(c.items, entry.key)
-	delete(c.items, entry.key)
+	delete(c.expires, entry.key)
+	delete(c.ttls, entry.key)
+	c.setTags(entry, nil)
 	// 2.修改缓存的当前存储空间
-	c.currentBytes -= int64(len(entry.key) + entry.value.Len())
+	size := c.sizeOf(entry.key, entry.value)
+	c.currentBytes -= size
+	metrics.Entries.Dec()
+	metrics.Bytes.Sub(float64(size))
+	if reason == ReasonExpired {
+		atomic.AddInt64(&c.expirations, 1)
+	}
 	if c.onEvicted != nil {
-		c.onEvicted(entry.key, entry.value)
+		c.onEvicted(entry.key, entry.value, reason)
 	}
 	return nil
 }
 
+// Clear 清空缓存中的所有数据，每个被删除的元素都会触发 onEvicted 回调
+func (c *LruCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.onEvicted != nil {
+		for elem := c.list.Front(); elem != nil; elem = elem.Next() {
+			entry := elem.Value.(*LruEntry)
+			c.onEvicted(entry.key, entry.value, ReasonCleared)
+		}
+	}
+	metrics.Entries.Sub(float64(c.list.Len()))
+	metrics.Bytes.Sub(float64(c.currentBytes))
+	c.list = list.New()
+	c.items = make(map[string]*list.Element)
+	c.expires = make(map[string]time.Time)
+	c.expiryHeap = nil
+	c.ttls = make(map[string]time.Duration)
+	c.tagIndex = make(map[string]map[string]struct{})
+	c.scanIndex = nil
+	c.currentBytes = 0
+}
+
+// Resize 运行时修改最大容量，如果新的容量更小会立即淘汰数据，返回被淘汰的条目数
+// 配置了 EvictionBatchSize 时分批淘汰，批与批之间释放写锁，其他协程的读写可以穿插执行
+func (c *LruCache) Resize(maxBytes int64) int {
+	c.mu.Lock()
+	c.maxBytes = maxBytes
+	c.mu.Unlock()
+	count, _, err := c.evictInBatches()
+	if err != nil {
+		c.log.Error("Resize 淘汰数据报错", zap.Error(err))
+	}
+	return count
+}
+
+// evictInBatches 反复获取写锁执行 evictN，每次最多删除 EvictionBatchSize 个条目，直到没有需要清理的数据
+// 批与批之间释放写锁并让出处理器，避免大量淘汰时长时间阻塞其他协程；没有配置批大小时一次清理完成
+func (c *LruCache) evictInBatches() (int, int64, error) {
+	total, totalBytes := 0, int64(0)
+	for {
+		c.mu.Lock()
+		count, bytes, err := c.evictN(c.evictBatch)
+		c.mu.Unlock()
+		total += count
+		totalBytes += bytes
+		if err != nil || c.evictBatch <= 0 || count < c.evictBatch {
+			return total, totalBytes, err
+		}
+		runtime.Gosched()
+	}
+}
+
 // 定期清理缓存的方法
 func (c *LruCache) cleanupLoop() error {
 	for {
 		select {
 		// 如果检测到时间到了，那么就执行清楚缓存中已经超过过期时间的数据，从而实现定期清理过期数据
 		case <-c.cleanTicker.C:
-			c.mu.Lock()
-			err := c.evict()
+			count, bytes, err := c.evictInBatches()
 			if err != nil {
 				c.log.Error(err.Error())
 				return fmt.Errorf("cleanupLoop 报错:%v", err.Error())
 			}
-			c.mu.Unlock()
+			reportReclaimed(c.log, count, bytes)
 		case <-c.closeChan:
 			return nil
 
@@ -228,43 +856,190 @@ func (c *LruCache) cleanupLoop() error {
 	}
 }
 
-// evict 清理过期和超出内存限制的缓存，调用此方法前必须持有锁
-func (c *LruCache) evict() error {
-	// 首先先处理过期数据
-	now := time.Now()
-	expires := c.expires
-	for i, j := range expires {
-		// 判断是否超时了，如果超时的话，就执行去除函数
-		if now.After(j) {
-			if elem, ok := c.items[i]; ok {
-				err := c.removeCache(elem)
-				if err != nil {
-					c.log.Error(err.Error())
-					return fmt.Errorf("evict 清理过期数据报错:%v", err.Error())
-				}
-			}
+// DeleteExpired 立即清理所有已经过期的数据，返回被删除的条目数，不需要等待后台清理协程
+// 适合关闭了后台清理、只依赖惰性过期的场景，例如在统计内存占用之前主动清理一次；不会触发容量淘汰
+// 配置了 EvictionBatchSize 时分批清理，批与批之间释放写锁
+func (c *LruCache) DeleteExpired() int {
+	total := 0
+	for {
+		c.mu.Lock()
+		count, bytes, err := c.evictExpired(c.evictBatch)
+		c.evictLog.record(count, bytes)
+		c.mu.Unlock()
+		total += count
+		if err != nil {
+			c.log.Error("DeleteExpired 清理过期数据报错", zap.Error(err))
+			return total
 		}
+		if c.evictBatch <= 0 || count < c.evictBatch {
+			return total
+		}
+		runtime.Gosched()
+	}
+}
 
+// evictExpired 是 evict 中清理过期数据的部分，返回被清理的条目数和回收的字节数，调用此方法前必须持有锁
+// limit 大于 0 时最多删除 limit 个条目
+func (c *LruCache) evictExpired(limit int) (int, int64, error) {
+	count, bytes := 0, int64(0)
+	// 从过期堆的堆顶开始弹出，直到堆顶还没有过期，只会访问已经过期的key
+	// 永不过期的key没有记录在 expires 中，也不会出现在堆里
+	now := c.clk.Now()
+	for c.expiryHeap.Len() > 0 && now.After(c.expiryHeap[0].at) && (limit <= 0 || count < limit) {
+		item := heap.Pop(&c.expiryHeap).(expiryItem)
+		// 堆中的记录可能已经过时（key被删除或者过期时间被刷新），以 expires 为准
+		if t, ok := c.expires[item.key]; !ok || !t.Equal(item.at) {
+			continue
+		}
+		elem, ok := c.items[item.key]
+		if !ok {
+			delete(c.expires, item.key)
+			continue
+		}
+		entry := elem.Value.(*LruEntry)
+		err := c.removeCache(elem, ReasonExpired)
+		if err != nil {
+			c.log.Error(err.Error())
+			return count, bytes, fmt.Errorf("evict 清理过期数据报错:%v", err.Error())
+		}
+		count++
+		bytes += c.sizeOf(entry.key, entry.value)
+		metrics.Evictions.Inc()
 	}
-	// 当存储的数据大小超出了最大存储的时候，需要根据lru策略删除掉缓存中的数据
-	// 如果超出了最大存储，那么应该从list的头部开始删除数据，直到删到当前存储小于最大存储的时候
-	for c.currentBytes > c.maxBytes && c.maxBytes > 0 && c.list.Len() > 0 {
+	return count, bytes, nil
+}
+
+// evict 清理过期和超出内存限制的缓存，返回被清理的条目数和回收的字节数，调用此方法前必须持有锁
+func (c *LruCache) evict() (int, int64, error) {
+	return c.evictN(0)
+}
+
+// evictN 与 evict 相同，但 limit 大于 0 时最多删除 limit 个条目，返回的条目数等于 limit 时说明可能还有需要清理的数据
+// 调用此方法前必须持有锁
+func (c *LruCache) evictN(limit int) (int, int64, error) {
+	count, bytes, err := c.evictExpired(limit)
+	if err != nil {
+		return count, bytes, err
+	}
+	// 当存储的数据大小超出了最大存储，或者条目数超出了最大条目数的时候，需要根据lru策略删除掉缓存中的数据
+	// 如果超出了限制，那么应该从list的头部开始删除数据，直到两个限制都满足的时候
+	// 淘汰之前先处理还在队列中的延迟提升，避免淘汰刚刚被访问过的数据
+	if c.promotions != nil && c.overCapacity() {
+		c.drainPromotions()
+	}
+	for c.overCapacity() && c.list.Len() > 0 && (limit <= 0 || count < limit) {
 		elem := c.list.Front() // 获取最久未使用的项（链表头部）
+		if c.samples > 0 {
+			elem = c.sampleOldest()
+		}
 		if elem != nil {
-			err := c.removeCache(elem)
+			entry := elem.Value.(*LruEntry)
+			err := c.removeCache(elem, ReasonCapacity)
 			if err != nil {
 				c.log.Error(err.Error())
-				return fmt.Errorf("evict 清理超过最大缓存的数据报错:%v", err.Error())
+				return count, bytes, fmt.Errorf("evict 清理超过最大缓存的数据报错:%v", err.Error())
 			}
+			count++
+			bytes += c.sizeOf(entry.key, entry.value)
+			metrics.Evictions.Inc()
 		}
 	}
-	return nil
+	c.evictLog.record(count, bytes)
+	return count, bytes, nil
 }
 
-// close 关闭缓存，停止清理协程
+// 记录后台清理协程回收的条目数和字节数，没有回收任何数据时不输出日志
+func reportReclaimed(log *zap.Logger, count int, bytes int64) {
+	if count == 0 {
+		return
+	}
+	metrics.CleanupReclaimed.Add(float64(count))
+	metrics.CleanupReclaimedBytes.Add(float64(bytes))
+	log.Debug("后台清理回收数据", zap.Int("count", count), zap.Int64("bytes", bytes))
+}
+
+// 返回下一个逻辑时间
+func (c *LruCache) tick() int64 {
+	return atomic.AddInt64(&c.clock, 1)
+}
+
+// 延迟提升的后台协程，每收到一个元素就获取写锁，把队列中积压的元素一起移动到队尾
+func (c *LruCache) promotionLoop() {
+	for {
+		select {
+		case elem := <-c.promotions:
+			c.mu.Lock()
+			c.moveToBack(elem)
+			c.drainPromotions()
+			c.mu.Unlock()
+		case <-c.closeChan:
+			return
+		}
+	}
+}
+
+// 处理队列中所有积压的延迟提升，调用此方法前必须持有锁
+func (c *LruCache) drainPromotions() {
+	for {
+		select {
+		case elem := <-c.promotions:
+			c.moveToBack(elem)
+		default:
+			return
+		}
+	}
+}
+
+// 如果元素仍然在缓存中，就将它移动到list的队尾，调用此方法前必须持有锁
+func (c *LruCache) moveToBack(elem *list.Element) {
+	entry := elem.Value.(*LruEntry)
+	if e, ok := c.items[entry.key]; ok && e == elem {
+		c.list.MoveToBack(elem)
+	}
+}
+
+// 随机查看 samples 个条目，返回其中最久没有被访问的一个，调用此方法前必须持有锁
+// 利用 map 遍历顺序的随机性进行采样，与 Redis 的近似 LRU 类似
+func (c *LruCache) sampleOldest() *list.Element {
+	var oldest *list.Element
+	var oldestAt int64
+	n := 0
+	for _, elem := range c.items {
+		at := atomic.LoadInt64(&elem.Value.(*LruEntry).accessed)
+		if oldest == nil || at < oldestAt {
+			oldest, oldestAt = elem, at
+		}
+		n++
+		if n >= c.samples {
+			break
+		}
+	}
+	return oldest
+}
+
+// 是否超出了容量限制，MaxBytes 和 MaxEntries 任意一个超出都需要淘汰，调用此方法前必须持有锁
+func (c *LruCache) overCapacity() bool {
+	if c.maxBytes > 0 && c.currentBytes > c.maxBytes {
+		return true
+	}
+	return c.maxEntries > 0 && int64(c.list.Len()) > c.maxEntries
+}
+
+// Close 关闭缓存，停止后台清理协程和延迟提升协程，并等待它们退出之后再返回
+// 后台清理会在持有写锁时调用 OnEvicted，所以不能在 OnEvicted 中调用 Close，否则会一直等待自己退出
 func (c *LruCache) Close() {
-	if c.cleanTicker != nil {
-		c.cleanTicker.Stop()
+	// 使用 sync.Once 保证重复调用 Close 不会重复关闭 closeChan 导致 panic
+	c.closeOnce.Do(func() {
+		if c.cleanTicker != nil {
+			c.cleanTicker.Stop()
+		}
 		close(c.closeChan)
-	}
+		c.wg.Wait()
+		c.evictLog.flush()
+	})
+}
+
+// 键值对占用的容量
+func (c *LruCache) sizeOf(key string, value Value) int64 {
+	return entrySize(c.weigher, key, value)
 }
@@ -0,0 +1,58 @@
+package lru
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// FuzzLruOps 把输入解码为一串操作应用到一个很小的 LruCache 上，每个操作之后检查内部数据结构的一致性
+// 每两个字节为一个操作：第一个字节选择操作，第二个字节决定 key、值的长度和参数
+// 使用 go test -fuzz=FuzzLruOps ./lru 运行
+func FuzzLruOps(f *testing.F) {
+	f.Add([]byte{0, 1, 0, 2, 0, 3, 3, 1, 0, 4})
+	f.Add([]byte{1, 9, 4, 0, 3, 9, 8, 0, 0, 9})
+	f.Add([]byte{0, 45, 0, 46, 0, 47, 2, 46, 3, 45})
+	f.Add([]byte{5, 1, 5, 2, 6, 0, 0, 1})
+	f.Add([]byte{0, 30, 0, 31, 7, 20, 0, 32, 7, 200})
+	f.Add([]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 200, 31, 77})
+	f.Fuzz(func(t *testing.T, ops []byte) {
+		clock := NewFakeClock(time.Unix(0, 0))
+		c := NewLruCache(&Options{
+			MaxBytes:                 64,
+			MaxValueBytes:            40,
+			Clock:                    clock,
+			SlidingExpiration:        true,
+			DisableBackgroundCleanup: true,
+		})
+		defer c.Close()
+		for i := 0; i+1 < len(ops); i += 2 {
+			arg := ops[i+1]
+			key := string(rune('a' + arg%8))
+			value := testValue(strings.Repeat("x", int(arg%50)))
+			switch ops[i] % 9 {
+			case 0:
+				c.AddAndUpdateCache(key, value)
+			case 1:
+				c.AddWithTTL(key, value, time.Duration(arg%3)*time.Second)
+			case 2:
+				c.DeleteCache(key)
+			case 3:
+				c.FindCache(key)
+			case 4:
+				clock.Advance(time.Second)
+			case 5:
+				c.AddWithTags(key, value, 0, "t", key)
+			case 6:
+				c.InvalidateTag("t")
+			case 7:
+				c.Resize(int64(arg))
+			case 8:
+				c.DeleteExpired()
+			}
+			if err := c.checkInvariants(); err != nil {
+				t.Fatalf("第 %d 个操作 %d(%d) 之后: %v", i/2, ops[i]%9, arg, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,746 @@
+package lru
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 测试使用的 Value，长度为字符串的字节数
+type testValue string
+
+func (v testValue) Len() int {
+	return len(v)
+}
+
+func TestGetSet(t *testing.T) {
+	c := NewLruCache(&Options{MaxBytes: 100, MaxValueBytes: 4, DisableBackgroundCleanup: true})
+	defer c.Close()
+	if _, existed, err := c.GetSet("a", testValue("1")); existed || err != nil {
+		t.Fatalf("新的key应该返回 existed=false，实际为 %v %v", existed, err)
+	}
+	c.AddAndUpdateCache("b", testValue("x"))
+	old, existed, err := c.GetSet("a", testValue("2"))
+	if err != nil || !existed || old != testValue("1") {
+		t.Fatalf("GetSet 返回 %v %v %v", old, existed, err)
+	}
+	// GetSet 会把 a 移动到队尾
+	if c.list.Back().Value.(*LruEntry).key != "a" {
+		t.Fatal("GetSet 之后 a 应该在队尾")
+	}
+	if v, _ := c.Peek("a"); v != testValue("2") {
+		t.Fatalf("a 的值为 %v", v)
+	}
+	// 写入失败时返回错误，旧值保持不变
+	old, existed, err = c.GetSet("a", testValue("12345"))
+	if !errors.Is(err, ErrValueTooLarge) || !existed || old != testValue("2") {
+		t.Fatalf("写入失败时 GetSet 返回 %v %v %v", old, existed, err)
+	}
+	if v, _ := c.Peek("a"); v != testValue("2") {
+		t.Fatalf("写入失败之后 a 的值为 %v", v)
+	}
+}
+
+func TestGetWithTTL(t *testing.T) {
+	clk := NewFakeClock(time.Unix(0, 0))
+	c := NewLruCache(&Options{MaxBytes: 100, Clock: clk, DisableBackgroundCleanup: true})
+	defer c.Close()
+	c.AddWithTTL("a", testValue("1"), 10*time.Second)
+	c.AddAndUpdateCache("forever", testValue("2"))
+	clk.Advance(3 * time.Second)
+	if v, ttl, ok := c.GetWithTTL("a"); !ok || v != testValue("1") || ttl != 7*time.Second {
+		t.Fatalf("GetWithTTL 返回 %v %v %v", v, ttl, ok)
+	}
+	if _, ttl, ok := c.GetWithTTL("forever"); !ok || ttl != 0 {
+		t.Fatalf("永不过期的key应该返回 0，实际为 %v %v", ttl, ok)
+	}
+	clk.Advance(8 * time.Second)
+	if _, _, ok := c.GetWithTTL("a"); ok {
+		t.Fatal("已经过期的key不应该命中")
+	}
+}
+
+// 与删除并发时，命中的带过期时间的key不会返回 0（永不过期）
+func TestGetWithTTLConcurrentDelete(t *testing.T) {
+	c := NewLruCache(&Options{MaxBytes: 1 << 20, DisableBackgroundCleanup: true})
+	defer c.Close()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 2000; i++ {
+			c.AddWithTTL("k", testValue("v"), time.Hour)
+			c.DeleteCache("k")
+		}
+	}()
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		if _, ttl, ok := c.GetWithTTL("k"); ok && ttl <= 0 {
+			t.Fatalf("命中时剩余过期时间为 %v", ttl)
+		}
+	}
+}
+
+// Close 返回时后台清理协程已经退出，不会再有正在执行的 OnEvicted
+func TestCloseWaitsForCleanup(t *testing.T) {
+	var running int32
+	var once sync.Once
+	started := make(chan struct{})
+	c := NewLruCache(&Options{
+		MaxBytes:        100,
+		CleanupInterval: time.Millisecond,
+		DefaultTTL:      time.Millisecond,
+		OnEvicted: func(key string, value Value, reason EvictReason) {
+			atomic.AddInt32(&running, 1)
+			once.Do(func() { close(started) })
+			time.Sleep(20 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+		},
+	})
+	c.AddAndUpdateCache("a", testValue("1"))
+	c.AddAndUpdateCache("b", testValue("2"))
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("后台清理没有运行")
+	}
+	c.Close()
+	if n := atomic.LoadInt32(&running); n != 0 {
+		t.Fatalf("Close 返回时仍然有 %d 个 OnEvicted 在执行", n)
+	}
+}
+
+func TestResetStats(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	c := NewLruCache(&Options{MaxBytes: 100, Clock: clock, DisableBackgroundCleanup: true})
+	defer c.Close()
+	c.AddWithTTL("a", testValue("1"), time.Second)
+	c.AddAndUpdateCache("b", testValue("2"))
+	c.FindCache("b")
+	clock.Advance(2 * time.Second)
+	c.FindCache("a")
+
+	prev := c.ResetStats()
+	if prev.Hits != 1 || prev.Misses != 1 || prev.Expirations != 1 || prev.Entries != 1 {
+		t.Fatalf("清零之前的统计错误: %+v", prev)
+	}
+	if s := c.Stats(); s.Hits != 0 || s.Misses != 0 || s.Expirations != 0 {
+		t.Fatalf("清零之后统计应该为 0: %+v", s)
+	}
+	c.FindCache("b")
+	if s := c.Stats(); s.Hits != 1 {
+		t.Fatalf("清零之后应该重新开始累计: %+v", s)
+	}
+}
+
+func TestFindCache(t *testing.T) {
+	c := NewLruCache(&Options{DisableBackgroundCleanup: true})
+	defer c.Close()
+	if err := c.AddAndUpdateCache("k", testValue("v")); err != nil {
+		t.Fatal(err)
+	}
+	v, ok := c.FindCache("k")
+	if !ok || v != testValue("v") {
+		t.Fatalf("FindCache 返回 %v %v", v, ok)
+	}
+	if _, ok := c.FindCache("missing"); ok {
+		t.Fatal("不存在的key不应该命中")
+	}
+}
+
+// Peek 不改变淘汰顺序也不计入命中统计，FindCache 会把key移动到队尾；已经过期的key Peek 同样返回 false
+func TestPeek(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	c := NewLruCache(&Options{Clock: clock, DisableBackgroundCleanup: true})
+	defer c.Close()
+	c.AddAndUpdateCache("a", testValue("1"))
+	c.AddAndUpdateCache("b", testValue("2"))
+	c.AddWithTTL("e", testValue("3"), time.Second)
+	order := func() string {
+		s := ""
+		for e := c.list.Front(); e != nil; e = e.Next() {
+			s += e.Value.(*LruEntry).key
+		}
+		return s
+	}
+
+	tests := []struct {
+		name  string
+		read  func(key string) (Value, bool)
+		want  string
+		stats CacheStats
+	}{
+		{"Peek", c.Peek, "abe", CacheStats{}},
+		{"FindCache", c.FindCache, "bea", CacheStats{Hits: 1}},
+	}
+	for _, tt := range tests {
+		if v, ok := tt.read("a"); !ok || v != testValue("1") {
+			t.Fatalf("%s 返回 %v %v", tt.name, v, ok)
+		}
+		if got := order(); got != tt.want {
+			t.Fatalf("%s 之后的顺序为 %s，期望 %s", tt.name, got, tt.want)
+		}
+		if s := c.Stats(); s.Hits != tt.stats.Hits || s.Misses != tt.stats.Misses {
+			t.Fatalf("%s 之后的统计为 %+v", tt.name, s)
+		}
+	}
+	clock.Advance(2 * time.Second)
+	if _, ok := c.Peek("e"); ok {
+		t.Fatal("已经过期的key Peek 不应该命中")
+	}
+}
+
+// Contains 不改变淘汰顺序也不计入命中统计，已经过期的key返回 false
+func TestContains(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	c := NewLruCache(&Options{MaxEntries: 2, Clock: clock, DisableBackgroundCleanup: true})
+	defer c.Close()
+	c.AddAndUpdateCache("a", testValue("1"))
+	c.AddAndUpdateCache("b", testValue("1"))
+
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{"a", true},
+		{"b", true},
+		{"none", false},
+	}
+	for _, tt := range tests {
+		if got := c.Contains(tt.key); got != tt.want {
+			t.Fatalf("Contains(%q) = %v，期望 %v", tt.key, got, tt.want)
+		}
+	}
+	if s := c.Stats(); s.Hits != 0 || s.Misses != 0 {
+		t.Fatalf("Contains 不应该计入命中统计: %+v", s)
+	}
+	// a 没有被移动到队尾，写入 c 时仍然是最早被淘汰的
+	c.AddAndUpdateCache("c", testValue("1"))
+	if c.Contains("a") || !c.Contains("b") {
+		t.Fatal("Contains 改变了淘汰顺序")
+	}
+	c.AddWithTTL("e", testValue("1"), time.Second)
+	clock.Advance(2 * time.Second)
+	if c.Contains("e") {
+		t.Fatal("已经过期的key Contains 应该返回 false")
+	}
+}
+
+// DeletePrefix 只删除带有该前缀的key，每个被删除的key都以 ReasonDeleted 触发回调
+func TestDeletePrefix(t *testing.T) {
+	type prefixDeleter interface {
+		Store
+		DeletePrefix(prefix string) int
+	}
+	var evicted []string
+	onEvicted := func(key string, value Value, reason EvictReason) {
+		if reason == ReasonDeleted {
+			evicted = append(evicted, key)
+		}
+	}
+	tests := []struct {
+		name string
+		c    prefixDeleter
+	}{
+		{"LruCache", NewLruCache(&Options{OnEvicted: onEvicted, DisableBackgroundCleanup: true})},
+		{"ShardedCache", NewShardedCache(&Options{ShardCount: 4, OnEvicted: onEvicted, DisableBackgroundCleanup: true})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer tt.c.Close()
+			evicted = nil
+			for _, k := range []string{"user:1:a", "user:1:b", "user:12", "user:2:a", "x"} {
+				tt.c.AddAndUpdateCache(k, testValue("v"))
+			}
+			if n := tt.c.DeletePrefix("user:1:"); n != 2 || len(evicted) != 2 {
+				t.Fatalf("删除了 %d 个，回调了 %d 次，期望都为 2", n, len(evicted))
+			}
+			for _, k := range []string{"user:12", "user:2:a", "x"} {
+				if !tt.c.Contains(k) {
+					t.Fatalf("%s 不带该前缀，不应该被删除", k)
+				}
+			}
+			if n := tt.c.DeletePrefix("none:"); n != 0 || tt.c.Len() != 3 {
+				t.Fatalf("不匹配的前缀删除了 %d 个", n)
+			}
+			if n := tt.c.DeletePrefix(""); n != 3 || tt.c.Len() != 0 {
+				t.Fatalf("空前缀应该删除所有key，实际删除了 %d 个", n)
+			}
+		})
+	}
+}
+
+// Stat 在多次访问之后更新命中次数、最近访问时间和剩余过期时间，Peek 不计入访问
+func TestStat(t *testing.T) {
+	start := time.Unix(1000, 0)
+	clock := NewFakeClock(start)
+	c := NewLruCache(&Options{Clock: clock, DisableBackgroundCleanup: true})
+	defer c.Close()
+	c.AddWithTTL("a", testValue("123"), time.Minute)
+	want := EntryStat{Size: 4, InsertedAt: start, TTL: time.Minute}
+	if st, ok := c.Stat("a"); !ok || st != want {
+		t.Fatalf("写入之后 Stat = %+v %v，期望 %+v", st, ok, want)
+	}
+	for i := 1; i <= 3; i++ {
+		clock.Advance(time.Second)
+		c.FindCache("a")
+	}
+	c.Peek("a")
+	want = EntryStat{Size: 4, InsertedAt: start, LastAccess: start.Add(3 * time.Second), Hits: 3, TTL: 57 * time.Second}
+	if st, ok := c.Stat("a"); !ok || !st.LastAccess.Equal(want.LastAccess) || st.Hits != want.Hits || st.TTL != want.TTL || st.Size != want.Size {
+		t.Fatalf("访问 3 次之后 Stat = %+v，期望 %+v", st, want)
+	}
+	if _, ok := c.Stat("none"); ok {
+		t.Fatal("不存在的key不应该返回统计")
+	}
+	clock.Advance(time.Minute)
+	if _, ok := c.Stat("a"); ok {
+		t.Fatal("已经过期的key不应该返回统计")
+	}
+}
+
+// Touch 刷新已经存在的key的过期时间，被 Touch 过的key在原来的过期时间之后仍然存在；不存在或者已经过期的key返回 false
+func TestTouch(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	c := NewLruCache(&Options{Clock: clock, DisableBackgroundCleanup: true})
+	defer c.Close()
+	c.AddWithTTL("a", testValue("1"), 3*time.Second)
+	c.AddWithTTL("b", testValue("1"), time.Second)
+	clock.Advance(2 * time.Second)
+
+	tests := []struct {
+		key     string
+		ttl     time.Duration
+		want    bool
+		wantTTL time.Duration
+	}{
+		{"a", 10 * time.Second, true, 10 * time.Second},
+		{"b", 10 * time.Second, false, 0},
+		{"none", 10 * time.Second, false, 0},
+	}
+	for _, tt := range tests {
+		if got := c.Touch(tt.key, tt.ttl); got != tt.want {
+			t.Fatalf("Touch(%q) = %v，期望 %v", tt.key, got, tt.want)
+		}
+		if ttl, _ := c.TTL(tt.key); ttl != tt.wantTTL {
+			t.Fatalf("Touch 之后 %s 的剩余时间为 %v，期望 %v", tt.key, ttl, tt.wantTTL)
+		}
+	}
+	// a 原来在第 3s 过期，Touch 之后在第 12s 过期
+	clock.Advance(5 * time.Second)
+	if _, ok := c.FindCache("a"); !ok {
+		t.Fatal("Touch 过的 a 不应该在原来的过期时间过期")
+	}
+	if c.list.Back().Value.(*LruEntry).key != "a" {
+		t.Fatal("Touch 之后 a 应该在队尾")
+	}
+
+	c.AddWithTTL("forever", testValue("1"), time.Second)
+	if !c.Touch("forever", NoExpiration) {
+		t.Fatal("Touch(forever) 应该返回 true")
+	}
+	clock.Advance(time.Hour)
+	if ttl, ok := c.TTL("forever"); !ok || ttl != NoExpiration {
+		t.Fatalf("Touch 为 NoExpiration 之后剩余时间为 %v %v", ttl, ok)
+	}
+}
+
+// 并发 LoadOrStore 同一个key时只有一个值被存入，所有调用方拿到的都是这个值
+func TestLoadOrStore(t *testing.T) {
+	c := NewLruCache(&Options{DisableBackgroundCleanup: true})
+	defer c.Close()
+	var stored int32
+	results := make([]Value, 50)
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			actual, loaded := c.LoadOrStore("k", testValue(strconv.Itoa(i)))
+			if !loaded {
+				atomic.AddInt32(&stored, 1)
+			}
+			results[i] = actual
+		}(i)
+	}
+	wg.Wait()
+	if stored != 1 {
+		t.Fatalf("%d 个调用存入了值，期望只有 1 个", stored)
+	}
+	v, _ := c.Peek("k")
+	for i, r := range results {
+		if r != v {
+			t.Fatalf("第 %d 个调用拿到 %v，缓存中的值为 %v", i, r, v)
+		}
+	}
+	if actual, loaded := c.LoadOrStore("new", testValue("n")); loaded || actual != testValue("n") {
+		t.Fatalf("不存在的key应该被存入，实际为 %v %v", actual, loaded)
+	}
+}
+
+// DumpKeys 按照淘汰顺序返回所有未过期的key，Rank 与访问顺序一致
+func TestDumpKeys(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	c := NewLruCache(&Options{Clock: clock, DisableBackgroundCleanup: true})
+	defer c.Close()
+	c.AddAndUpdateCache("a", testValue("1"))
+	c.AddAndUpdateCache("b", testValue("22"))
+	c.AddWithTTL("c", testValue("1"), time.Hour)
+	c.AddWithTTL("x", testValue("1"), time.Second)
+	clock.Advance(2 * time.Second)
+	c.FindCache("a")
+
+	want := []KeyInfo{
+		{Key: "b", Size: 3, Rank: 0},
+		{Key: "c", Size: 2, TTL: time.Hour - 2*time.Second, Rank: 1},
+		{Key: "a", Size: 2, Rank: 2},
+	}
+	if got := c.DumpKeys(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("DumpKeys() = %+v，期望 %+v", got, want)
+	}
+}
+
+// 采样淘汰仍然大致遵循最近访问的顺序，最近访问过的key绝大多数会保留下来
+func TestSampledEvictionRespectsRecency(t *testing.T) {
+	c := NewLruCache(&Options{MaxEntries: 100, EvictionSamples: 10, DisableBackgroundCleanup: true})
+	defer c.Close()
+	for i := 0; i < 100; i++ {
+		c.AddAndUpdateCache(strconv.Itoa(i), testValue("v"))
+	}
+	for i := 50; i < 100; i++ {
+		c.FindCache(strconv.Itoa(i))
+	}
+	for i := 0; i < 30; i++ {
+		c.AddAndUpdateCache("n"+strconv.Itoa(i), testValue("v"))
+	}
+	hot := 0
+	for i := 50; i < 100; i++ {
+		if c.Contains(strconv.Itoa(i)) {
+			hot++
+		}
+	}
+	if hot < 40 {
+		t.Fatalf("最近访问过的 50 个key只保留了 %d 个", hot)
+	}
+}
+
+// 延迟提升模式下命中的key在后台协程处理之后同样会被移动到队尾
+func TestLazyPromotion(t *testing.T) {
+	c := NewLruCache(&Options{MaxEntries: 3, PromotionBuffer: 16, DisableBackgroundCleanup: true})
+	defer c.Close()
+	for _, key := range []string{"a", "b", "c"} {
+		c.AddAndUpdateCache(key, testValue("1"))
+	}
+	c.FindCache("a")
+	c.AddAndUpdateCache("d", testValue("1"))
+	if !c.Contains("a") || c.Contains("b") {
+		t.Fatal("a 被访问过，应该淘汰 b")
+	}
+}
+
+// 并发读取时不同淘汰方式的开销，lazy 为延迟提升模式，读取只需要读锁
+func BenchmarkFindCache(b *testing.B) {
+	for _, bc := range []struct {
+		name string
+		opt  Options
+	}{
+		{"strict", Options{}},
+		{"sampled", Options{EvictionSamples: 5}},
+		{"lazy", Options{PromotionBuffer: 1024}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			opt := bc.opt
+			opt.MaxEntries = 1000
+			opt.DisableBackgroundCleanup = true
+			c := NewLruCache(&opt)
+			defer c.Close()
+			keys := make([]string, 1000)
+			for i := range keys {
+				keys[i] = strconv.Itoa(i)
+				c.AddAndUpdateCache(keys[i], testValue("v"))
+			}
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					c.FindCache(keys[i%len(keys)])
+					i++
+				}
+			})
+		})
+	}
+}
+
+// 并发读取同一个已经过期的key，全部返回未命中并且只删除一次，使用 go test -race 运行可以检查数据竞争
+func TestFindCacheExpiredConcurrent(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	var evicted int32
+	c := NewLruCache(&Options{
+		Clock:                    clock,
+		DisableBackgroundCleanup: true,
+		OnEvicted: func(key string, value Value, reason EvictReason) {
+			atomic.AddInt32(&evicted, 1)
+		},
+	})
+	defer c.Close()
+	c.AddWithTTL("k", testValue("v"), time.Second)
+	clock.Advance(2 * time.Second)
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, ok := c.FindCache("k"); ok || v != nil {
+				t.Errorf("已经过期的key返回了 %v %v", v, ok)
+			}
+		}()
+	}
+	wg.Wait()
+	if c.Len() != 0 || atomic.LoadInt32(&evicted) != 1 {
+		t.Fatalf("过期的key应该只被删除一次，剩余 %d 个，回调 %d 次", c.Len(), evicted)
+	}
+}
+
+// 读取已经过期的key时立即删除，未命中和过期各计数一次，之后再读取只增加未命中
+func TestFindCacheExpiredStats(t *testing.T) {
+	tests := []struct {
+		name string
+		opt  Options
+	}{
+		{"默认", Options{}},
+		{"采样淘汰", Options{EvictionSamples: 5}},
+		{"延迟提升", Options{PromotionBuffer: 4}},
+		{"滑动过期", Options{SlidingExpiration: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := NewFakeClock(time.Unix(0, 0))
+			var reasons []EvictReason
+			opt := tt.opt
+			opt.Clock = clock
+			opt.DisableBackgroundCleanup = true
+			opt.OnEvicted = func(key string, value Value, reason EvictReason) {
+				reasons = append(reasons, reason)
+			}
+			c := NewLruCache(&opt)
+			defer c.Close()
+			c.AddWithTTL("a", testValue("1"), time.Second)
+			clock.Advance(2 * time.Second)
+
+			for i := 1; i <= 3; i++ {
+				if _, ok := c.FindCache("a"); ok {
+					t.Fatal("已经过期的key不应该命中")
+				}
+				if s := c.Stats(); s.Misses != int64(i) || s.Expirations != 1 || s.Hits != 0 {
+					t.Fatalf("第 %d 次读取之后的统计为 %+v", i, s)
+				}
+			}
+			if !reflect.DeepEqual(reasons, []EvictReason{ReasonExpired}) || c.Len() != 0 {
+				t.Fatalf("回调收到 %v，剩余 %d 个", reasons, c.Len())
+			}
+		})
+	}
+}
+
+// 两个写入者使用同一个版本号竞争 CompareAndSwap，只有一个成功；任何写入都会让旧的版本号失效
+func TestCompareAndSwap(t *testing.T) {
+	type versioned interface {
+		Store
+		GetWithVersion(key string) (Value, uint64, bool)
+		CompareAndSwap(key string, expectedVersion uint64, value Value) bool
+	}
+	tests := []struct {
+		name string
+		c    versioned
+	}{
+		{"LruCache", NewLruCache(&Options{DisableBackgroundCleanup: true})},
+		{"ShardedCache", NewShardedCache(&Options{ShardCount: 4, DisableBackgroundCleanup: true})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := tt.c
+			defer c.Close()
+			c.AddAndUpdateCache("k", testValue("0"))
+			_, v1, ok := c.GetWithVersion("k")
+			if !ok {
+				t.Fatal("GetWithVersion 应该命中")
+			}
+			var wins int32
+			var wg sync.WaitGroup
+			for i := 0; i < 2; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					if c.CompareAndSwap("k", v1, testValue(strconv.Itoa(i+1))) {
+						atomic.AddInt32(&wins, 1)
+					}
+				}(i)
+			}
+			wg.Wait()
+			if wins != 1 {
+				t.Fatalf("%d 个 CompareAndSwap 成功，期望只有 1 个", wins)
+			}
+			_, v2, _ := c.GetWithVersion("k")
+			if v2 <= v1 {
+				t.Fatalf("写入之后版本号应该增加: %d -> %d", v1, v2)
+			}
+			c.AddAndUpdateCache("k", testValue("z"))
+			if c.CompareAndSwap("k", v2, testValue("y")) {
+				t.Fatal("普通写入之后旧的版本号应该失效")
+			}
+			if c.CompareAndSwap("none", 0, testValue("x")) {
+				t.Fatal("不存在的key CompareAndSwap 应该失败")
+			}
+			if v, _ := c.Peek("k"); v != testValue("z") {
+				t.Fatalf("k 的值为 %v", v)
+			}
+		})
+	}
+}
+
+// RemoveOldest 按最近最少使用的顺序删除并返回条目，并以对应的原因触发 onEvicted；头部已经过期的条目被跳过
+func TestRemoveOldest(t *testing.T) {
+	tests := []struct {
+		name        string
+		expireFirst bool
+		want        []string
+		wantReasons map[string]EvictReason
+	}{
+		{"按访问顺序返回", false, []string{"b", "c", "a"},
+			map[string]EvictReason{"a": ReasonCapacity, "b": ReasonCapacity, "c": ReasonCapacity}},
+		{"跳过已经过期的头部", true, []string{"c", "a"},
+			map[string]EvictReason{"a": ReasonCapacity, "b": ReasonExpired, "c": ReasonCapacity}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clk := NewFakeClock(time.Unix(0, 0))
+			reasons := map[string]EvictReason{}
+			c := NewLruCache(&Options{Clock: clk, DisableBackgroundCleanup: true,
+				OnEvicted: func(key string, _ Value, reason EvictReason) { reasons[key] = reason }})
+			defer c.Close()
+			c.AddAndUpdateCache("a", testValue("1"))
+			c.AddWithTTL("b", testValue("2"), time.Second)
+			c.AddAndUpdateCache("c", testValue("3"))
+			c.FindCache("a")
+			if tt.expireFirst {
+				clk.Advance(2 * time.Second)
+			}
+			var got []string
+			for {
+				k, v, ok := c.RemoveOldest()
+				if !ok {
+					break
+				}
+				if v == nil {
+					t.Fatalf("%s 返回的值为 nil", k)
+				}
+				got = append(got, k)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("RemoveOldest 的顺序为 %v，期望 %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(reasons, tt.wantReasons) {
+				t.Fatalf("onEvicted 的原因为 %v，期望 %v", reasons, tt.wantReasons)
+			}
+			if c.Len() != 0 || c.Bytes() != 0 {
+				t.Fatalf("删除之后还剩 %d 个条目 %d 字节", c.Len(), c.Bytes())
+			}
+		})
+	}
+}
+
+// 配置 EvictionBatchSize 之后大量淘汰分批进行，批与批之间释放写锁，其他读操作可以穿插完成；单次写入仍然一直淘汰到容量以内
+func TestEvictionBatchInterleaves(t *testing.T) {
+	const n = 100000
+	c := NewLruCache(&Options{MaxBytes: 1 << 40, EvictionBatchSize: 64, DisableBackgroundCleanup: true})
+	defer c.Close()
+	for i := 0; i < n; i++ {
+		c.AddAndUpdateCache(strconv.Itoa(i), testValue("v"))
+	}
+	var resizing, during int32
+	started := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		close(started)
+		for atomic.LoadInt32(&resizing) != 2 {
+			before := atomic.LoadInt32(&resizing)
+			c.FindCache(strconv.Itoa(n - 1))
+			// 只统计开始和结束都在 Resize 期间的读取
+			if before == 1 && atomic.LoadInt32(&resizing) == 1 {
+				atomic.AddInt32(&during, 1)
+			}
+			time.Sleep(10 * time.Microsecond)
+		}
+	}()
+	<-started
+	atomic.StoreInt32(&resizing, 1)
+	removed := c.Resize(100)
+	atomic.StoreInt32(&resizing, 2)
+	<-done
+	if c.Bytes() > 100 || removed != n-c.Len() {
+		t.Fatalf("Resize 淘汰了 %d 个，剩余 Len=%d Bytes=%d", removed, c.Len(), c.Bytes())
+	}
+	if atomic.LoadInt32(&during) == 0 {
+		t.Fatal("分批淘汰期间没有任何读操作穿插完成")
+	}
+
+	small := NewLruCache(&Options{MaxBytes: 1000, EvictionBatchSize: 1, DisableBackgroundCleanup: true})
+	defer small.Close()
+	for i := 0; i < 100; i++ {
+		small.AddAndUpdateCache(strconv.Itoa(i), testValue("v"))
+	}
+	small.AddAndUpdateCache("big", testValue(make([]byte, 990)))
+	if small.Bytes() > 1000 || !small.Contains("big") {
+		t.Fatalf("写入需要淘汰多个条目时超出了容量: %d", small.Bytes())
+	}
+}
+
+// GetNoPromote 与 FindCache 一样计入命中统计，但不会改变淘汰顺序；Peek 两者都不改变
+func TestGetNoPromote(t *testing.T) {
+	type noPromoter interface {
+		Store
+		GetNoPromote(key string) (Value, bool)
+		Stats() CacheStats
+	}
+	tests := []struct {
+		name        string
+		read        func(c noPromoter, key string) (Value, bool)
+		wantHits    int64
+		wantEvicted string
+	}{
+		{"FindCache", func(c noPromoter, key string) (Value, bool) { return c.FindCache(key) }, 1, "b"},
+		{"GetNoPromote", func(c noPromoter, key string) (Value, bool) { return c.GetNoPromote(key) }, 1, "a"},
+		{"Peek", func(c noPromoter, key string) (Value, bool) { return c.Peek(key) }, 0, "a"},
+	}
+	for _, tt := range tests {
+		for _, c := range []noPromoter{
+			NewLruCache(&Options{MaxBytes: 4, DisableBackgroundCleanup: true}),
+			NewShardedCache(&Options{MaxBytes: 4, ShardCount: 1, DisableBackgroundCleanup: true}),
+		} {
+			c.AddAndUpdateCache("a", testValue("1"))
+			c.AddAndUpdateCache("b", testValue("2"))
+			if v, ok := tt.read(c, "a"); !ok || v != testValue("1") {
+				t.Fatalf("%s: 读取 a 返回 %v %v", tt.name, v, ok)
+			}
+			if _, ok := tt.read(c, "none"); ok {
+				t.Fatalf("%s: 不存在的key不应该命中", tt.name)
+			}
+			if hits := c.Stats().Hits; hits != tt.wantHits {
+				t.Fatalf("%s: 命中次数为 %d，期望 %d", tt.name, hits, tt.wantHits)
+			}
+			c.AddAndUpdateCache("c", testValue("3"))
+			if c.Contains(tt.wantEvicted) {
+				t.Fatalf("%s: 写入 c 之后应该淘汰 %s", tt.name, tt.wantEvicted)
+			}
+			c.Close()
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package lru
+
+import (
+	"bufio"
+	"errors"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	// MaxBytesFromFraction 计算结果的下限
+	minFractionBytes int64 = 1 << 20
+	// 允许使用的内存比例上限，需要给进程的其他部分和操作系统留出空间
+	maxMemoryFraction = 0.9
+	// 未配置 MaxBytes 时的默认容量，与 withDefault 一致
+	defaultMaxBytes int64 = 8 * 1024 * 1024
+)
+
+// 读取进程可用的内存（字节），测试时可以替换
+var totalMemory = readTotalMemory
+
+// 内存信息所在的文件，测试时可以替换
+var (
+	cgroupV2MemoryMax   = "/sys/fs/cgroup/memory.max"
+	cgroupV1MemoryLimit = "/sys/fs/cgroup/memory/memory.limit_in_bytes"
+	procMeminfo         = "/proc/meminfo"
+)
+
+// 读取进程可用的内存，只支持 Linux
+// 运行在容器中时以 cgroup 的内存限制为准，依次尝试 cgroup v2 的 memory.max 和 cgroup v1 的 memory.limit_in_bytes，
+// 没有限制或者读取失败时使用 /proc/meminfo 中的 MemTotal；限制大于物理内存时同样以 MemTotal 为准
+func readTotalMemory() (int64, error) {
+	total, err := readMemTotal(procMeminfo)
+	if limit, ok := readCgroupLimit(); ok && (err != nil || limit < total) {
+		return limit, nil
+	}
+	return total, err
+}
+
+// 读取 cgroup 的内存限制，没有限制或者读取失败时返回 false
+func readCgroupLimit() (int64, bool) {
+	for _, path := range []string{cgroupV2MemoryMax, cgroupV1MemoryLimit} {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		// cgroup v2 中没有限制时内容为 "max"
+		text := strings.TrimSpace(string(data))
+		if text == "max" {
+			return 0, false
+		}
+		limit, err := strconv.ParseInt(text, 10, 64)
+		if err != nil || limit <= 0 {
+			return 0, false
+		}
+		return limit, true
+	}
+	return 0, false
+}
+
+// 从 meminfo 文件读取系统总内存
+func readMemTotal(path string) (int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// 格式为 "MemTotal:       16314312 kB"
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "MemTotal:" {
+			continue
+		}
+		kb, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		return kb * 1024, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, errors.New(path + " 中没有 MemTotal")
+}
+
+// MaxBytesFromFraction 按照系统总内存的比例计算 Options.MaxBytes，例如 0.25 表示使用四分之一的内存
+// 运行在容器中时按照 cgroup 的内存限制计算，fraction 会被限制在 (0, 0.9] 之内，结果不小于 1MB；fraction 不是正数或者无法读取系统内存时返回默认的 8MB
+// 注意 MaxBytes 只统计键值对本身的大小，不包括 map、链表等结构的额外开销，实际占用的内存会更多
+func MaxBytesFromFraction(fraction float64) int64 {
+	if math.IsNaN(fraction) || fraction <= 0 {
+		return defaultMaxBytes
+	}
+	if fraction > maxMemoryFraction {
+		fraction = maxMemoryFraction
+	}
+	total, err := totalMemory()
+	if err != nil || total <= 0 {
+		return defaultMaxBytes
+	}
+	n := int64(float64(total) * fraction)
+	if n < minFractionBytes {
+		n = minFractionBytes
+	}
+	return n
+}
@@ -0,0 +1,84 @@
+package lru
+
+import (
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// 把内存信息文件替换为临时目录中的文件，内容为空的文件不会被创建
+func fakeMemoryFiles(t *testing.T, meminfo, v2, v1 string) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if content != "" {
+			if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return path
+	}
+	oldMeminfo, oldV2, oldV1 := procMeminfo, cgroupV2MemoryMax, cgroupV1MemoryLimit
+	procMeminfo = write("meminfo", meminfo)
+	cgroupV2MemoryMax = write("memory.max", v2)
+	cgroupV1MemoryLimit = write("memory.limit_in_bytes", v1)
+	t.Cleanup(func() {
+		procMeminfo, cgroupV2MemoryMax, cgroupV1MemoryLimit = oldMeminfo, oldV2, oldV1
+	})
+}
+
+func TestReadTotalMemory(t *testing.T) {
+	const meminfo = "MemFree:         1024 kB\nMemTotal:       16777216 kB\n"
+	tests := []struct {
+		name   string
+		v2, v1 string
+		want   int64
+	}{
+		{"没有 cgroup", "", "", 16 << 30},
+		{"cgroup v2 限制", "2147483648\n", "", 2 << 30},
+		{"cgroup v2 没有限制", "max\n", "1073741824\n", 16 << 30},
+		{"cgroup v1 限制", "", "1073741824\n", 1 << 30},
+		{"cgroup v1 没有限制", "", "9223372036854771712\n", 16 << 30},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeMemoryFiles(t, meminfo, tt.v2, tt.v1)
+			if n, err := readTotalMemory(); err != nil || n != tt.want {
+				t.Fatalf("readTotalMemory() = %d, %v，期望 %d", n, err, tt.want)
+			}
+		})
+	}
+
+	fakeMemoryFiles(t, "MemFree: 1024 kB\n", "", "")
+	if _, err := readTotalMemory(); err == nil {
+		t.Fatal("没有 MemTotal 时应该返回错误")
+	}
+	fakeMemoryFiles(t, "", "536870912", "")
+	if n, err := readTotalMemory(); err != nil || n != 512<<20 {
+		t.Fatalf("无法读取 meminfo 时应该使用 cgroup 限制，实际为 %d, %v", n, err)
+	}
+}
+
+func TestMaxBytesFromFraction(t *testing.T) {
+	old := totalMemory
+	defer func() { totalMemory = old }()
+	totalMemory = func() (int64, error) { return 16 << 30, nil }
+	if n := MaxBytesFromFraction(0.25); n != 4<<30 {
+		t.Fatalf("MaxBytesFromFraction(0.25) = %d", n)
+	}
+	if n, total := MaxBytesFromFraction(2), float64(16<<30); n != int64(total*maxMemoryFraction) {
+		t.Fatalf("比例应该被限制在 %v 之内，实际为 %d", maxMemoryFraction, n)
+	}
+	if MaxBytesFromFraction(0) != defaultMaxBytes || MaxBytesFromFraction(math.NaN()) != defaultMaxBytes {
+		t.Fatal("非正数的比例应该返回默认容量")
+	}
+	if n := MaxBytesFromFraction(1e-12); n != minFractionBytes {
+		t.Fatalf("结果应该不小于 %d，实际为 %d", minFractionBytes, n)
+	}
+	totalMemory = func() (int64, error) { return 0, errors.New("无法读取") }
+	if MaxBytesFromFraction(0.5) != defaultMaxBytes {
+		t.Fatal("无法读取内存时应该返回默认容量")
+	}
+}
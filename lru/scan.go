@@ -0,0 +1,88 @@
+package lru
+
+import "sort"
+
+// Scan 索引中的一项
+type scanItem struct {
+	hash uint32
+	key  string
+}
+
+// Scan 类似 Redis 的 SCAN，按照 key 的哈希值顺序分批返回key，每批在一次读锁内完成，批与批之间释放锁
+// 第一次调用传入 cursor 0，之后传入上一次返回的 next，next 为 0 表示遍历结束；count 小于等于 0 时为 10
+// 整个遍历期间一直存在的key恰好返回一次，遍历期间新增或者删除的key可能出现也可能不出现；已经过期的key不会返回
+// 哈希值相同的key总是在同一批中返回，所以一批的数量可能略多于 count
+// key 按照哈希值排序的索引在 cursor 为 0 并且有新增的key时重新构建，之后每一批只需要二分查找游标的位置，耗时与 count 成正比
+func (c *LruCache) Scan(cursor uint64, count int) (keys []string, next uint64) {
+	if count <= 0 {
+		count = 10
+	}
+	if cursor > 1<<32-1 {
+		return nil, 0
+	}
+	start := uint32(cursor)
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	index := c.scanSnapshot(start == 0)
+	now := c.clk.Now()
+	i := sort.Search(len(index), func(i int) bool { return index[i].hash >= start })
+	hashes := 0
+	var last uint32
+	for ; i < len(index); i++ {
+		item := index[i]
+		// 已经凑够 count 个哈希值，并且下一个哈希值与最后一个不同时结束这一批
+		if hashes >= count && item.hash != last {
+			return keys, uint64(last) + 1
+		}
+		// 索引中可能还留着已经删除的key，同名的key重新写入之后哈希值不变，仍然可以返回
+		if _, ok := c.items[item.key]; !ok {
+			continue
+		}
+		if t, ok := c.expires[item.key]; ok && now.After(t) {
+			continue
+		}
+		if hashes == 0 || item.hash != last {
+			hashes++
+			last = item.hash
+		}
+		keys = append(keys, item.key)
+	}
+	return keys, 0
+}
+
+// scanSnapshot 返回 Scan 使用的索引，索引不存在或者新一轮遍历开始（rebuild 为 true）时有新增的key则重新构建
+// 遍历进行到一半时不重新构建，期间新增的key可能不会被返回，这与 Scan 的约定一致；调用此方法前必须持有读锁
+func (c *LruCache) scanSnapshot(rebuild bool) []scanItem {
+	c.scanMu.Lock()
+	defer c.scanMu.Unlock()
+	if c.scanIndex == nil || (rebuild && c.scanDirty) {
+		index := make([]scanItem, 0, len(c.items))
+		for key, elem := range c.items {
+			index = append(index, scanItem{hash: elem.Value.(*LruEntry).hash, key: key})
+		}
+		sort.Slice(index, func(i, j int) bool { return index[i].hash < index[j].hash })
+		c.scanIndex = index
+		c.scanDirty = false
+	}
+	return c.scanIndex
+}
+
+// Scan 依次扫描每个分片，游标的高 32 位是分片下标，低 32 位是分片内的游标
+// 一次调用只会返回一个分片中的key，遇到空分片时继续扫描下一个分片
+func (c *ShardedCache) Scan(cursor uint64, count int) (keys []string, next uint64) {
+	shard, inner := int(cursor>>32), cursor&(1<<32-1)
+	for ; shard < len(c.shards); shard, inner = shard+1, 0 {
+		var n uint64
+		keys, n = c.shards[shard].Scan(inner, count)
+		if n != 0 {
+			return keys, uint64(shard)<<32 | n
+		}
+		if len(keys) > 0 {
+			if shard+1 < len(c.shards) {
+				return keys, uint64(shard+1) << 32
+			}
+			return keys, 0
+		}
+	}
+	return nil, 0
+}
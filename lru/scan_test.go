@@ -0,0 +1,89 @@
+package lru
+
+import (
+	"strconv"
+	"testing"
+)
+
+// 完整遍历一次，返回每个key出现的次数
+func scanAll(t *testing.T, scan func(cursor uint64, count int) ([]string, uint64), count int, during func()) map[string]int {
+	t.Helper()
+	seen := make(map[string]int)
+	var cursor uint64
+	for i := 0; ; i++ {
+		keys, next := scan(cursor, count)
+		for _, k := range keys {
+			seen[k]++
+		}
+		if i == 0 && during != nil {
+			during()
+		}
+		if next == 0 {
+			return seen
+		}
+		if next <= cursor&(1<<32-1) && next>>32 == cursor>>32 {
+			t.Fatalf("游标没有前进: %d -> %d", cursor, next)
+		}
+		cursor = next
+	}
+}
+
+func TestLruScan(t *testing.T) {
+	c := NewLruCache(&Options{MaxBytes: 1 << 20, DisableBackgroundCleanup: true})
+	defer c.Close()
+	for i := 0; i < 1000; i++ {
+		c.AddAndUpdateCache("k"+strconv.Itoa(i), testValue("v"))
+	}
+	// 遍历期间删除一部分key、新增一部分key，一直存在的key恰好返回一次
+	seen := scanAll(t, c.Scan, 7, func() {
+		for i := 0; i < 100; i++ {
+			c.DeleteCache("k" + strconv.Itoa(i))
+			c.AddAndUpdateCache("new"+strconv.Itoa(i), testValue("v"))
+		}
+	})
+	for i := 100; i < 1000; i++ {
+		if n := seen["k"+strconv.Itoa(i)]; n != 1 {
+			t.Fatalf("key k%d 返回了 %d 次", i, n)
+		}
+	}
+	for k, n := range seen {
+		if n != 1 {
+			t.Fatalf("key %s 返回了 %d 次", k, n)
+		}
+	}
+	// 新的一轮遍历可以看到之前新增的key
+	seen = scanAll(t, c.Scan, 50, nil)
+	if len(seen) != 1000 || seen["new0"] != 1 || seen["k0"] != 0 {
+		t.Fatalf("第二轮遍历返回了 %d 个key", len(seen))
+	}
+}
+
+func TestShardedScan(t *testing.T) {
+	c := NewShardedCache(&Options{MaxBytes: 1 << 20, ShardCount: 4, DisableBackgroundCleanup: true})
+	defer c.Close()
+	for i := 0; i < 500; i++ {
+		c.AddAndUpdateCache("k"+strconv.Itoa(i), testValue("v"))
+	}
+	seen := scanAll(t, c.Scan, 16, nil)
+	if len(seen) != 500 {
+		t.Fatalf("应该返回 500 个key，实际为 %d", len(seen))
+	}
+	for k, n := range seen {
+		if n != 1 {
+			t.Fatalf("key %s 返回了 %d 次", k, n)
+		}
+	}
+}
+
+func BenchmarkLruScan(b *testing.B) {
+	c := NewLruCache(&Options{MaxBytes: 1 << 30, DisableBackgroundCleanup: true})
+	defer c.Close()
+	for i := 0; i < 100000; i++ {
+		c.AddAndUpdateCache("k"+strconv.Itoa(i), testValue("v"))
+	}
+	b.ResetTimer()
+	var cursor uint64
+	for i := 0; i < b.N; i++ {
+		_, cursor = c.Scan(cursor, 100)
+	}
+}
@@ -0,0 +1,321 @@
+package lru
+
+import (
+	"github.com/cespare/xxhash/v2"
+	"go.uber.org/zap"
+	"hash/crc32"
+	"time"
+)
+
+// 默认的分片数量
+const defaultShardCount = 16
+
+// ShardHash 分片使用的哈希算法
+type ShardHash string
+
+const (
+	ShardHashFNV    ShardHash = "fnv"    // FNV-1a，默认
+	ShardHashXXHash ShardHash = "xxhash" // xxHash，长 key 时更快，分布也更均匀
+	ShardHashCRC32  ShardHash = "crc32"  // CRC-32（IEEE），在支持硬件指令的平台上很快
+)
+
+// 根据名称返回对应的哈希函数，未知的名称使用 FNV-1a
+func shardHashFunc(h ShardHash) func(key string) uint32 {
+	switch h {
+	case ShardHashXXHash:
+		return func(key string) uint32 {
+			return uint32(xxhash.Sum64String(key))
+		}
+	case ShardHashCRC32:
+		return func(key string) uint32 {
+			return crc32.ChecksumIEEE([]byte(key))
+		}
+	default:
+		return fnv32a
+	}
+}
+
+// FNV-1a 哈希，直接在字符串上计算，避免 hash/fnv 的内存分配
+func fnv32a(key string) uint32 {
+	const (
+		offset32 = 2166136261
+		prime32  = 16777619
+	)
+	h := uint32(offset32)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= prime32
+	}
+	return h
+}
+
+// 返回不小于 n 的最小的 2 的幂
+func nextPowerOfTwo(n int) int {
+	p := 1
+	for p < n {
+		p <<= 1
+	}
+	return p
+}
+
+// ShardedCache 分片缓存，实现了 Store 接口。
+// 根据 key 的哈希值将数据分散到 N 个相互独立的 LruCache 分片中，每个分片拥有自己的锁和 MaxBytes/N 的容量（MaxEntries 同理），
+// 从而降低单个读写锁带来的锁竞争，提升多核下的并发吞吐量。
+// 分片数量总是 2 的幂，选择分片时只需要对哈希值做一次按位与
+type ShardedCache struct {
+	shards []*LruCache
+	mask   uint32
+	hash   func(key string) uint32
+}
+
+// 构造函数
+func NewShardedCache(opt *Options) *ShardedCache {
+	withDefault(opt)
+	count := opt.ShardCount
+	if count <= 0 {
+		count = defaultShardCount
+	}
+	if count&(count-1) != 0 {
+		rounded := nextPowerOfTwo(count)
+		opt.Logger.Warn("分片数量不是 2 的幂，已向上取整", zap.Int("shardCount", count), zap.Int("rounded", rounded))
+		count = rounded
+	}
+	// 每个分片平分总容量
+	shardOpt := *opt
+	shardOpt.MaxBytes = opt.MaxBytes / int64(count)
+	if shardOpt.MaxBytes <= 0 {
+		shardOpt.MaxBytes = 1
+	}
+	if opt.MaxEntries > 0 {
+		shardOpt.MaxEntries = (opt.MaxEntries + int64(count) - 1) / int64(count)
+	}
+	cache := &ShardedCache{
+		shards: make([]*LruCache, count),
+		mask:   uint32(count - 1),
+		hash:   shardHashFunc(opt.ShardHash),
+	}
+	for i := range cache.shards {
+		o := shardOpt
+		var delay time.Duration
+		if opt.CleanupStagger {
+			// 均匀地错开各个分片的清理时间，避免所有分片在同一时刻加锁清理造成周期性的延迟尖刺
+			delay = opt.CleanupInterval * time.Duration(i) / time.Duration(count)
+		}
+		cache.shards[i] = newLruCache(&o, delay)
+	}
+	return cache
+}
+
+// 根据 key 选择对应的分片
+func (c *ShardedCache) shard(key string) *LruCache {
+	return c.shards[c.hash(key)&c.mask]
+}
+
+func (c *ShardedCache) AddAndUpdateCache(key string, value Value) error {
+	return c.shard(key).AddAndUpdateCache(key, value)
+}
+
+func (c *ShardedCache) AddWithTTL(key string, value Value, ttl time.Duration) error {
+	return c.shard(key).AddWithTTL(key, value, ttl)
+}
+
+func (c *ShardedCache) AddIfAbsent(key string, value Value, ttl time.Duration) (bool, error) {
+	return c.shard(key).AddIfAbsent(key, value, ttl)
+}
+
+func (c *ShardedCache) GetSet(key string, value Value) (Value, bool, error) {
+	return c.shard(key).GetSet(key, value)
+}
+
+func (c *ShardedCache) LoadOrStore(key string, value Value) (Value, bool) {
+	return c.shard(key).LoadOrStore(key, value)
+}
+
+func (c *ShardedCache) GetWithVersion(key string) (Value, uint64, bool) {
+	return c.shard(key).GetWithVersion(key)
+}
+
+// CompareAndSwap 的版本号只在所属分片内单调递增，不同分片的版本号之间没有可比性
+func (c *ShardedCache) CompareAndSwap(key string, expectedVersion uint64, value Value) bool {
+	return c.shard(key).CompareAndSwap(key, expectedVersion, value)
+}
+
+func (c *ShardedCache) DeleteCache(key string) error {
+	return c.shard(key).DeleteCache(key)
+}
+
+// DeletePrefix 在所有分片中删除以 prefix 开头的key，返回删除的条目数之和
+func (c *ShardedCache) DeletePrefix(prefix string) int {
+	n := 0
+	for _, s := range c.shards {
+		n += s.DeletePrefix(prefix)
+	}
+	return n
+}
+
+func (c *ShardedCache) FindCache(key string) (Value, bool) {
+	return c.shard(key).FindCache(key)
+}
+
+func (c *ShardedCache) GetWithTTL(key string) (Value, time.Duration, bool) {
+	return c.shard(key).GetWithTTL(key)
+}
+
+func (c *ShardedCache) Touch(key string, ttl time.Duration) bool {
+	return c.shard(key).Touch(key, ttl)
+}
+
+func (c *ShardedCache) Stat(key string) (EntryStat, bool) {
+	return c.shard(key).Stat(key)
+}
+
+func (c *ShardedCache) GetNoPromote(key string) (Value, bool) {
+	return c.shard(key).GetNoPromote(key)
+}
+
+func (c *ShardedCache) Peek(key string) (Value, bool) {
+	return c.shard(key).Peek(key)
+}
+
+func (c *ShardedCache) Contains(key string) bool {
+	return c.shard(key).Contains(key)
+}
+
+func (c *ShardedCache) TTL(key string) (time.Duration, bool) {
+	return c.shard(key).TTL(key)
+}
+
+// TopK 合并所有分片的热点key统计，返回命中次数最多的 n 个key
+func (c *ShardedCache) TopK(n int) []KeyCount {
+	var counts []KeyCount
+	for _, s := range c.shards {
+		counts = append(counts, s.TopK(-1)...)
+	}
+	return topKeyCounts(counts, n)
+}
+
+// ResetTopK 清空所有分片的热点key统计
+func (c *ShardedCache) ResetTopK() {
+	for _, s := range c.shards {
+		s.ResetTopK()
+	}
+}
+
+// Range 依次遍历每个分片，f 返回 false 时停止遍历，f 中不能再调用该缓存的任何方法
+func (c *ShardedCache) Range(f func(key string, value Value) bool) {
+	stopped := false
+	for _, s := range c.shards {
+		s.Range(func(key string, value Value) bool {
+			if !f(key, value) {
+				stopped = true
+				return false
+			}
+			return true
+		})
+		if stopped {
+			return
+		}
+	}
+}
+
+// Len 返回所有分片的条目数之和
+func (c *ShardedCache) Len() int {
+	n := 0
+	for _, s := range c.shards {
+		n += s.Len()
+	}
+	return n
+}
+
+// Bytes 返回所有分片已经使用的容量之和
+func (c *ShardedCache) Bytes() int64 {
+	var n int64
+	for _, s := range c.shards {
+		n += s.Bytes()
+	}
+	return n
+}
+
+// DumpKeys 依次拼接每个分片的 DumpKeys 结果，Rank 是key在所属分片内的位置
+func (c *ShardedCache) DumpKeys() []KeyInfo {
+	var keys []KeyInfo
+	for _, s := range c.shards {
+		keys = append(keys, s.DumpKeys()...)
+	}
+	return keys
+}
+
+// ShardStats 返回每个分片各自的统计信息，下标与分片一一对应，可以用来检查数据在分片之间是否均衡
+func (c *ShardedCache) ShardStats() []CacheStats {
+	stats := make([]CacheStats, len(c.shards))
+	for i, s := range c.shards {
+		stats[i] = s.Stats()
+	}
+	return stats
+}
+
+// Stats 汇总所有分片的统计信息，命中率根据汇总后的命中和未命中次数重新计算
+// 每个分片的计数都是原子读取的，但各个分片不是在同一时刻读取的，并发写入时总数只是近似值
+func (c *ShardedCache) Stats() CacheStats {
+	var total CacheStats
+	for _, s := range c.ShardStats() {
+		total.Hits += s.Hits
+		total.Misses += s.Misses
+		total.Entries += s.Entries
+		total.Bytes += s.Bytes
+		total.Expirations += s.Expirations
+	}
+	total.computeHitRatio()
+	return total
+}
+
+// ResetStats 将所有分片的统计清零，返回清零之前的汇总
+func (c *ShardedCache) ResetStats() CacheStats {
+	var total CacheStats
+	for _, shard := range c.shards {
+		s := shard.ResetStats()
+		total.Hits += s.Hits
+		total.Misses += s.Misses
+		total.Entries += s.Entries
+		total.Bytes += s.Bytes
+		total.Expirations += s.Expirations
+	}
+	total.computeHitRatio()
+	return total
+}
+
+// Clear 清空所有分片
+func (c *ShardedCache) Clear() {
+	for _, s := range c.shards {
+		s.Clear()
+	}
+}
+
+// DeleteExpired 依次清理每个分片中已经过期的数据，返回所有分片被删除的条目数之和
+func (c *ShardedCache) DeleteExpired() int {
+	n := 0
+	for _, s := range c.shards {
+		n += s.DeleteExpired()
+	}
+	return n
+}
+
+// Resize 修改总容量，每个分片平分新的容量，返回所有分片被淘汰的条目数之和
+func (c *ShardedCache) Resize(maxBytes int64) int {
+	shardBytes := maxBytes / int64(len(c.shards))
+	if maxBytes > 0 && shardBytes <= 0 {
+		shardBytes = 1
+	}
+	n := 0
+	for _, s := range c.shards {
+		n += s.Resize(shardBytes)
+	}
+	return n
+}
+
+// Close 关闭所有分片
+func (c *ShardedCache) Close() {
+	for _, s := range c.shards {
+		s.Close()
+	}
+}
@@ -0,0 +1,185 @@
+package lru
+
+import (
+	"math"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestShardedCache(t *testing.T) {
+	c := NewShardedCache(&Options{ShardCount: 4, MaxBytes: 1 << 20, DisableBackgroundCleanup: true})
+	defer c.Close()
+	for i := 0; i < 100; i++ {
+		c.AddAndUpdateCache(strconv.Itoa(i), testValue("v"))
+	}
+	if c.Len() != 100 {
+		t.Fatalf("Len() = %d，期望 100", c.Len())
+	}
+	if v, ok := c.FindCache("42"); !ok || v != testValue("v") {
+		t.Fatalf("FindCache 返回 %v %v", v, ok)
+	}
+}
+
+// 混合读写之后 Stats 的总数等于 ShardStats 中每个分片之和
+func TestShardedStats(t *testing.T) {
+	c := NewShardedCache(&Options{ShardCount: 4, DisableBackgroundCleanup: true})
+	defer c.Close()
+	for i := 0; i < 100; i++ {
+		c.AddAndUpdateCache(strconv.Itoa(i), testValue("v"))
+	}
+	for i := 0; i < 150; i++ {
+		c.FindCache(strconv.Itoa(i))
+	}
+	c.DeleteCache("1")
+
+	shards := c.ShardStats()
+	if len(shards) != 4 {
+		t.Fatalf("ShardStats 返回了 %d 个分片", len(shards))
+	}
+	var sum CacheStats
+	for _, s := range shards {
+		if s.Entries == 0 {
+			t.Fatalf("100 个key没有分布到所有分片上: %+v", shards)
+		}
+		sum.Hits += s.Hits
+		sum.Misses += s.Misses
+		sum.Entries += s.Entries
+		sum.Bytes += s.Bytes
+	}
+	total := c.Stats()
+	tests := []struct {
+		name       string
+		total, sum int64
+		want       int64
+	}{
+		{"Hits", total.Hits, sum.Hits, 100},
+		{"Misses", total.Misses, sum.Misses, 50},
+		{"Entries", int64(total.Entries), int64(sum.Entries), 99},
+		{"Bytes", total.Bytes, sum.Bytes, c.Bytes()},
+	}
+	for _, tt := range tests {
+		if tt.total != tt.want || tt.sum != tt.want {
+			t.Fatalf("%s: Stats 为 %d，分片之和为 %d，期望 %d", tt.name, tt.total, tt.sum, tt.want)
+		}
+	}
+	if total.HitRatio != 100.0/150 {
+		t.Fatalf("HitRatio = %v", total.HitRatio)
+	}
+}
+
+// 每种哈希算法下 32000 个key在 16 个分片中的分布与平均值相差不超过 10%；分片数量会向上取整为 2 的幂
+func TestShardHashDistribution(t *testing.T) {
+	const n, shards = 32000, 16
+	for _, h := range []ShardHash{"", ShardHashFNV, ShardHashXXHash, ShardHashCRC32} {
+		t.Run(string(h), func(t *testing.T) {
+			c := NewShardedCache(&Options{ShardCount: shards, ShardHash: h, MaxBytes: 1 << 30, DisableBackgroundCleanup: true})
+			defer c.Close()
+			for i := 0; i < n; i++ {
+				c.AddAndUpdateCache("user:"+strconv.Itoa(i), testValue("v"))
+			}
+			want := float64(n) / shards
+			for i, s := range c.ShardStats() {
+				if d := math.Abs(float64(s.Entries) - want); d > want*0.1 {
+					t.Fatalf("分片 %d 有 %d 个key，平均值为 %v", i, s.Entries, want)
+				}
+			}
+		})
+	}
+
+	tests := []struct {
+		count, want int
+	}{
+		{0, defaultShardCount},
+		{1, 1},
+		{10, 16},
+		{16, 16},
+		{17, 32},
+	}
+	for _, tt := range tests {
+		c := NewShardedCache(&Options{ShardCount: tt.count, DisableBackgroundCleanup: true})
+		if len(c.shards) != tt.want || c.mask != uint32(tt.want-1) {
+			t.Fatalf("ShardCount=%d 时创建了 %d 个分片，期望 %d 个", tt.count, len(c.shards), tt.want)
+		}
+		c.Close()
+	}
+	// 与标准库 hash/fnv 的 FNV-1a 结果一致
+	if got := fnv32a("hello"); got != 0x4f9f2cab {
+		t.Fatalf("fnv32a(hello) = %#x", got)
+	}
+}
+
+// 8 个协程并发读取时，单锁的 LruCache 与分片的 ShardedCache 的吞吐量对比
+func BenchmarkParallelGet(b *testing.B) {
+	for _, bc := range []struct {
+		name  string
+		store func() Store
+	}{
+		{"single", func() Store { return NewLruCache(&Options{DisableBackgroundCleanup: true}) }},
+		{"sharded", func() Store { return NewShardedCache(&Options{DisableBackgroundCleanup: true}) }},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			s := bc.store()
+			defer s.Close()
+			keys := make([]string, 1000)
+			for i := range keys {
+				keys[i] = strconv.Itoa(i)
+				s.AddAndUpdateCache(keys[i], testValue("v"))
+			}
+			b.SetParallelism(8)
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					s.FindCache(keys[i%len(keys)])
+					i++
+				}
+			})
+		})
+	}
+}
+
+// 每个分片由自己的后台协程清理，无论是否错开，所有分片中过期的条目都会在各自的一个清理周期内被回收
+func TestShardedCleanup(t *testing.T) {
+	const interval = 20 * time.Millisecond
+	tests := []struct {
+		name    string
+		stagger bool
+	}{
+		{"同时清理", false},
+		{"错开清理", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clk := NewFakeClock(time.Unix(0, 0))
+			c := NewShardedCache(&Options{ShardCount: 4, CleanupInterval: interval, CleanupStagger: tt.stagger, Clock: clk})
+			defer c.Close()
+			for i := 0; i < 100; i++ {
+				c.AddWithTTL(strconv.Itoa(i), testValue("v"), time.Second)
+			}
+			for i, s := range c.ShardStats() {
+				if s.Entries == 0 {
+					t.Fatalf("分片 %d 中没有条目", i)
+				}
+			}
+			clk.Advance(2 * time.Second)
+			// 错开时最后一个分片的第一次清理在 (N-1)/N 个周期的延迟之后，这里再留出足够的余量
+			deadline := time.Now().Add(10 * interval)
+			for {
+				done := true
+				for _, s := range c.ShardStats() {
+					if s.Entries != 0 || s.Expirations == 0 {
+						done = false
+					}
+				}
+				if done {
+					break
+				}
+				if time.Now().After(deadline) {
+					t.Fatalf("过期的条目没有被后台清理回收: %+v", c.ShardStats())
+				}
+				time.Sleep(interval / 4)
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package lru
+
+import "time"
+
+// CacheStats 缓存的统计信息
+type CacheStats struct {
+	Hits     int64   // 命中次数
+	Misses   int64   // 未命中次数
+	HitRatio float64 // 命中率，没有任何访问时为 0
+	Entries  int     // 当前缓存的条目数
+	Bytes    int64   // 当前已经使用的容量
+
+	Expirations int64 // 因为过期被删除的条目数，包括读取时发现已经过期的key，只有 LRU 和 Sharded 类型统计
+}
+
+// 根据命中和未命中次数计算命中率
+func (s *CacheStats) computeHitRatio() {
+	s.HitRatio = 0
+	if total := s.Hits + s.Misses; total > 0 {
+		s.HitRatio = float64(s.Hits) / float64(total)
+	}
+}
+
+// KeyInfo DumpKeys 返回的单个key的信息
+type KeyInfo struct {
+	Key  string
+	Size int64         // len(key) + value.Len()，配置了 Weigher 时为 Weigher 的结果
+	TTL  time.Duration // 剩余的过期时间，0 表示永不过期
+	Rank int           // 距离链表头部的位置，0 表示最先被淘汰
+}
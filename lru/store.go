@@ -1,29 +1,181 @@
 package lru
 
-import "time"
+import (
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"math"
+	"math/rand"
+	"time"
+)
 
 type Store interface {
 	AddAndUpdateCache(key string, value Value) error
+	AddWithTTL(key string, value Value, ttl time.Duration) error
+	AddIfAbsent(key string, value Value, ttl time.Duration) (bool, error)
 	DeleteCache(key string) error
 	FindCache(key string) (Value, bool)
+	Peek(key string) (Value, bool)
+	Contains(key string) bool
+	TTL(key string) (time.Duration, bool)
+	Range(f func(key string, value Value) bool)
+	Len() int
+	Bytes() int64
+	Clear()
+	Resize(maxBytes int64) int
 	Close()
 }
 type Value interface {
 	Len() int
 }
 
+// EvictReason 数据离开缓存的原因
+type EvictReason int
+
+const (
+	ReasonCapacity EvictReason = iota // 容量不足被淘汰
+	ReasonExpired                     // 过期
+	ReasonDeleted                     // 被主动删除
+	ReasonCleared                     // Clear 清空缓存
+)
+
+func (r EvictReason) String() string {
+	switch r {
+	case ReasonCapacity:
+		return "capacity"
+	case ReasonExpired:
+		return "expired"
+	case ReasonDeleted:
+		return "deleted"
+	case ReasonCleared:
+		return "cleared"
+	default:
+		return "unknown"
+	}
+}
+
+// IgnoreReason 将不关心淘汰原因的旧回调转换成 OnEvicted 需要的形式
+func IgnoreReason(f func(key string, value Value)) func(key string, value Value, reason EvictReason) {
+	if f == nil {
+		return nil
+	}
+	return func(key string, value Value, _ EvictReason) {
+		f(key, value)
+	}
+}
+
+// ErrValueTooLarge 单个键值对超过了 Options.MaxValueBytes，或者更新已有的key时新的值超过了 Options.MaxBytes，
+// 写入被拒绝，缓存保持不变
+var ErrValueTooLarge = errors.New("键值对超过了最大大小限制")
+
+// 检查单个键值对的大小，maxValueBytes<=0 时不限制
+func checkValueSize(key string, size int64, maxValueBytes int64) error {
+	if maxValueBytes > 0 && size > maxValueBytes {
+		return fmt.Errorf("%w: key %q 大小为 %d，限制为 %d", ErrValueTooLarge, key, size, maxValueBytes)
+	}
+	return nil
+}
+
+// 更新已经存在的key时检查新的值是否超过了整个缓存的容量，超过时拒绝更新，避免为了放下它而淘汰所有其他数据
+func checkCapacity(key string, size int64, maxBytes int64) error {
+	if maxBytes > 0 && size > maxBytes {
+		return fmt.Errorf("%w: key %q 大小为 %d，超过了最大容量 %d", ErrValueTooLarge, key, size, maxBytes)
+	}
+	return nil
+}
+
+// 计算键值对占用的容量，weigher 为空时为 len(key)+value.Len()
+func entrySize(weigher func(key string, value Value) int64, key string, value Value) int64 {
+	if weigher != nil {
+		return weigher(key, value)
+	}
+	return int64(len(key) + value.Len())
+}
+
+// EntryStat 单个键值对的统计信息
+type EntryStat struct {
+	Size       int64         // 占用的容量，len(key)+value.Len()，配置了 Weigher 时为 Weigher 的结果
+	InsertedAt time.Time     // 插入的时间
+	LastAccess time.Time     // 最近一次命中的时间，没有被访问过时为零值
+	Hits       int64         // 命中次数
+	TTL        time.Duration // 剩余的过期时间，0 表示永不过期
+}
+
+// NoExpiration 作为过期时间传入时表示永不过期，永不过期的key不会记录在 expires 中，清理时也不会被扫描到
+const NoExpiration time.Duration = 0
+
+// KeepTTL 作为写入的过期时间传入时，更新已经存在的key会保留原来的过期时间，写入新的key时使用默认过期时间
+// 用于只替换value的场景（例如计数器），避免每次写回都重新计算过期时间和抖动，导致频繁写入的key永远不会过期
+const KeepTTL time.Duration = math.MinInt64
+
+// jitterTTL 在 ttl 的基础上加上 [-jitter, jitter] 之间的随机抖动，结果始终大于 0，即过期时间不会早于当前时间
+func jitterTTL(ttl, jitter time.Duration) time.Duration {
+	if jitter <= 0 {
+		return ttl
+	}
+	ttl += time.Duration(rand.Int63n(int64(2*jitter)+1)) - jitter
+	if ttl <= 0 {
+		ttl = time.Nanosecond
+	}
+	return ttl
+}
+
 // 需要传递的初始化参数
 type Options struct {
 	MaxBytes        int64
-	OnEvicted       func(key string, value Value)
+	MaxEntries      int64                                             // 最大条目数，0 表示不限制，与 MaxBytes 任意一个超出都会触发淘汰
+	MaxValueBytes   int64                                             // 单个键值对（len(key)+value.Len()）的最大大小，超出时写入返回 ErrValueTooLarge，0 表示不限制
+	OnEvicted       func(key string, value Value, reason EvictReason) // 数据离开缓存时的回调，旧的回调可以通过 IgnoreReason 转换
 	CleanupInterval time.Duration
+	DefaultTTL      time.Duration // AddAndUpdateCache 使用的默认过期时间，NoExpiration 表示永不过期
+	Logger          *zap.Logger   // 日志输出，为空时使用 zap.NewNop()
+	ShardCount      int           // Sharded 类型的分片数量，默认为 16
+	TTLJitter       time.Duration // 过期时间的随机抖动范围，每个key的过期时间会在 ttl±TTLJitter 之间随机，避免同时写入的key同时过期
+	EvictionSamples int           // 大于 0 时 LRU 使用采样淘汰：每次随机查看这么多条目并淘汰其中最久没有访问的，读取时不再移动链表节点
+	PromotionBuffer int           // 大于 0 时 LRU 开启延迟提升：命中的元素先放入这么大的队列，由后台协程批量移动到队尾，读取只需要读锁
+	TrackHotKeys    bool          // 是否统计key的访问次数，开启后可以通过 TopK 获取热点key，使用固定大小的 count-min sketch，内存占用与key的数量无关
+	// TwoQueue 类型的参数
+	TwoQueueRecentRatio float64 // A1in 队列占总容量的比例，取值 (0, 1)，默认为 0.25
+	TwoQueueGhostRatio  float64 // A1out 记录的已淘汰 key 相当于总容量的比例，默认为 0.5
+
+	// 是否开启滑动过期，开启后 LRU 每次 FindCache 命中都会把过期时间刷新为 now + ttl，没有开启时过期时间是固定的
+	SlidingExpiration bool
+
+	// Sharded 类型选择分片使用的哈希算法，为空时使用 ShardHashFNV
+	ShardHash ShardHash
+
+	// 时间来源，为空时使用系统时间，测试时可以传入 FakeClock
+	Clock Clock
+
+	// Sharded 类型每个分片都有独立的清理协程，开启后第 i 个分片的第一次清理推迟 i*CleanupInterval/ShardCount，
+	// 使各个分片的清理时间均匀错开
+	CleanupStagger bool
+
+	// 不启动后台清理协程，适合生命周期很短的进程或者测试，过期数据只会在访问或者写入时被清理
+	DisableBackgroundCleanup bool
+
+	// 淘汰日志的汇总间隔，淘汰数据时不逐条输出日志，而是每个间隔最多输出一条 "淘汰了多少条目、多少字节" 的汇总
+	// 为 0 时默认为 10 秒，小于 0 时不输出淘汰日志
+	EvictionLogInterval time.Duration
+
+	// 自定义每个键值对占用的容量，例如按照行数而不是字节数计算，为空时使用 len(key)+value.Len()
+	// 配置之后 MaxBytes、MaxValueBytes 和 Bytes 都以 Weigher 的结果为单位；同一个键值对多次调用必须返回相同的结果，并且不能为负数
+	Weigher func(key string, value Value) int64
+
+	// 后台清理、Resize 和 DeleteExpired 每次获取写锁最多删除的条目数，0 表示不限制，只对 LRU 和 Sharded 类型有效
+	// 需要一次回收大量数据时分批进行，批与批之间释放写锁，避免长时间阻塞其他协程；写入时触发的淘汰不受限制，保证写入返回时不超过容量
+	EvictionBatchSize int
 }
 
 // CacheType 缓存类型
 type CacheType string
 
 const (
-	LRU CacheType = "lru"
+	LRU      CacheType = "lru"
+	LFU      CacheType = "lfu"
+	FIFO     CacheType = "fifo"
+	Sharded  CacheType = "sharded" // 按 key 分片的 LRU
+	TwoQueue CacheType = "2q"      // 抗顺序扫描的 2Q
 )
 
 // 工厂模式
@@ -31,6 +183,14 @@ func NewStore(cacheType CacheType, opt *Options) Store {
 	switch cacheType {
 	case LRU:
 		return NewLruCache(opt)
+	case LFU:
+		return NewLfuCache(opt)
+	case FIFO:
+		return NewFifoCache(opt)
+	case Sharded:
+		return NewShardedCache(opt)
+	case TwoQueue:
+		return NewTwoQueueCache(opt)
 	default:
 		return NewLruCache(opt)
 	}
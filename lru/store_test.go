@@ -0,0 +1,452 @@
+package lru
+
+import (
+	"errors"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// NewStore 使用导出的 Options 字段创建 LruCache，配置项被正确地传递
+func TestNewStoreLru(t *testing.T) {
+	var evicted []string
+	s := NewStore(LRU, &Options{
+		MaxBytes:        4,
+		CleanupInterval: time.Hour,
+		OnEvicted: func(key string, value Value, reason EvictReason) {
+			evicted = append(evicted, key)
+		},
+	})
+	defer s.Close()
+	c, ok := s.(*LruCache)
+	if !ok {
+		t.Fatalf("NewStore(LRU) 应该返回 *LruCache，实际为 %T", s)
+	}
+	if c.maxBytes != 4 || c.cleanupInterval != time.Hour {
+		t.Fatalf("配置没有生效: maxBytes=%d cleanupInterval=%v", c.maxBytes, c.cleanupInterval)
+	}
+	s.AddAndUpdateCache("a", testValue("1"))
+	s.AddAndUpdateCache("b", testValue("1"))
+	s.AddAndUpdateCache("c", testValue("1"))
+	if len(evicted) != 1 || evicted[0] != "a" {
+		t.Fatalf("超出容量时应该淘汰 a 并调用 OnEvicted，实际淘汰了 %v", evicted)
+	}
+	if _, ok := NewStore("unknown", &Options{DisableBackgroundCleanup: true}).(*LruCache); !ok {
+		t.Fatal("未知的类型应该使用 LRU")
+	}
+}
+
+// 关闭后台清理时不会启动任何协程，过期只在访问时惰性检查
+func TestDisableBackgroundCleanupStartsNoGoroutine(t *testing.T) {
+	before := runtime.NumGoroutine()
+	var stores []Store
+	for _, ct := range []CacheType{LRU, LFU, FIFO, Sharded, TwoQueue} {
+		stores = append(stores, NewStore(ct, &Options{DisableBackgroundCleanup: true}))
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		t.Fatalf("创建缓存之后协程数量从 %d 增加到了 %d", before, n)
+	}
+	for _, s := range stores {
+		s.Close()
+	}
+
+	clock := NewFakeClock(time.Unix(0, 0))
+	c := NewLruCache(&Options{DisableBackgroundCleanup: true, Clock: clock})
+	defer c.Close()
+	c.AddWithTTL("a", testValue("1"), time.Second)
+	clock.Advance(2 * time.Second)
+	if _, ok := c.FindCache("a"); ok || c.Len() != 0 {
+		t.Fatal("访问已经过期的key时应该惰性删除")
+	}
+}
+
+// Close 等待后台协程退出，关闭之后不会留下泄漏的协程
+func TestCloseLeavesNoGoroutine(t *testing.T) {
+	before := runtime.NumGoroutine()
+	c := NewLruCache(&Options{CleanupInterval: time.Millisecond, PromotionBuffer: 4})
+	c.Close()
+	if n := runtime.NumGoroutine(); n > before {
+		t.Fatalf("Close 之后协程数量从 %d 增加到了 %d", before, n)
+	}
+}
+
+// 重复调用 Close 以及并发调用 Close 都不会 panic，启用了后台清理的缓存同样如此
+func TestCloseIdempotent(t *testing.T) {
+	for _, ct := range []CacheType{LRU, LFU, FIFO, Sharded, TwoQueue} {
+		for _, disable := range []bool{false, true} {
+			s := NewStore(ct, &Options{CleanupInterval: time.Millisecond, DisableBackgroundCleanup: disable})
+			s.Close()
+			s.Close()
+
+			s = NewStore(ct, &Options{CleanupInterval: time.Millisecond, DisableBackgroundCleanup: disable})
+			var wg sync.WaitGroup
+			for i := 0; i < 8; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					s.Close()
+				}()
+			}
+			wg.Wait()
+		}
+	}
+}
+
+// 更新已经存在的key时，新的值超过整个缓存的容量会返回 ErrValueTooLarge，其他数据和旧值都保持不变
+func TestOversizedUpdateRejected(t *testing.T) {
+	for _, ct := range []CacheType{LRU, LFU, FIFO, TwoQueue} {
+		t.Run(string(ct), func(t *testing.T) {
+			s := NewStore(ct, &Options{MaxBytes: 10, DisableBackgroundCleanup: true})
+			defer s.Close()
+			s.AddAndUpdateCache("a", testValue("1"))
+			s.AddAndUpdateCache("b", testValue("1"))
+			if err := s.AddAndUpdateCache("a", testValue("0123456789")); !errors.Is(err, ErrValueTooLarge) {
+				t.Fatalf("应该返回 ErrValueTooLarge，实际为 %v", err)
+			}
+			if s.Len() != 2 || s.Bytes() != 4 {
+				t.Fatalf("缓存被改变了: Len=%d Bytes=%d", s.Len(), s.Bytes())
+			}
+			if v, ok := s.Peek("a"); !ok || v != testValue("1") {
+				t.Fatalf("a 的值为 %v %v", v, ok)
+			}
+		})
+	}
+}
+
+// 更新为更大的值时先淘汰旧的数据腾出空间，容量统计与实际存放的数据一致
+func TestUpdateEvictsToFit(t *testing.T) {
+	for _, ct := range []CacheType{LRU, FIFO} {
+		t.Run(string(ct), func(t *testing.T) {
+			s := NewStore(ct, &Options{MaxBytes: 10, DisableBackgroundCleanup: true})
+			defer s.Close()
+			s.AddAndUpdateCache("a", testValue("1"))
+			s.AddAndUpdateCache("b", testValue("1"))
+			s.AddAndUpdateCache("c", testValue("1"))
+			if err := s.AddAndUpdateCache("c", testValue("123456")); err != nil {
+				t.Fatalf("淘汰之后可以放下，不应该返回错误: %v", err)
+			}
+			if s.Len() != 2 || s.Bytes() != 9 {
+				t.Fatalf("更新之后 Len=%d Bytes=%d，期望 2 和 9", s.Len(), s.Bytes())
+			}
+			if s.Contains("a") {
+				t.Fatal("最早写入的 a 应该被淘汰")
+			}
+			if v, ok := s.Peek("c"); !ok || v != testValue("123456") {
+				t.Fatalf("c 的值为 %v %v", v, ok)
+			}
+		})
+	}
+}
+
+// Clear 删除所有条目并以 ReasonCleared 触发回调，清空之后容量统计归零，缓存仍然可以继续使用
+func TestClear(t *testing.T) {
+	for _, ct := range []CacheType{LRU, LFU, FIFO, Sharded, TwoQueue} {
+		t.Run(string(ct), func(t *testing.T) {
+			evicted := map[string]EvictReason{}
+			var mu sync.Mutex
+			s := NewStore(ct, &Options{DisableBackgroundCleanup: true, OnEvicted: func(key string, value Value, reason EvictReason) {
+				mu.Lock()
+				defer mu.Unlock()
+				evicted[key] = reason
+			}})
+			defer s.Close()
+			for i := 0; i < 10; i++ {
+				s.AddWithTTL(strconv.Itoa(i), testValue("v"), time.Duration(i)*time.Hour)
+			}
+			s.Clear()
+			if s.Len() != 0 || s.Bytes() != 0 {
+				t.Fatalf("Clear 之后 Len=%d Bytes=%d", s.Len(), s.Bytes())
+			}
+			mu.Lock()
+			if len(evicted) != 10 || evicted["3"] != ReasonCleared {
+				t.Fatalf("回调收到的条目为 %v", evicted)
+			}
+			mu.Unlock()
+			s.AddAndUpdateCache("a", testValue("1"))
+			if v, ok := s.FindCache("a"); !ok || v != testValue("1") || s.Len() != 1 {
+				t.Fatalf("Clear 之后写入的值为 %v %v", v, ok)
+			}
+		})
+	}
+}
+
+// MaxEntries 和 MaxBytes 同时设置时，先达到的那一个触发淘汰
+func TestMaxEntries(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxEntries int64
+		maxBytes   int64
+		value      testValue
+		wantLen    int
+	}{
+		{"只限制条目数", 3, 0, "1", 3},
+		{"条目数先达到上限", 3, 1000, "1", 3},
+		{"容量先达到上限", 3, 8, "123", 2},
+	}
+	for _, tt := range tests {
+		for _, ct := range []CacheType{LRU, FIFO} {
+			t.Run(tt.name+"/"+string(ct), func(t *testing.T) {
+				s := NewStore(ct, &Options{MaxEntries: tt.maxEntries, MaxBytes: tt.maxBytes, DisableBackgroundCleanup: true})
+				defer s.Close()
+				for _, k := range []string{"a", "b", "c", "d"} {
+					s.AddAndUpdateCache(k, tt.value)
+				}
+				if s.Len() != tt.wantLen || s.Contains("a") || !s.Contains("d") {
+					t.Fatalf("写入 4 个key之后剩余 %d 个，期望 %d 个并且淘汰最早的 a", s.Len(), tt.wantLen)
+				}
+			})
+		}
+	}
+	for _, ct := range []CacheType{LFU, Sharded, TwoQueue} {
+		s := NewStore(ct, &Options{MaxEntries: 3, ShardCount: 1, DisableBackgroundCleanup: true})
+		for i := 0; i < 10; i++ {
+			s.AddAndUpdateCache(strconv.Itoa(i), testValue("1"))
+		}
+		if s.Len() > 3 {
+			t.Fatalf("%s: 剩余 %d 个key，超过了 MaxEntries", ct, s.Len())
+		}
+		s.Close()
+	}
+}
+
+// 缩小容量时立即淘汰到新的上限以内并返回淘汰的条目数，扩大容量不会淘汰任何数据
+func TestResize(t *testing.T) {
+	tests := []struct {
+		name      string
+		ct        CacheType
+		batchSize int
+	}{
+		{"LRU", LRU, 0},
+		{"LRU 分批淘汰", LRU, 1},
+		{"LFU", LFU, 0},
+		{"FIFO", FIFO, 0},
+		{"TwoQueue", TwoQueue, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewStore(tt.ct, &Options{MaxBytes: 100, EvictionBatchSize: tt.batchSize, DisableBackgroundCleanup: true})
+			defer s.Close()
+			for i := 0; i < 5; i++ {
+				s.AddAndUpdateCache(strconv.Itoa(i), testValue("1"))
+			}
+			if n := s.Resize(1000); n != 0 || s.Len() != 5 {
+				t.Fatalf("扩大容量淘汰了 %d 个", n)
+			}
+			if n := s.Resize(6); n != 2 || s.Bytes() > 6 || s.Len() != 3 {
+				t.Fatalf("缩小容量淘汰了 %d 个，剩余 Len=%d Bytes=%d", n, s.Len(), s.Bytes())
+			}
+			s.AddAndUpdateCache("x", testValue("1"))
+			if s.Bytes() > 6 {
+				t.Fatalf("缩小容量之后写入仍然超过了新的上限: %d", s.Bytes())
+			}
+		})
+	}
+}
+
+// Range 遍历所有未过期的条目，f 返回 false 时停止遍历
+func TestRange(t *testing.T) {
+	for _, ct := range []CacheType{LRU, LFU, FIFO, Sharded, TwoQueue} {
+		t.Run(string(ct), func(t *testing.T) {
+			clock := NewFakeClock(time.Unix(0, 0))
+			s := NewStore(ct, &Options{Clock: clock, DisableBackgroundCleanup: true})
+			defer s.Close()
+			s.AddAndUpdateCache("a", testValue("1"))
+			s.AddAndUpdateCache("b", testValue("2"))
+			s.AddAndUpdateCache("c", testValue("3"))
+			s.AddWithTTL("expired", testValue("4"), time.Second)
+			clock.Advance(2 * time.Second)
+
+			got := map[string]Value{}
+			s.Range(func(key string, value Value) bool {
+				got[key] = value
+				return true
+			})
+			want := map[string]Value{"a": testValue("1"), "b": testValue("2"), "c": testValue("3")}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("Range 遍历到 %v，期望 %v", got, want)
+			}
+			n := 0
+			s.Range(func(key string, value Value) bool {
+				n++
+				return false
+			})
+			if n != 1 {
+				t.Fatalf("f 返回 false 之后仍然遍历了 %d 个条目", n)
+			}
+		})
+	}
+}
+
+// 每种删除路径都以对应的原因触发 onEvicted 回调，IgnoreReason 可以继续使用旧的回调签名
+func TestEvictReason(t *testing.T) {
+	for _, ct := range []CacheType{LRU, LFU, FIFO, Sharded, TwoQueue} {
+		t.Run(string(ct), func(t *testing.T) {
+			clock := NewFakeClock(time.Unix(0, 0))
+			got := map[string]EvictReason{}
+			s := NewStore(ct, &Options{MaxEntries: 2, ShardCount: 1, Clock: clock, DisableBackgroundCleanup: true,
+				OnEvicted: func(key string, value Value, reason EvictReason) { got[key] = reason }})
+			defer s.Close()
+			s.AddAndUpdateCache("a", testValue("1"))
+			s.AddAndUpdateCache("b", testValue("1"))
+			s.AddAndUpdateCache("c", testValue("1"))
+			s.DeleteCache("b")
+			s.AddWithTTL("e", testValue("1"), time.Second)
+			clock.Advance(2 * time.Second)
+			s.FindCache("e")
+			s.Clear()
+
+			want := map[string]EvictReason{"a": ReasonCapacity, "b": ReasonDeleted, "e": ReasonExpired, "c": ReasonCleared}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("回调收到的原因为 %v，期望 %v", got, want)
+			}
+		})
+	}
+
+	var keys []string
+	s := NewLruCache(&Options{MaxEntries: 1, DisableBackgroundCleanup: true, OnEvicted: IgnoreReason(func(key string, value Value) {
+		keys = append(keys, key)
+	})})
+	defer s.Close()
+	s.AddAndUpdateCache("a", testValue("1"))
+	s.AddAndUpdateCache("b", testValue("1"))
+	if !reflect.DeepEqual(keys, []string{"a"}) {
+		t.Fatalf("IgnoreReason 包装的回调收到 %v", keys)
+	}
+}
+
+// 并发对同一个key调用 AddIfAbsent 只有一个返回 added=true；已经过期的key视为不存在
+func TestAddIfAbsent(t *testing.T) {
+	for _, ct := range []CacheType{LRU, LFU, FIFO, Sharded, TwoQueue} {
+		t.Run(string(ct), func(t *testing.T) {
+			clock := NewFakeClock(time.Unix(0, 0))
+			s := NewStore(ct, &Options{Clock: clock, DisableBackgroundCleanup: true})
+			defer s.Close()
+			var added int32
+			var wg sync.WaitGroup
+			for i := 0; i < 50; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					if ok, err := s.AddIfAbsent("k", testValue(strconv.Itoa(i)), 0); err == nil && ok {
+						atomic.AddInt32(&added, 1)
+					}
+				}(i)
+			}
+			wg.Wait()
+			if added != 1 {
+				t.Fatalf("%d 个调用返回了 added=true", added)
+			}
+
+			tests := []struct {
+				name      string
+				key       string
+				wantAdded bool
+			}{
+				{"已经存在", "k", false},
+				{"已经过期", "e", true},
+				{"不存在", "new", true},
+			}
+			s.AddWithTTL("e", testValue("old"), time.Second)
+			clock.Advance(2 * time.Second)
+			for _, tt := range tests {
+				ok, err := s.AddIfAbsent(tt.key, testValue("v"), time.Hour)
+				if err != nil || ok != tt.wantAdded {
+					t.Fatalf("%s: AddIfAbsent(%q) = %v %v", tt.name, tt.key, ok, err)
+				}
+			}
+			if ttl, _ := s.TTL("e"); ttl != time.Hour {
+				t.Fatalf("重新写入的 e 应该使用新的过期时间，实际为 %v", ttl)
+			}
+			if s.Len() != 3 {
+				t.Fatalf("剩余 %d 个key，期望 3 个", s.Len())
+			}
+		})
+	}
+}
+
+// 超过 MaxValueBytes 的写入返回 ErrValueTooLarge，缓存中原有的数据保持不变
+func TestMaxValueBytes(t *testing.T) {
+	for _, ct := range []CacheType{LRU, LFU, FIFO, Sharded, TwoQueue} {
+		t.Run(string(ct), func(t *testing.T) {
+			s := NewStore(ct, &Options{MaxValueBytes: 5, DisableBackgroundCleanup: true})
+			defer s.Close()
+			if err := s.AddAndUpdateCache("a", testValue("1234")); err != nil {
+				t.Fatalf("len(key)+Len() 等于 MaxValueBytes 时应该写入成功: %v", err)
+			}
+			tests := []struct {
+				name  string
+				write func() error
+			}{
+				{"新增", func() error { return s.AddAndUpdateCache("b", testValue("12345")) }},
+				{"更新", func() error { return s.AddAndUpdateCache("a", testValue("12345")) }},
+				{"带过期时间", func() error { return s.AddWithTTL("c", testValue("12345"), time.Hour) }},
+				{"AddIfAbsent", func() error { _, err := s.AddIfAbsent("d", testValue("12345"), 0); return err }},
+			}
+			for _, tt := range tests {
+				if err := tt.write(); !errors.Is(err, ErrValueTooLarge) {
+					t.Fatalf("%s: 应该返回 ErrValueTooLarge，实际为 %v", tt.name, err)
+				}
+			}
+			if v, _ := s.Peek("a"); s.Len() != 1 || v != testValue("1234") || s.Bytes() != 5 {
+				t.Fatalf("缓存被改变了: Len=%d Bytes=%d a=%v", s.Len(), s.Bytes(), v)
+			}
+		})
+	}
+}
+
+// 配置 Weigher 之后容量按照权重计算：很大但权重为 1 的值可以放下很多个，很小但权重为 100 的值只能放下两个
+func TestWeigher(t *testing.T) {
+	weigher := func(key string, v Value) int64 {
+		if v.Len() >= 1000 {
+			return 1
+		}
+		return 100
+	}
+	big := testValue(strings.Repeat("x", 5000))
+	for _, ct := range []CacheType{LRU, LFU, FIFO, Sharded, TwoQueue} {
+		t.Run(string(ct), func(t *testing.T) {
+			s := NewStore(ct, &Options{MaxBytes: 250, ShardCount: 1, Weigher: weigher, MaxValueBytes: 150, DisableBackgroundCleanup: true})
+			defer s.Close()
+			for i := 0; i < 5; i++ {
+				if err := s.AddAndUpdateCache("big"+strconv.Itoa(i), big); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if s.Len() != 5 || s.Bytes() != 5 {
+				t.Fatalf("5 个权重为 1 的大值: Len=%d Bytes=%d", s.Len(), s.Bytes())
+			}
+			for i := 0; i < 3; i++ {
+				s.AddAndUpdateCache("small"+strconv.Itoa(i), testValue("a"))
+			}
+			small := 0
+			for i := 0; i < 3; i++ {
+				if s.Contains("small" + strconv.Itoa(i)) {
+					small++
+				}
+			}
+			if s.Bytes() > 250 || small != 2 || !s.Contains("small2") {
+				t.Fatalf("写入 3 个小值之后 Bytes=%d，保留了 %d 个小值", s.Bytes(), small)
+			}
+			// 更新为大值之后权重随之改变
+			s.AddAndUpdateCache("small2", big)
+			if v, _ := s.Peek("small2"); v != big || s.Bytes() > 250 {
+				t.Fatalf("更新之后 Bytes=%d", s.Bytes())
+			}
+			s.Clear()
+			if s.Bytes() != 0 {
+				t.Fatalf("Clear 之后 Bytes=%d", s.Bytes())
+			}
+
+			heavy := NewStore(ct, &Options{Weigher: func(string, Value) int64 { return 200 }, MaxValueBytes: 150, DisableBackgroundCleanup: true})
+			defer heavy.Close()
+			if err := heavy.AddAndUpdateCache("x", testValue("a")); !errors.Is(err, ErrValueTooLarge) {
+				t.Fatalf("权重超过 MaxValueBytes 时应该返回 ErrValueTooLarge，实际为 %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package lru
+
+import (
+	"go.uber.org/zap"
+	"time"
+)
+
+// AddWithTags 与 AddWithTTL 相同，并为该key附加标签，之后可以通过 InvalidateTag 删除带有某个标签的所有key
+// 每次写入都会替换原来的标签，不带标签的写入（AddAndUpdateCache、AddWithTTL 等）会清除原来的标签；重复的标签只记录一次
+func (c *LruCache) AddWithTags(key string, value Value, ttl time.Duration, tags ...string) error {
+	if value == nil {
+		return nil
+	}
+	if err := checkValueSize(key, c.sizeOf(key, value), c.maxValueBytes); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.set(key, value, ttl); err != nil {
+		return err
+	}
+	// 写入之后的淘汰可能已经把它删除了
+	if elem, ok := c.items[key]; ok {
+		c.setTags(elem.Value.(*LruEntry), tags)
+	}
+	return nil
+}
+
+// InvalidateTag 删除所有带有 tag 标签的key，每个被删除的元素都会以 ReasonDeleted 触发 onEvicted 回调，返回删除的条目数
+func (c *LruCache) InvalidateTag(tag string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for key := range c.tagIndex[tag] {
+		elem, ok := c.items[key]
+		if !ok {
+			continue
+		}
+		if err := c.removeCache(elem, ReasonDeleted); err != nil {
+			c.log.Error("InvalidateTag 删除节点报错", zap.String("key", key), zap.Error(err))
+			continue
+		}
+		n++
+	}
+	delete(c.tagIndex, tag)
+	return n
+}
+
+// Tags 返回key当前的标签，key不存在或者已经过期时返回 false
+func (c *LruCache) Tags(key string) ([]string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	if t, ok := c.expires[key]; ok && c.clk.Now().After(t) {
+		return nil, false
+	}
+	tags := elem.Value.(*LruEntry).tags
+	return append([]string(nil), tags...), true
+}
+
+// setTags 替换条目的标签并维护反向索引，tags 为空时清除所有标签，调用此方法前必须持有写锁
+func (c *LruCache) setTags(entry *LruEntry, tags []string) {
+	for _, tag := range entry.tags {
+		keys := c.tagIndex[tag]
+		delete(keys, entry.key)
+		if len(keys) == 0 {
+			delete(c.tagIndex, tag)
+		}
+	}
+	entry.tags = nil
+	for _, tag := range tags {
+		keys, ok := c.tagIndex[tag]
+		if !ok {
+			keys = make(map[string]struct{})
+			c.tagIndex[tag] = keys
+		}
+		if _, dup := keys[entry.key]; dup {
+			continue
+		}
+		keys[entry.key] = struct{}{}
+		entry.tags = append(entry.tags, tag)
+	}
+}
+
+// AddWithTags 写入key所在的分片
+func (c *ShardedCache) AddWithTags(key string, value Value, ttl time.Duration, tags ...string) error {
+	return c.shard(key).AddWithTags(key, value, ttl, tags...)
+}
+
+// InvalidateTag 在每个分片中删除带有 tag 标签的key，返回所有分片删除的条目数之和
+func (c *ShardedCache) InvalidateTag(tag string) int {
+	n := 0
+	for _, s := range c.shards {
+		n += s.InvalidateTag(tag)
+	}
+	return n
+}
+
+func (c *ShardedCache) Tags(key string) ([]string, bool) {
+	return c.shard(key).Tags(key)
+}
@@ -0,0 +1,131 @@
+package lru
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// 支持标签的缓存
+type taggedStore interface {
+	Store
+	AddWithTags(key string, value Value, ttl time.Duration, tags ...string) error
+	InvalidateTag(tag string) int
+	Tags(key string) ([]string, bool)
+}
+
+// InvalidateTag 只删除带有该标签的key，重复的标签只记录一次，不带标签的覆盖写入会清除原来的标签
+func TestInvalidateTag(t *testing.T) {
+	tests := []struct {
+		name string
+		c    taggedStore
+	}{
+		{"LruCache", NewLruCache(&Options{MaxEntries: 100, DisableBackgroundCleanup: true})},
+		{"ShardedCache", NewShardedCache(&Options{ShardCount: 4, DisableBackgroundCleanup: true})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := tt.c
+			defer c.Close()
+			c.AddWithTags("u1", testValue("v"), 0, "user", "a")
+			c.AddWithTags("u2", testValue("v"), 0, "user", "user")
+			c.AddWithTags("p1", testValue("v"), 0, "post")
+			c.AddAndUpdateCache("plain", testValue("v"))
+			c.AddWithTags("u3", testValue("v"), 0, "user")
+			c.AddAndUpdateCache("u3", testValue("v2"))
+			if tags, _ := c.Tags("u2"); !reflect.DeepEqual(tags, []string{"user"}) {
+				t.Fatalf("u2 的标签为 %v", tags)
+			}
+			if n := c.InvalidateTag("user"); n != 2 {
+				t.Fatalf("应该删除 2 个key，实际为 %d", n)
+			}
+			for _, k := range []string{"p1", "plain", "u3"} {
+				if !c.Contains(k) {
+					t.Fatalf("%s 不带 user 标签，不应该被删除", k)
+				}
+			}
+			for _, k := range []string{"u1", "u2"} {
+				if c.Contains(k) {
+					t.Fatalf("%s 应该被删除", k)
+				}
+			}
+			// 不存在的标签、已经失效的标签以及key被删除之后的标签都不会删除任何key
+			for _, tag := range []string{"user", "a", "none"} {
+				if n := c.InvalidateTag(tag); n != 0 {
+					t.Fatalf("InvalidateTag(%q) 删除了 %d 个key", tag, n)
+				}
+			}
+			c.DeleteCache("p1")
+			if n := c.InvalidateTag("post"); n != 0 {
+				t.Fatalf("p1 删除之后 InvalidateTag 仍然删除了 %d 个key", n)
+			}
+			if c.Len() != 2 {
+				t.Fatalf("剩余 %d 个key，期望 2 个", c.Len())
+			}
+		})
+	}
+}
+
+// 带标签重新写入时替换原来的标签，旧标签不再指向该key
+func TestRetagOnOverwrite(t *testing.T) {
+	c := NewLruCache(&Options{MaxEntries: 100, DisableBackgroundCleanup: true})
+	defer c.Close()
+	c.AddWithTags("k", testValue("v1"), 0, "old", "shared")
+	c.AddWithTags("k", testValue("v2"), 0, "new", "shared")
+	if tags, ok := c.Tags("k"); !ok || !reflect.DeepEqual(tags, []string{"new", "shared"}) {
+		t.Fatalf("k 的标签为 %v %v", tags, ok)
+	}
+	if _, ok := c.tagIndex["old"]; ok {
+		t.Fatal("旧标签应该从反向索引中删除")
+	}
+	if n := c.InvalidateTag("old"); n != 0 || !c.Contains("k") {
+		t.Fatalf("旧标签不应该删除 k，删除了 %d 个", n)
+	}
+	if n := c.InvalidateTag("new"); n != 1 || c.Contains("k") {
+		t.Fatalf("新标签应该删除 k，删除了 %d 个", n)
+	}
+	if len(c.tagIndex) != 0 {
+		t.Fatalf("key删除之后反向索引中仍有 %v", c.tagIndex)
+	}
+}
+
+// 被淘汰或者过期删除的key同时从反向索引中删除
+func TestTagsCleanup(t *testing.T) {
+	tests := []struct {
+		name   string
+		remove func(c *LruCache, clock *FakeClock)
+	}{
+		{"容量淘汰", func(c *LruCache, clock *FakeClock) {
+			c.AddAndUpdateCache("x", testValue("v"))
+			c.AddAndUpdateCache("y", testValue("v"))
+		}},
+		{"后台清理过期的key", func(c *LruCache, clock *FakeClock) {
+			clock.Advance(2 * time.Second)
+			c.DeleteExpired()
+		}},
+		{"读取时发现过期", func(c *LruCache, clock *FakeClock) {
+			clock.Advance(2 * time.Second)
+			c.FindCache("a")
+			c.FindCache("b")
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := NewFakeClock(time.Unix(0, 0))
+			c := NewLruCache(&Options{MaxEntries: 2, Clock: clock, DisableBackgroundCleanup: true})
+			defer c.Close()
+			c.AddWithTags("a", testValue("v"), time.Second, "t")
+			c.AddWithTags("b", testValue("v"), time.Second, "t")
+			tt.remove(c, clock)
+			if c.Contains("a") || c.Contains("b") {
+				t.Fatal("a 和 b 应该已经被删除")
+			}
+			if len(c.tagIndex) != 0 {
+				t.Fatalf("删除之后反向索引中仍有 %v", c.tagIndex)
+			}
+			if n := c.InvalidateTag("t"); n != 0 {
+				t.Fatalf("InvalidateTag 删除了 %d 个key", n)
+			}
+		})
+	}
+}
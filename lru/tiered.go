@@ -0,0 +1,175 @@
+package lru
+
+import "time"
+
+// TierWritePolicy TieredCache 的写入策略
+type TierWritePolicy int
+
+const (
+	WriteThrough TierWritePolicy = iota // 同时写入 L1 和 L2，默认
+	WriteAround                         // 只写入 L2 并删除 L1 中的旧值，L1 只在读取命中 L2 时填充，适合写多读少的key
+)
+
+// TieredCache 两级缓存，实现了 Store 接口。
+// L1 通常是容量较小、速度更快的本地缓存，L2 是容量更大（可以是远程共享）的缓存，L2 被视为完整的数据集：
+// 读取时先查 L1，未命中再查 L2，命中 L2 的数据会按照 L2 中剩余的过期时间提升到 L1；写入和删除总是先作用于 L2。
+type TieredCache struct {
+	l1     Store
+	l2     Store
+	policy TierWritePolicy
+}
+
+// NewTieredCache 创建两级缓存，两级缓存的生命周期由 TieredCache 管理，Close 时会一起关闭
+func NewTieredCache(l1, l2 Store, policy TierWritePolicy) *TieredCache {
+	return &TieredCache{l1: l1, l2: l2, policy: policy}
+}
+
+// 根据写入策略同步 L1
+func (c *TieredCache) writeL1(key string, value Value, ttl time.Duration) {
+	if c.policy == WriteAround {
+		_ = c.l1.DeleteCache(key)
+		return
+	}
+	// L1 只是 L2 的副本，写入失败（例如超过 L1 的 MaxValueBytes）时删除旧值，保证不会读到过时的数据
+	if err := c.l1.AddWithTTL(key, value, ttl); err != nil {
+		_ = c.l1.DeleteCache(key)
+	}
+}
+
+// AddAndUpdateCache 使用 L2 的默认过期时间写入，L1 中的副本使用 L2 中实际的剩余过期时间
+func (c *TieredCache) AddAndUpdateCache(key string, value Value) error {
+	if err := c.l2.AddAndUpdateCache(key, value); err != nil {
+		return err
+	}
+	ttl, ok := c.l2.TTL(key)
+	if !ok {
+		_ = c.l1.DeleteCache(key)
+		return nil
+	}
+	c.writeL1(key, value, ttl)
+	return nil
+}
+
+func (c *TieredCache) AddWithTTL(key string, value Value, ttl time.Duration) error {
+	if err := c.l2.AddWithTTL(key, value, ttl); err != nil {
+		return err
+	}
+	// KeepTTL 时过期时间由 L2 决定，L1 使用 L2 中实际的剩余过期时间
+	if ttl == KeepTTL {
+		remaining, ok := c.l2.TTL(key)
+		if !ok {
+			_ = c.l1.DeleteCache(key)
+			return nil
+		}
+		ttl = remaining
+	}
+	c.writeL1(key, value, ttl)
+	return nil
+}
+
+func (c *TieredCache) AddIfAbsent(key string, value Value, ttl time.Duration) (bool, error) {
+	added, err := c.l2.AddIfAbsent(key, value, ttl)
+	if err != nil || !added {
+		return added, err
+	}
+	c.writeL1(key, value, ttl)
+	return true, nil
+}
+
+// DeleteCache 同时从两级缓存中删除，返回遇到的第一个错误
+func (c *TieredCache) DeleteCache(key string) error {
+	err := c.l2.DeleteCache(key)
+	if err1 := c.l1.DeleteCache(key); err == nil {
+		err = err1
+	}
+	return err
+}
+
+// FindCache 先查 L1，未命中时查 L2，命中 L2 的数据会提升到 L1
+func (c *TieredCache) FindCache(key string) (Value, bool) {
+	if value, ok := c.l1.FindCache(key); ok {
+		return value, true
+	}
+	value, ok := c.l2.FindCache(key)
+	if !ok {
+		return nil, false
+	}
+	if ttl, ok := c.l2.TTL(key); ok {
+		_ = c.l1.AddWithTTL(key, value, ttl)
+	}
+	return value, true
+}
+
+// Peek 与 FindCache 相同，但不会把数据提升到 L1，也不影响两级缓存的淘汰顺序
+func (c *TieredCache) Peek(key string) (Value, bool) {
+	if value, ok := c.l1.Peek(key); ok {
+		return value, true
+	}
+	return c.l2.Peek(key)
+}
+
+func (c *TieredCache) Contains(key string) bool {
+	return c.l1.Contains(key) || c.l2.Contains(key)
+}
+
+// TTL 以 L2 中的过期时间为准，L2 中已经不存在时使用 L1 中的过期时间
+func (c *TieredCache) TTL(key string) (time.Duration, bool) {
+	if ttl, ok := c.l2.TTL(key); ok {
+		return ttl, true
+	}
+	return c.l1.TTL(key)
+}
+
+// Range 先遍历 L2，再遍历只存在于 L1 中的key，每个key只会出现一次，f 返回 false 时停止遍历
+func (c *TieredCache) Range(f func(key string, value Value) bool) {
+	stopped := false
+	c.l2.Range(func(key string, value Value) bool {
+		if !f(key, value) {
+			stopped = true
+			return false
+		}
+		return true
+	})
+	if stopped {
+		return
+	}
+	c.l1.Range(func(key string, value Value) bool {
+		if c.l2.Contains(key) {
+			return true
+		}
+		return f(key, value)
+	})
+}
+
+// Len 返回两级缓存中key的并集的数量，与 Range 遍历到的key一致
+// 只存在于 L1 中的key（例如 L2 已经淘汰、L1 中的副本还没有过期）也会被计入，需要遍历 L1
+func (c *TieredCache) Len() int {
+	n := c.l2.Len()
+	c.l1.Range(func(key string, value Value) bool {
+		if !c.l2.Contains(key) {
+			n++
+		}
+		return true
+	})
+	return n
+}
+
+// Bytes 返回两级缓存实际占用的容量之和
+func (c *TieredCache) Bytes() int64 {
+	return c.l1.Bytes() + c.l2.Bytes()
+}
+
+func (c *TieredCache) Clear() {
+	c.l2.Clear()
+	c.l1.Clear()
+}
+
+// Resize 只修改 L2 的容量，L1 的容量需要直接在 L1 上修改
+func (c *TieredCache) Resize(maxBytes int64) int {
+	return c.l2.Resize(maxBytes)
+}
+
+func (c *TieredCache) Close() {
+	c.l1.Close()
+	c.l2.Close()
+}
@@ -0,0 +1,63 @@
+package lru
+
+import (
+	"testing"
+	"time"
+)
+
+// 读取命中 L2 时按照 L2 剩余的过期时间提升到 L1，删除同时作用于两级缓存
+func TestTieredPromote(t *testing.T) {
+	l1 := NewLruCache(&Options{DisableBackgroundCleanup: true})
+	l2 := NewLruCache(&Options{DisableBackgroundCleanup: true})
+	c := NewTieredCache(l1, l2, WriteThrough)
+	defer c.Close()
+	c.AddWithTTL("a", testValue("1"), time.Hour)
+	if !l1.Contains("a") || !l2.Contains("a") {
+		t.Fatal("WriteThrough 应该同时写入两级缓存")
+	}
+	l2.AddWithTTL("b", testValue("2"), time.Minute)
+	if v, ok := c.FindCache("b"); !ok || v != testValue("2") {
+		t.Fatalf("FindCache(b) = %v %v", v, ok)
+	}
+	if ttl, ok := l1.TTL("b"); !ok || ttl <= 0 || ttl > time.Minute {
+		t.Fatalf("提升到 L1 的 b 剩余过期时间为 %v", ttl)
+	}
+	c.DeleteCache("a")
+	if l1.Contains("a") || l2.Contains("a") {
+		t.Fatal("删除应该同时作用于两级缓存")
+	}
+}
+
+// Len 与 Range 一致，统计两级缓存中key的并集，只存在于 L1 中的key也会被计入
+func TestTieredLenMatchesRange(t *testing.T) {
+	l1 := NewLruCache(&Options{DisableBackgroundCleanup: true})
+	l2 := NewLruCache(&Options{DisableBackgroundCleanup: true})
+	c := NewTieredCache(l1, l2, WriteThrough)
+	defer c.Close()
+	c.AddAndUpdateCache("both", testValue("1"))
+	l2.AddAndUpdateCache("only2", testValue("2"))
+	l1.AddAndUpdateCache("only1", testValue("3"))
+	n := 0
+	c.Range(func(string, Value) bool { n++; return true })
+	if n != 3 || c.Len() != 3 {
+		t.Fatalf("Range 遍历到 %d 个，Len 为 %d，期望都为 3", n, c.Len())
+	}
+}
+
+// WriteAround 写入时只写 L2 并删除 L1 中的旧值，L1 只在读取时填充
+func TestTieredWriteAround(t *testing.T) {
+	c := NewTieredCache(NewLruCache(&Options{DisableBackgroundCleanup: true}), NewLruCache(&Options{DisableBackgroundCleanup: true}), WriteAround)
+	defer c.Close()
+	c.AddAndUpdateCache("k", testValue("1"))
+	if c.l1.Contains("k") {
+		t.Fatal("WriteAround 写入时不应该填充 L1")
+	}
+	c.FindCache("k")
+	if !c.l1.Contains("k") {
+		t.Fatal("读取命中 L2 之后应该填充 L1")
+	}
+	c.AddAndUpdateCache("k", testValue("2"))
+	if v, _ := c.FindCache("k"); v != testValue("2") {
+		t.Fatalf("更新之后读取到 %v", v)
+	}
+}
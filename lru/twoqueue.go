@@ -0,0 +1,476 @@
+package lru
+
+import (
+	"Distributed-Cache-Go/metrics"
+	"container/list"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+const (
+	// A1in 队列默认占总容量的比例
+	defaultRecentRatio = 0.25
+	// A1out 默认记录的已淘汰 key 相当于总容量的比例
+	defaultGhostRatio = 0.5
+)
+
+// TwoQueueCache 是一个 2Q 缓存实现，实现了 Store 接口。
+// 新数据先进入 A1in（FIFO），A1in 超出自己的份额时淘汰的 key 会记录在 A1out 中（只保存 key，不保存值），
+// 被淘汰后短时间内再次写入的 key 说明确实是热点数据，直接进入 Am（LRU）。
+// 这样一次性的顺序扫描只会冲刷 A1in，不会把 Am 中的热点数据挤出去。
+// 过期机制与 LruCache 保持一致。
+type TwoQueueCache struct {
+	// 1.核心功能：数据存储、容量控制、并发控制
+	recent        *list.List               // A1in，头部是最早进入的元素
+	frequent      *list.List               // Am，头部是最久未使用的元素
+	items         map[string]*list.Element // 键到 A1in 或 Am 中链表节点的映射
+	ghost         *list.List               // A1out，头部是最早被淘汰的 key
+	ghostItems    map[string]*list.Element // 键到 A1out 中链表节点的映射
+	maxBytes      int64                    // 最大容量
+	maxEntries    int64                    // 最大条目数，0 表示不限制
+	maxValueBytes int64                    // 单个键值对的最大大小，0 表示不限制
+	currentBytes  int64                    // 当前已经使用的容量
+	recentBytes   int64                    // A1in 已经使用的容量
+	ghostBytes    int64                    // A1out 中记录的 key 被淘汰前占用的容量
+	recentRatio   float64                  // A1in 占总容量的比例
+	ghostRatio    float64                  // A1out 相当于总容量的比例
+	mu            sync.RWMutex             // 读写锁
+	// 2.扩展功能：淘汰策略、过期机制
+	onEvicted  func(key string, value Value, reason EvictReason)
+	expires    map[string]time.Time
+	expiryHeap expiryHeap // 按过期时间排序的最小堆，清理时只需要查看已经过期的部分
+	defaultTTL time.Duration
+	ttlJitter  time.Duration
+	// 3.优化功能：后台清理协程、优雅关闭
+	cleanupInterval time.Duration
+	cleanTicker     *time.Ticker
+	closeChan       chan struct{}
+	closeOnce       sync.Once
+	wg              sync.WaitGroup // 后台清理协程，Close 等待它退出
+	// 日志输出
+	log *zap.Logger
+	// 时间来源，默认为系统时间
+	clk Clock
+	// 汇总输出淘汰日志
+	evictLog *evictionLog
+	// 自定义容量计算，为空时使用 len(key)+value.Len()
+	weigher func(key string, value Value) int64
+}
+
+// 内层条目结构体
+type TwoQueueEntry struct {
+	key      string
+	value    Value
+	frequent bool // 是否在 Am 中
+}
+
+// A1out 中的条目，只记录 key 和被淘汰前占用的容量
+type ghostEntry struct {
+	key  string
+	size int64
+}
+
+// 构造函数
+func NewTwoQueueCache(opt *Options) *TwoQueueCache {
+	withDefault(opt)
+	recentRatio := opt.TwoQueueRecentRatio
+	if recentRatio <= 0 || recentRatio >= 1 {
+		recentRatio = defaultRecentRatio
+	}
+	ghostRatio := opt.TwoQueueGhostRatio
+	if ghostRatio <= 0 {
+		ghostRatio = defaultGhostRatio
+	}
+	cache := &TwoQueueCache{
+		recent:          list.New(),
+		frequent:        list.New(),
+		items:           make(map[string]*list.Element),
+		ghost:           list.New(),
+		ghostItems:      make(map[string]*list.Element),
+		maxBytes:        opt.MaxBytes,
+		maxEntries:      opt.MaxEntries,
+		maxValueBytes:   opt.MaxValueBytes,
+		recentRatio:     recentRatio,
+		ghostRatio:      ghostRatio,
+		onEvicted:       opt.OnEvicted,
+		expires:         make(map[string]time.Time),
+		defaultTTL:      opt.DefaultTTL,
+		ttlJitter:       opt.TTLJitter,
+		cleanupInterval: opt.CleanupInterval,
+		closeChan:       make(chan struct{}),
+		log:             opt.Logger,
+		clk:             opt.Clock,
+		evictLog:        newEvictionLog(opt),
+		weigher:         opt.Weigher,
+	}
+	if !opt.DisableBackgroundCleanup {
+		cache.startCleanUpRoutine()
+	}
+	return cache
+}
+
+func (c *TwoQueueCache) startCleanUpRoutine() {
+	c.cleanTicker = time.NewTicker(c.cleanupInterval)
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		c.cleanupLoop()
+	}()
+}
+
+// 1.向缓存中新增/更新数据，过期时间使用默认的 defaultTTL
+func (c *TwoQueueCache) AddAndUpdateCache(key string, value Value) error {
+	return c.AddWithTTL(key, value, c.defaultTTL)
+}
+
+// AddWithTTL 向缓存中新增/更新数据，并为该key单独设置过期时间，ttl<=0 表示永不过期
+// 新的key进入 A1in，最近在 A1out 中出现过的key直接进入 Am
+func (c *TwoQueueCache) AddWithTTL(key string, value Value, ttl time.Duration) error {
+	if value == nil {
+		return nil
+	}
+	if err := checkValueSize(key, c.sizeOf(key, value), c.maxValueBytes); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.set(key, value, ttl)
+}
+
+// AddIfAbsent 只有key不存在或者已经过期时才写入，检查和写入在同一次加写锁中完成，返回是否写入
+func (c *TwoQueueCache) AddIfAbsent(key string, value Value, ttl time.Duration) (bool, error) {
+	if value == nil {
+		return false, nil
+	}
+	if err := checkValueSize(key, c.sizeOf(key, value), c.maxValueBytes); err != nil {
+		return false, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok {
+		if t, ok := c.expires[key]; !ok || !c.clk.Now().After(t) {
+			return false, nil
+		}
+		// 已经过期的旧数据先删除，再作为新的key写入
+		c.removeCache(elem, ReasonExpired)
+		metrics.Evictions.Inc()
+	}
+	if err := c.set(key, value, ttl); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// 新增/更新数据，调用此方法前必须持有锁
+func (c *TwoQueueCache) set(key string, value Value, ttl time.Duration) error {
+	if elem, ok := c.items[key]; ok {
+		entry := elem.Value.(*TwoQueueEntry)
+		if err := checkCapacity(key, c.sizeOf(key, value), c.maxBytes); err != nil {
+			return err
+		}
+		delta := c.sizeOf(key, value) - c.sizeOf(entry.key, entry.value)
+		c.currentBytes += delta
+		if entry.frequent {
+			c.frequent.MoveToBack(elem)
+		} else {
+			c.recentBytes += delta
+		}
+		metrics.Bytes.Add(float64(delta))
+		entry.value = value
+	} else {
+		// 新的key没有可以保留的过期时间，使用默认过期时间
+		if ttl == KeepTTL {
+			ttl = c.defaultTTL
+		}
+		size := c.sizeOf(key, value)
+		entry := &TwoQueueEntry{key: key, value: value}
+		if g, ok := c.ghostItems[key]; ok {
+			c.removeGhost(g)
+			entry.frequent = true
+			c.items[key] = c.frequent.PushBack(entry)
+		} else {
+			c.items[key] = c.recent.PushBack(entry)
+			c.recentBytes += size
+		}
+		c.currentBytes += size
+		metrics.Entries.Inc()
+		metrics.Bytes.Add(float64(size))
+	}
+	if ttl != KeepTTL {
+		c.createExpires(key, ttl)
+	}
+	c.evict()
+	return nil
+}
+
+// 创建元素的超时时间，ttl<=0 时不记录过期时间，即永不过期
+func (c *TwoQueueCache) createExpires(key string, ttl time.Duration) {
+	if ttl <= 0 {
+		delete(c.expires, key)
+		return
+	}
+	at := c.clk.Now().Add(jitterTTL(ttl, c.ttlJitter))
+	c.expires[key] = at
+	c.expiryHeap.push(c.expires, key, at)
+}
+
+// 2.根据key删除缓存中的数据，同时清除 A1out 中的记录
+func (c *TwoQueueCache) DeleteCache(key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok {
+		c.removeCache(elem, ReasonDeleted)
+	}
+	if g, ok := c.ghostItems[key]; ok {
+		c.removeGhost(g)
+	}
+	return nil
+}
+
+// 3.查询缓存中的数据，命中 Am 时移动到队尾，命中 A1in 时不改变位置
+func (c *TwoQueueCache) FindCache(key string) (Value, bool) {
+	// 访问可能会修改 Am 链表，所以直接使用写锁
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	if t, ok := c.expires[key]; ok && c.clk.Now().After(t) {
+		c.removeCache(elem, ReasonExpired)
+		metrics.Evictions.Inc()
+		return nil, false
+	}
+	entry := elem.Value.(*TwoQueueEntry)
+	if entry.frequent {
+		c.frequent.MoveToBack(elem)
+	}
+	return entry.value, true
+}
+
+// Peek 查询缓存中的数据，但不会影响淘汰顺序
+func (c *TwoQueueCache) Peek(key string) (Value, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	if t, ok := c.expires[key]; ok && c.clk.Now().After(t) {
+		return nil, false
+	}
+	return elem.Value.(*TwoQueueEntry).value, true
+}
+
+// Contains 判断key是否存在并且没有过期，不会影响淘汰顺序
+func (c *TwoQueueCache) Contains(key string) bool {
+	_, ok := c.Peek(key)
+	return ok
+}
+
+// TTL 返回key剩余的过期时间，0 表示永不过期，key不存在或者已经过期时返回 false，不会影响淘汰顺序
+func (c *TwoQueueCache) TTL(key string) (time.Duration, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if _, ok := c.items[key]; !ok {
+		return 0, false
+	}
+	t, ok := c.expires[key]
+	if !ok {
+		return 0, true
+	}
+	remaining := t.Sub(c.clk.Now())
+	if remaining <= 0 {
+		return 0, false
+	}
+	return remaining, true
+}
+
+// Range 遍历所有未过期的数据，f 返回 false 时停止遍历，先遍历 A1in 再遍历 Am
+// 遍历期间持有读锁，f 中不能再调用该缓存的任何方法，否则可能造成死锁
+func (c *TwoQueueCache) Range(f func(key string, value Value) bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	now := c.clk.Now()
+	for _, l := range []*list.List{c.recent, c.frequent} {
+		for elem := l.Front(); elem != nil; elem = elem.Next() {
+			entry := elem.Value.(*TwoQueueEntry)
+			if t, ok := c.expires[entry.key]; ok && now.After(t) {
+				continue
+			}
+			if !f(entry.key, entry.value) {
+				return
+			}
+		}
+	}
+}
+
+func (c *TwoQueueCache) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.items)
+}
+
+// Bytes 返回当前已经使用的容量，不包括 A1out
+func (c *TwoQueueCache) Bytes() int64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.currentBytes
+}
+
+// 删除缓存中的数据，调用此方法前必须持有锁
+func (c *TwoQueueCache) removeCache(elem *list.Element, reason EvictReason) {
+	entry := elem.Value.(*TwoQueueEntry)
+	size := c.sizeOf(entry.key, entry.value)
+	if entry.frequent {
+		c.frequent.Remove(elem)
+	} else {
+		c.recent.Remove(elem)
+		c.recentBytes -= size
+	}
+	delete(c.items, entry.key)
+	delete(c.expires, entry.key)
+	c.currentBytes -= size
+	metrics.Entries.Dec()
+	metrics.Bytes.Sub(float64(size))
+	if c.onEvicted != nil {
+		c.onEvicted(entry.key, entry.value, reason)
+	}
+}
+
+// 删除 A1out 中的记录，调用此方法前必须持有锁
+func (c *TwoQueueCache) removeGhost(elem *list.Element) {
+	g := elem.Value.(*ghostEntry)
+	c.ghost.Remove(elem)
+	delete(c.ghostItems, g.key)
+	c.ghostBytes -= g.size
+}
+
+// 将从 A1in 淘汰的key记录到 A1out 中，超出 A1out 的份额时丢弃最早的记录，调用此方法前必须持有锁
+func (c *TwoQueueCache) addGhost(key string, size int64) {
+	c.ghostItems[key] = c.ghost.PushBack(&ghostEntry{key: key, size: size})
+	c.ghostBytes += size
+	maxGhostBytes := int64(float64(c.maxBytes) * c.ghostRatio)
+	maxGhostEntries := int64(float64(c.maxEntries) * c.ghostRatio)
+	for c.ghost.Len() > 0 {
+		overBytes := c.maxBytes > 0 && c.ghostBytes > maxGhostBytes
+		overEntries := c.maxEntries > 0 && int64(c.ghost.Len()) > maxGhostEntries
+		if !overBytes && !overEntries {
+			return
+		}
+		c.removeGhost(c.ghost.Front())
+	}
+}
+
+// Clear 清空缓存中的所有数据以及 A1out 中的记录，每个被删除的元素都会触发 onEvicted 回调
+func (c *TwoQueueCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.onEvicted != nil {
+		for _, l := range []*list.List{c.recent, c.frequent} {
+			for elem := l.Front(); elem != nil; elem = elem.Next() {
+				entry := elem.Value.(*TwoQueueEntry)
+				c.onEvicted(entry.key, entry.value, ReasonCleared)
+			}
+		}
+	}
+	metrics.Entries.Sub(float64(len(c.items)))
+	metrics.Bytes.Sub(float64(c.currentBytes))
+	c.recent = list.New()
+	c.frequent = list.New()
+	c.items = make(map[string]*list.Element)
+	c.ghost = list.New()
+	c.ghostItems = make(map[string]*list.Element)
+	c.expires = make(map[string]time.Time)
+	c.expiryHeap = nil
+	c.currentBytes = 0
+	c.recentBytes = 0
+	c.ghostBytes = 0
+}
+
+// Resize 运行时修改最大容量，如果新的容量更小会立即淘汰数据，返回被淘汰的条目数
+func (c *TwoQueueCache) Resize(maxBytes int64) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.maxBytes = maxBytes
+	before := len(c.items)
+	c.evict()
+	return before - len(c.items)
+}
+
+// 定期清理缓存的方法
+func (c *TwoQueueCache) cleanupLoop() {
+	for {
+		select {
+		case <-c.cleanTicker.C:
+			c.mu.Lock()
+			count, bytes := c.evict()
+			c.mu.Unlock()
+			reportReclaimed(c.log, count, bytes)
+		case <-c.closeChan:
+			return
+		}
+	}
+}
+
+// evict 清理过期和超出容量限制的缓存，返回被清理的条目数和回收的字节数，调用此方法前必须持有锁
+// 容量不足时，A1in 超出自己的份额就从 A1in 头部淘汰并记录到 A1out，否则从 Am 头部淘汰
+func (c *TwoQueueCache) evict() (int, int64) {
+	before, beforeBytes := len(c.items), c.currentBytes
+	// 从过期堆中只取出已经过期的key，不需要遍历所有设置了过期时间的key
+	now := c.clk.Now()
+	for {
+		key, ok := c.expiryHeap.popExpired(c.expires, now)
+		if !ok {
+			break
+		}
+		if elem, ok := c.items[key]; ok {
+			c.removeCache(elem, ReasonExpired)
+			metrics.Evictions.Inc()
+		} else {
+			delete(c.expires, key)
+		}
+	}
+	maxRecentBytes := int64(float64(c.maxBytes) * c.recentRatio)
+	maxRecentEntries := int64(float64(c.maxEntries) * c.recentRatio)
+	for len(c.items) > 0 {
+		overBytes := c.maxBytes > 0 && c.currentBytes > c.maxBytes
+		overEntries := c.maxEntries > 0 && int64(len(c.items)) > c.maxEntries
+		if !overBytes && !overEntries {
+			break
+		}
+		recentOver := (c.maxBytes > 0 && c.recentBytes > maxRecentBytes) ||
+			(c.maxEntries > 0 && int64(c.recent.Len()) > maxRecentEntries)
+		if c.recent.Len() > 0 && (recentOver || c.frequent.Len() == 0) {
+			entry := c.recent.Front().Value.(*TwoQueueEntry)
+			size := c.sizeOf(entry.key, entry.value)
+			c.removeCache(c.recent.Front(), ReasonCapacity)
+			c.addGhost(entry.key, size)
+		} else {
+			c.removeCache(c.frequent.Front(), ReasonCapacity)
+		}
+		metrics.Evictions.Inc()
+	}
+	count, bytes := before-len(c.items), beforeBytes-c.currentBytes
+	c.evictLog.record(count, bytes)
+	return count, bytes
+}
+
+// Close 关闭缓存，停止清理协程
+func (c *TwoQueueCache) Close() {
+	// 使用 sync.Once 保证重复调用 Close 不会重复关闭 closeChan 导致 panic
+	c.closeOnce.Do(func() {
+		if c.cleanTicker != nil {
+			c.cleanTicker.Stop()
+		}
+		close(c.closeChan)
+		c.wg.Wait()
+		c.evictLog.flush()
+	})
+}
+
+// 键值对占用的容量
+func (c *TwoQueueCache) sizeOf(key string, value Value) int64 {
+	return entrySize(c.weigher, key, value)
+}
@@ -0,0 +1,56 @@
+package lru
+
+import (
+	"strconv"
+	"testing"
+)
+
+// 一次性的顺序扫描不会把 Am 中的热点数据挤出去，同样的访问模式下 LRU 中的热点数据会被全部淘汰
+func TestTwoQueueScanResistance(t *testing.T) {
+	tests := []struct {
+		ct   CacheType
+		want int // 扫描之后仍然存在的热点key的数量
+	}{
+		{TwoQueue, 20},
+		{LRU, 0},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.ct), func(t *testing.T) {
+			s := NewStore(tt.ct, &Options{MaxEntries: 100, MaxBytes: 1 << 20, DisableBackgroundCleanup: true})
+			defer s.Close()
+			// 热点key写入之后被挤出 A1in 并记录在 A1out 中，短时间内再次写入时进入 Am
+			for i := 0; i < 20; i++ {
+				s.AddAndUpdateCache("hot"+strconv.Itoa(i), testValue("v"))
+			}
+			for i := 0; i < 100; i++ {
+				s.AddAndUpdateCache("fill"+strconv.Itoa(i), testValue("v"))
+			}
+			for i := 0; i < 20; i++ {
+				s.AddAndUpdateCache("hot"+strconv.Itoa(i), testValue("v"))
+			}
+			for i := 0; i < 1000; i++ {
+				s.AddAndUpdateCache("scan"+strconv.Itoa(i), testValue("v"))
+			}
+			n := 0
+			for i := 0; i < 20; i++ {
+				if s.Contains("hot" + strconv.Itoa(i)) {
+					n++
+				}
+			}
+			if n != tt.want {
+				t.Fatalf("扫描之后剩余 %d 个热点key，期望 %d 个", n, tt.want)
+			}
+		})
+	}
+}
+
+// 删除之后 A1in 的容量统计被正确扣除
+func TestTwoQueueDeleteAccounting(t *testing.T) {
+	c := NewTwoQueueCache(&Options{MaxBytes: 100, DisableBackgroundCleanup: true})
+	defer c.Close()
+	c.AddAndUpdateCache("a", testValue("1"))
+	c.DeleteCache("a")
+	if c.Len() != 0 || c.Bytes() != 0 || c.recentBytes != 0 {
+		t.Fatalf("删除之后 Len=%d Bytes=%d recentBytes=%d", c.Len(), c.Bytes(), c.recentBytes)
+	}
+}
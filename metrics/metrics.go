@@ -0,0 +1,56 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// 指标名称的统一前缀
+const namespace = "distributed_cache"
+
+// 缓存相关的 Prometheus 指标，由 Cache 和 lru 包中的各个 Store 更新
+// 同一个进程中的多个缓存实例会累加到同一组指标上
+var (
+	Hits = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "hits_total",
+		Help:      "缓存命中次数",
+	})
+	Misses = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "misses_total",
+		Help:      "缓存未命中次数",
+	})
+	Evictions = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "evictions_total",
+		Help:      "因为过期或者容量不足被淘汰的条目数",
+	})
+	CleanupReclaimed = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cleanup_reclaimed_total",
+		Help:      "后台清理协程回收的条目数",
+	})
+	CleanupReclaimedBytes = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cleanup_reclaimed_bytes_total",
+		Help:      "后台清理协程回收的容量（字节）",
+	})
+	Bytes = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "bytes",
+		Help:      "当前已经使用的容量（字节）",
+	})
+	Entries = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "entries",
+		Help:      "当前缓存的条目数",
+	})
+)
+
+// RegisterMetrics 将所有指标注册到 reg 上，测试时可以传入自定义的 prometheus.Registry
+func RegisterMetrics(reg prometheus.Registerer) error {
+	for _, c := range []prometheus.Collector{Hits, Misses, Evictions, CleanupReclaimed, CleanupReclaimedBytes, Bytes, Entries} {
+		if err := reg.Register(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"Distributed-Cache-Go/metrics"
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"testing"
+)
+
+// 读取自定义 Registry 中的所有指标，返回指标名称到值的映射
+func gatherMetrics(t *testing.T, reg *prometheus.Registry) map[string]float64 {
+	t.Helper()
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := make(map[string]float64, len(families))
+	for _, f := range families {
+		for _, m := range f.GetMetric() {
+			switch {
+			case m.GetCounter() != nil:
+				values[f.GetName()] = m.GetCounter().GetValue()
+			case m.GetGauge() != nil:
+				values[f.GetName()] = m.GetGauge().GetValue()
+			}
+		}
+	}
+	return values
+}
+
+// 执行一组已知的操作之后，从自定义 Registry 中读取到的指标变化与操作一致
+func TestMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	if err := metrics.RegisterMetrics(reg); err != nil {
+		t.Fatal(err)
+	}
+	if err := metrics.RegisterMetrics(reg); err == nil {
+		t.Fatal("重复注册应该返回错误")
+	}
+	before := gatherMetrics(t, reg)
+
+	opt := DefaultCacheOptions()
+	opt.MaxEntries = 2
+	c := NewCache(&opt)
+	defer c.Close()
+	ctx := context.Background()
+	c.AddBytes("a", []byte("1"))
+	c.AddBytes("b", []byte("22"))
+	c.Get(ctx, "a")
+	c.Get(ctx, "a")
+	c.Get(ctx, "none")
+	c.AddBytes("c", []byte("3"))
+
+	after := gatherMetrics(t, reg)
+	tests := []struct {
+		name string
+		want float64
+	}{
+		{"distributed_cache_hits_total", 2},
+		{"distributed_cache_misses_total", 1},
+		{"distributed_cache_evictions_total", 1},
+		{"distributed_cache_entries", 2},
+		{"distributed_cache_bytes", 4},
+	}
+	for _, tt := range tests {
+		if got := after[tt.name] - before[tt.name]; got != tt.want {
+			t.Errorf("%s 变化了 %v，期望 %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,428 @@
+package msgpack
+
+import (
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+)
+
+// ErrShortData 数据不完整
+var ErrShortData = errors.New("msgpack: 数据不完整")
+
+// Unmarshal 将 MsgPack 格式的数据解码到 v 中，v 必须是非空指针
+// 解码到 interface{} 时，整数为 int64（超过 int64 范围的无符号整数为 uint64），浮点数为 float64，
+// 数组为 []interface{}，key 全部是字符串的 map 为 map[string]interface{}，其余 map 为 map[interface{}]interface{}
+func Unmarshal(data []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("msgpack: Unmarshal 需要非空指针，实际为 %T", v)
+	}
+	d := &decoder{b: data}
+	x, err := d.decode()
+	if err != nil {
+		return err
+	}
+	if d.off != len(d.b) {
+		return fmt.Errorf("msgpack: 末尾有 %d 字节多余的数据", len(d.b)-d.off)
+	}
+	return assign(rv.Elem(), x)
+}
+
+type decoder struct {
+	b   []byte
+	off int
+}
+
+func (d *decoder) next(n int) ([]byte, error) {
+	if n < 0 || len(d.b)-d.off < n {
+		return nil, ErrShortData
+	}
+	b := d.b[d.off : d.off+n]
+	d.off += n
+	return b, nil
+}
+
+// 读取 n 字节的大端无符号整数，n 只能是 1、2、4、8
+func (d *decoder) uint(n int) (uint64, error) {
+	b, err := d.next(n)
+	if err != nil {
+		return 0, err
+	}
+	switch n {
+	case 1:
+		return uint64(b[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(b)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(b)), nil
+	default:
+		return binary.BigEndian.Uint64(b), nil
+	}
+}
+
+// decode 把下一个值解码为通用的 Go 类型
+func (d *decoder) decode() (interface{}, error) {
+	b, err := d.next(1)
+	if err != nil {
+		return nil, err
+	}
+	c := b[0]
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c&0xf0 == codeFixMap:
+		return d.decodeMap(int(c & 0x0f))
+	case c&0xf0 == codeFixArray:
+		return d.decodeArray(int(c & 0x0f))
+	case c&0xe0 == codeFixStr:
+		s, err := d.next(int(c & 0x1f))
+		return string(s), err
+	}
+	switch c {
+	case codeNil:
+		return nil, nil
+	case codeFalse:
+		return false, nil
+	case codeTrue:
+		return true, nil
+	case codeUint8, codeUint16, codeUint32, codeUint64:
+		n, err := d.uint(1 << (c - codeUint8))
+		if err != nil {
+			return nil, err
+		}
+		if n > math.MaxInt64 {
+			return n, nil
+		}
+		return int64(n), nil
+	case codeInt8:
+		n, err := d.uint(1)
+		return int64(int8(n)), err
+	case codeInt16:
+		n, err := d.uint(2)
+		return int64(int16(n)), err
+	case codeInt32:
+		n, err := d.uint(4)
+		return int64(int32(n)), err
+	case codeInt64:
+		n, err := d.uint(8)
+		return int64(n), err
+	case codeFloat32:
+		n, err := d.uint(4)
+		return float64(math.Float32frombits(uint32(n))), err
+	case codeFloat64:
+		n, err := d.uint(8)
+		return math.Float64frombits(n), err
+	case codeStr8, codeStr16, codeStr32:
+		s, err := d.bytes(1 << (c - codeStr8))
+		return string(s), err
+	case codeBin8, codeBin16, codeBin32:
+		s, err := d.bytes(1 << (c - codeBin8))
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte(nil), s...), nil
+	case codeArray16, codeArray32:
+		n, err := d.uint(2 << (c - codeArray16))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeArray(int(n))
+	case codeMap16, codeMap32:
+		n, err := d.uint(2 << (c - codeMap16))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeMap(int(n))
+	case codeFixExt4, codeFixExt8:
+		return d.decodeExt(4 << (c - codeFixExt4))
+	case codeExt8:
+		n, err := d.uint(1)
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeExt(int(n))
+	}
+	return nil, fmt.Errorf("msgpack: 不支持的类型标记 0x%02x", c)
+}
+
+// 读取长度为 lenSize 字节的长度前缀以及对应的数据
+func (d *decoder) bytes(lenSize int) ([]byte, error) {
+	n, err := d.uint(lenSize)
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(len(d.b)-d.off) {
+		return nil, ErrShortData
+	}
+	return d.next(int(n))
+}
+
+// 解码长度为 n 的扩展类型，目前只支持时间戳
+func (d *decoder) decodeExt(n int) (interface{}, error) {
+	b, err := d.next(1)
+	if err != nil {
+		return nil, err
+	}
+	if b[0] != extTimestamp {
+		return nil, fmt.Errorf("msgpack: 不支持的扩展类型 %d", int8(b[0]))
+	}
+	data, err := d.next(n)
+	if err != nil {
+		return nil, err
+	}
+	switch n {
+	case 4:
+		return time.Unix(int64(binary.BigEndian.Uint32(data)), 0), nil
+	case 8:
+		x := binary.BigEndian.Uint64(data)
+		return time.Unix(int64(x&(1<<34-1)), int64(x>>34)), nil
+	case 12:
+		return time.Unix(int64(binary.BigEndian.Uint64(data[4:])), int64(binary.BigEndian.Uint32(data))), nil
+	}
+	return nil, fmt.Errorf("msgpack: 时间戳的长度 %d 错误", n)
+}
+
+func (d *decoder) decodeArray(n int) (interface{}, error) {
+	// 每个元素至少占 1 字节，长度明显超过剩余数据时直接报错，避免分配过大的切片
+	if n > len(d.b)-d.off {
+		return nil, ErrShortData
+	}
+	arr := make([]interface{}, n)
+	for i := range arr {
+		x, err := d.decode()
+		if err != nil {
+			return nil, err
+		}
+		arr[i] = x
+	}
+	return arr, nil
+}
+
+func (d *decoder) decodeMap(n int) (interface{}, error) {
+	if 2*n > len(d.b)-d.off {
+		return nil, ErrShortData
+	}
+	keys := make([]interface{}, n)
+	values := make([]interface{}, n)
+	allString := true
+	for i := 0; i < n; i++ {
+		k, err := d.decode()
+		if err != nil {
+			return nil, err
+		}
+		v, err := d.decode()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := k.(string); !ok {
+			allString = false
+		}
+		keys[i], values[i] = k, v
+	}
+	if allString {
+		m := make(map[string]interface{}, n)
+		for i, k := range keys {
+			m[k.(string)] = values[i]
+		}
+		return m, nil
+	}
+	m := make(map[interface{}]interface{}, n)
+	for i, k := range keys {
+		if k != nil && !reflect.TypeOf(k).Comparable() {
+			return nil, fmt.Errorf("msgpack: map 的 key 类型 %T 不可比较", k)
+		}
+		m[k] = values[i]
+	}
+	return m, nil
+}
+
+// assign 把 decode 得到的通用值赋给 v
+func assign(v reflect.Value, x interface{}) error {
+	if x == nil {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return assign(v.Elem(), x)
+	}
+	if v.Type() == timeType {
+		t, ok := x.(time.Time)
+		if !ok {
+			return mismatch(x, v)
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	}
+	if ok, err := assignUnmarshaler(v, x); ok {
+		return err
+	}
+	switch v.Kind() {
+	case reflect.Interface:
+		xv := reflect.ValueOf(x)
+		if !xv.Type().AssignableTo(v.Type()) {
+			return mismatch(x, v)
+		}
+		v.Set(xv)
+	case reflect.Bool:
+		b, ok := x.(bool)
+		if !ok {
+			return mismatch(x, v)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := x.(int64)
+		if !ok || v.OverflowInt(n) {
+			return mismatch(x, v)
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		var n uint64
+		switch t := x.(type) {
+		case int64:
+			if t < 0 {
+				return mismatch(x, v)
+			}
+			n = uint64(t)
+		case uint64:
+			n = t
+		default:
+			return mismatch(x, v)
+		}
+		if v.OverflowUint(n) {
+			return mismatch(x, v)
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		switch t := x.(type) {
+		case float64:
+			v.SetFloat(t)
+		case int64:
+			v.SetFloat(float64(t))
+		case uint64:
+			v.SetFloat(float64(t))
+		default:
+			return mismatch(x, v)
+		}
+	case reflect.String:
+		switch t := x.(type) {
+		case string:
+			v.SetString(t)
+		case []byte:
+			v.SetString(string(t))
+		default:
+			return mismatch(x, v)
+		}
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			switch t := x.(type) {
+			case []byte:
+				v.SetBytes(t)
+				return nil
+			case string:
+				v.SetBytes([]byte(t))
+				return nil
+			}
+		}
+		arr, ok := x.([]interface{})
+		if !ok {
+			return mismatch(x, v)
+		}
+		s := reflect.MakeSlice(v.Type(), len(arr), len(arr))
+		for i, e := range arr {
+			if err := assign(s.Index(i), e); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+	case reflect.Array:
+		arr, ok := x.([]interface{})
+		if !ok || len(arr) > v.Len() {
+			return mismatch(x, v)
+		}
+		v.Set(reflect.Zero(v.Type()))
+		for i, e := range arr {
+			if err := assign(v.Index(i), e); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		xv := reflect.ValueOf(x)
+		if xv.Kind() != reflect.Map {
+			return mismatch(x, v)
+		}
+		m := reflect.MakeMapWithSize(v.Type(), xv.Len())
+		iter := xv.MapRange()
+		for iter.Next() {
+			key := reflect.New(v.Type().Key()).Elem()
+			if err := assign(key, iter.Key().Interface()); err != nil {
+				return err
+			}
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := assign(elem, iter.Value().Interface()); err != nil {
+				return err
+			}
+			m.SetMapIndex(key, elem)
+		}
+		v.Set(m)
+	case reflect.Struct:
+		m, ok := x.(map[string]interface{})
+		if !ok {
+			return mismatch(x, v)
+		}
+		// 数据中没有的字段保持零值，结构体中没有的 key 直接忽略
+		v.Set(reflect.Zero(v.Type()))
+		for _, f := range structFields(v.Type()) {
+			if e, ok := m[f.name]; ok {
+				if err := assign(v.Field(f.index), e); err != nil {
+					return err
+				}
+			}
+		}
+	default:
+		return fmt.Errorf("msgpack: 不支持解码到类型 %s", v.Type())
+	}
+	return nil
+}
+
+// 如果 v 的指针实现了 encoding.BinaryUnmarshaler 或者 encoding.TextUnmarshaler，通过它解码并返回 true
+func assignUnmarshaler(v reflect.Value, x interface{}) (bool, error) {
+	if !v.CanAddr() {
+		return false, nil
+	}
+	p := v.Addr()
+	if u, ok := p.Interface().(encoding.BinaryUnmarshaler); ok {
+		b, ok := x.([]byte)
+		if !ok {
+			return true, mismatch(x, v)
+		}
+		if err := u.UnmarshalBinary(b); err != nil {
+			return true, fmt.Errorf("msgpack: 解码 %s 失败:%v", v.Type(), err)
+		}
+		return true, nil
+	}
+	if u, ok := p.Interface().(encoding.TextUnmarshaler); ok {
+		s, ok := x.(string)
+		if !ok {
+			return true, mismatch(x, v)
+		}
+		if err := u.UnmarshalText([]byte(s)); err != nil {
+			return true, fmt.Errorf("msgpack: 解码 %s 失败:%v", v.Type(), err)
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+func mismatch(x interface{}, v reflect.Value) error {
+	return fmt.Errorf("msgpack: 不能把 %T 解码到类型 %s", x, v.Type())
+}
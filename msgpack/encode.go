@@ -0,0 +1,252 @@
+package msgpack
+
+import (
+	"encoding"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+)
+
+// Marshal 将 v 编码为 MsgPack 格式
+// 支持 bool、整数、浮点数、字符串、[]byte、切片、数组、map、结构体、指针和 interface，
+// 结构体按导出字段编码为 map，字段名可以通过 `msgpack:"name"` 标签修改，标签为 "-" 的字段会被忽略
+// time.Time 编码为时间戳扩展类型，实现了 encoding.BinaryMarshaler 或者 encoding.TextMarshaler 的类型通过它们编码，
+// 既没有导出字段也没有实现这两个接口的结构体会返回错误，避免数据被静默丢弃
+func Marshal(v interface{}) ([]byte, error) {
+	e := &encoder{}
+	if err := e.encode(reflect.ValueOf(v)); err != nil {
+		return nil, err
+	}
+	return e.buf, nil
+}
+
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) encode(v reflect.Value) error {
+	if !v.IsValid() {
+		e.buf = append(e.buf, codeNil)
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			e.buf = append(e.buf, codeNil)
+			return nil
+		}
+		return e.encode(v.Elem())
+	}
+	if v.Type() == timeType {
+		e.encodeTime(v.Interface().(time.Time))
+		return nil
+	}
+	if ok, err := e.encodeMarshaler(v); ok {
+		return err
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			e.buf = append(e.buf, codeTrue)
+		} else {
+			e.buf = append(e.buf, codeFalse)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.encodeInt(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		e.encodeUint(v.Uint())
+	case reflect.Float32:
+		e.buf = append(e.buf, codeFloat32)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, math.Float32bits(float32(v.Float())))
+	case reflect.Float64:
+		e.buf = append(e.buf, codeFloat64)
+		e.buf = binary.BigEndian.AppendUint64(e.buf, math.Float64bits(v.Float()))
+	case reflect.String:
+		e.encodeString(v.String())
+	case reflect.Slice:
+		if v.IsNil() {
+			e.buf = append(e.buf, codeNil)
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			e.encodeBytes(v.Bytes())
+			return nil
+		}
+		return e.encodeArray(v)
+	case reflect.Array:
+		return e.encodeArray(v)
+	case reflect.Map:
+		if v.IsNil() {
+			e.buf = append(e.buf, codeNil)
+			return nil
+		}
+		e.encodeLen(v.Len(), codeFixMap, 0x0f, codeMap16, codeMap32)
+		iter := v.MapRange()
+		for iter.Next() {
+			if err := e.encode(iter.Key()); err != nil {
+				return err
+			}
+			if err := e.encode(iter.Value()); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		fields := structFields(v.Type())
+		if len(fields) == 0 && hasUnexported(v.Type()) {
+			return fmt.Errorf("msgpack: 结构体 %s 没有可以编码的导出字段", v.Type())
+		}
+		e.encodeLen(len(fields), codeFixMap, 0x0f, codeMap16, codeMap32)
+		for _, f := range fields {
+			e.encodeString(f.name)
+			if err := e.encode(v.Field(f.index)); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("msgpack: 不支持编码的类型 %s", v.Type())
+	}
+	return nil
+}
+
+func (e *encoder) encodeInt(n int64) {
+	if n >= 0 {
+		e.encodeUint(uint64(n))
+		return
+	}
+	switch {
+	case n >= -32:
+		e.buf = append(e.buf, byte(int8(n)))
+	case n >= math.MinInt8:
+		e.buf = append(e.buf, codeInt8, byte(int8(n)))
+	case n >= math.MinInt16:
+		e.buf = append(e.buf, codeInt16)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(n))
+	case n >= math.MinInt32:
+		e.buf = append(e.buf, codeInt32)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(n))
+	default:
+		e.buf = append(e.buf, codeInt64)
+		e.buf = binary.BigEndian.AppendUint64(e.buf, uint64(n))
+	}
+}
+
+func (e *encoder) encodeUint(n uint64) {
+	switch {
+	case n <= 0x7f:
+		e.buf = append(e.buf, byte(n))
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, codeUint8, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, codeUint16)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(n))
+	case n <= math.MaxUint32:
+		e.buf = append(e.buf, codeUint32)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(n))
+	default:
+		e.buf = append(e.buf, codeUint64)
+		e.buf = binary.BigEndian.AppendUint64(e.buf, n)
+	}
+}
+
+func (e *encoder) encodeString(s string) {
+	n := len(s)
+	switch {
+	case n <= 0x1f:
+		e.buf = append(e.buf, codeFixStr|byte(n))
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, codeStr8, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, codeStr16)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(n))
+	default:
+		e.buf = append(e.buf, codeStr32)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(n))
+	}
+	e.buf = append(e.buf, s...)
+}
+
+func (e *encoder) encodeBytes(b []byte) {
+	n := len(b)
+	switch {
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, codeBin8, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, codeBin16)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(n))
+	default:
+		e.buf = append(e.buf, codeBin32)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(n))
+	}
+	e.buf = append(e.buf, b...)
+}
+
+func (e *encoder) encodeArray(v reflect.Value) error {
+	e.encodeLen(v.Len(), codeFixArray, 0x0f, codeArray16, codeArray32)
+	for i := 0; i < v.Len(); i++ {
+		if err := e.encode(v.Index(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 写入数组或者 map 的长度，长度不超过 fixMax 时使用 fix 格式
+func (e *encoder) encodeLen(n int, fix byte, fixMax int, code16, code32 byte) {
+	switch {
+	case n <= fixMax:
+		e.buf = append(e.buf, fix|byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, code16)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(n))
+	default:
+		e.buf = append(e.buf, code32)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(n))
+	}
+}
+
+// 如果 v（或者 v 的指针）实现了 encoding.BinaryMarshaler 或者 encoding.TextMarshaler，通过它编码并返回 true
+func (e *encoder) encodeMarshaler(v reflect.Value) (bool, error) {
+	t := v.Type()
+	if !t.Implements(binaryMarshalerType) && !t.Implements(textMarshalerType) {
+		if !reflect.PointerTo(t).Implements(binaryMarshalerType) && !reflect.PointerTo(t).Implements(textMarshalerType) {
+			return false, nil
+		}
+		// 方法定义在指针上时，复制一份可以取地址的值
+		p := reflect.New(t)
+		p.Elem().Set(v)
+		v = p
+	}
+	if m, ok := v.Interface().(encoding.BinaryMarshaler); ok {
+		b, err := m.MarshalBinary()
+		if err != nil {
+			return true, fmt.Errorf("msgpack: 编码 %s 失败:%v", t, err)
+		}
+		e.encodeBytes(b)
+		return true, nil
+	}
+	b, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+	if err != nil {
+		return true, fmt.Errorf("msgpack: 编码 %s 失败:%v", t, err)
+	}
+	e.encodeString(string(b))
+	return true, nil
+}
+
+// 按照 MsgPack 规范的时间戳扩展类型编码，根据取值范围选择 32、64 或者 96 位格式
+func (e *encoder) encodeTime(t time.Time) {
+	sec, nsec := t.Unix(), int64(t.Nanosecond())
+	switch {
+	case sec>>34 == 0 && nsec == 0 && sec <= math.MaxUint32:
+		e.buf = append(e.buf, codeFixExt4, extTimestamp)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(sec))
+	case sec>>34 == 0:
+		e.buf = append(e.buf, codeFixExt8, extTimestamp)
+		e.buf = binary.BigEndian.AppendUint64(e.buf, uint64(nsec)<<34|uint64(sec))
+	default:
+		e.buf = append(e.buf, codeExt8, 12, extTimestamp)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(nsec))
+		e.buf = binary.BigEndian.AppendUint64(e.buf, uint64(sec))
+	}
+}
@@ -0,0 +1,99 @@
+// Package msgpack 是 MsgPack 格式的一个精简实现，只依赖标准库
+// 只覆盖缓存值序列化需要的基本类型，扩展类型（ext）只支持时间戳（类型 -1），对应 time.Time
+// 实现了 encoding.BinaryMarshaler 或者 encoding.TextMarshaler 的类型分别编码为 bin 和 str
+package msgpack
+
+import (
+	"encoding"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MsgPack 格式的类型标记
+const (
+	codeNil      byte = 0xc0
+	codeFalse    byte = 0xc2
+	codeTrue     byte = 0xc3
+	codeBin8     byte = 0xc4
+	codeBin16    byte = 0xc5
+	codeBin32    byte = 0xc6
+	codeFloat32  byte = 0xca
+	codeFloat64  byte = 0xcb
+	codeUint8    byte = 0xcc
+	codeUint16   byte = 0xcd
+	codeUint32   byte = 0xce
+	codeUint64   byte = 0xcf
+	codeInt8     byte = 0xd0
+	codeInt16    byte = 0xd1
+	codeInt32    byte = 0xd2
+	codeInt64    byte = 0xd3
+	codeStr8     byte = 0xd9
+	codeStr16    byte = 0xda
+	codeStr32    byte = 0xdb
+	codeArray16  byte = 0xdc
+	codeArray32  byte = 0xdd
+	codeMap16    byte = 0xde
+	codeMap32    byte = 0xdf
+	codeFixMap   byte = 0x80
+	codeFixArray byte = 0x90
+	codeFixStr   byte = 0xa0
+	codeFixExt4  byte = 0xd6
+	codeFixExt8  byte = 0xd7
+	codeExt8     byte = 0xc7
+)
+
+// 时间戳扩展类型，规范中的类型为 -1
+const extTimestamp byte = 0xff
+
+var (
+	timeType            = reflect.TypeOf(time.Time{})
+	binaryMarshalerType = reflect.TypeOf((*encoding.BinaryMarshaler)(nil)).Elem()
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// 结构体中参与编码的字段
+type field struct {
+	name  string
+	index int
+}
+
+// 缓存每个结构体类型的字段列表，避免每次编码都重新解析标签
+var fieldCache sync.Map // map[reflect.Type][]field
+
+func structFields(t reflect.Type) []field {
+	if fields, ok := fieldCache.Load(t); ok {
+		return fields.([]field)
+	}
+	var fields []field
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		name := f.Name
+		if tag := f.Tag.Get("msgpack"); tag != "" {
+			tag, _, _ = strings.Cut(tag, ",")
+			if tag == "-" {
+				continue
+			}
+			if tag != "" {
+				name = tag
+			}
+		}
+		fields = append(fields, field{name: name, index: i})
+	}
+	fieldCache.Store(t, fields)
+	return fields
+}
+
+// 结构体是否有未导出的字段，所有字段都未导出的结构体（例如 time.Time）按字段编码会丢失全部数据
+func hasUnexported(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).PkgPath != "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,143 @@
+package msgpack
+
+import (
+	"math"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type inner struct {
+	A []int
+	B map[string]float64
+}
+
+type outer struct {
+	Name   string `msgpack:"name"`
+	Skip   int    `msgpack:"-"`
+	I8     int8
+	I      int64
+	U      uint64
+	F32    float32
+	Bytes  []byte
+	Ptr    *inner
+	Arr    [3]string
+	Any    interface{}
+	Long   string
+	Nested []inner
+	hidden int
+}
+
+func TestRoundTrip(t *testing.T) {
+	in := outer{Name: "x", Skip: 5, I8: -100, I: math.MinInt64, U: math.MaxUint64, F32: 1.5,
+		Bytes: []byte{1, 2}, Ptr: &inner{A: []int{1, -1, 300, -40000}, B: map[string]float64{"pi": 3.14}},
+		Arr: [3]string{"a", "b"}, Any: map[string]interface{}{"k": []interface{}{int64(1), "s", true, nil}},
+		Long: strings.Repeat("z", 70000), Nested: []inner{{A: []int{}}}}
+	b, err := Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out outer
+	if err := Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	// 标签为 "-" 的字段不参与编码
+	in.Skip = 0
+	if !reflect.DeepEqual(in, out) {
+		t.Fatalf("解码结果不一致\n%+v\n%+v", in, out)
+	}
+	if err := Unmarshal(b[:len(b)-1], &out); err == nil {
+		t.Fatal("数据不完整时应该返回错误")
+	}
+}
+
+func TestOverflow(t *testing.T) {
+	b, err := Marshal(1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var i8 int8
+	if err := Unmarshal(b, &i8); err == nil {
+		t.Fatal("超出 int8 范围时应该返回错误")
+	}
+}
+
+func TestKnownEncoding(t *testing.T) {
+	b, err := Marshal(map[string]int{"a": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{0x81, 0xa1, 'a', 0x01}; !reflect.DeepEqual(b, want) {
+		t.Fatalf("编码结果为 %x，期望 %x", b, want)
+	}
+}
+
+func TestTime(t *testing.T) {
+	type event struct {
+		Name string
+		At   time.Time
+	}
+	times := []time.Time{
+		{},
+		time.Unix(1700000000, 0),
+		time.Unix(1700000000, 123456789),
+		time.Unix(1<<35, 1),
+		time.Unix(-1, 5),
+	}
+	for _, at := range times {
+		b, err := Marshal(event{Name: "x", At: at})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out event
+		if err := Unmarshal(b, &out); err != nil {
+			t.Fatal(err)
+		}
+		if out.Name != "x" || !out.At.Equal(at) {
+			t.Fatalf("时间 %v 解码为 %v", at, out.At)
+		}
+	}
+	// 规范中的 32 位时间戳格式
+	b, err := Marshal(time.Unix(1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{0xd6, 0xff, 0, 0, 0, 1}; !reflect.DeepEqual(b, want) {
+		t.Fatalf("编码结果为 %x，期望 %x", b, want)
+	}
+	var x interface{}
+	if err := Unmarshal(b, &x); err != nil {
+		t.Fatal(err)
+	}
+	if at, ok := x.(time.Time); !ok || at.Unix() != 1 {
+		t.Fatalf("解码到 interface{} 的结果为 %#v", x)
+	}
+}
+
+func TestTextMarshaler(t *testing.T) {
+	in := struct{ IP net.IP }{IP: net.ParseIP("10.0.0.1")}
+	b, err := Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out struct{ IP net.IP }
+	if err := Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !out.IP.Equal(in.IP) {
+		t.Fatalf("解码结果为 %v", out.IP)
+	}
+}
+
+// 没有导出字段的结构体不能静默编码为空 map
+func TestUnexportedStruct(t *testing.T) {
+	type opaque struct{ n int }
+	if _, err := Marshal(struct{ V opaque }{V: opaque{n: 1}}); err == nil {
+		t.Fatal("没有导出字段的结构体应该返回错误")
+	}
+	if _, err := Marshal(struct{}{}); err != nil {
+		t.Fatalf("空结构体应该可以编码: %v", err)
+	}
+}
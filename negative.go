@@ -0,0 +1,41 @@
+package main
+
+import (
+	"Distributed-Cache-Go/lru"
+	"errors"
+	"go.uber.org/zap"
+	"sync/atomic"
+)
+
+// ErrNotFound 数据源中不存在该key，loader/Getter 返回该错误（可以被包装）时，
+// 配置了 CacheOptions.NegativeTTL 的缓存会记住这次未命中，NegativeTTL 之内的查找直接返回 ErrNotFound 而不再调用 loader
+var ErrNotFound = errors.New("数据源中不存在该key")
+
+// tombstone 负缓存的标记，存放在底层 Store 中表示该key在数据源中不存在，不会被持久化也不会被导出
+type tombstone struct{}
+
+func (tombstone) Len() int {
+	return 0
+}
+
+// 判断底层 Store 中的值是否是负缓存的标记
+func isTombstone(value lru.Value) bool {
+	_, ok := value.(tombstone)
+	return ok
+}
+
+// addNegative 为不存在的key写入一个过期时间为 NegativeTTL 的标记，没有配置 NegativeTTL 时不做任何事
+func (c *Cache) addNegative(key string) {
+	ttl := c.cacheOptions.NegativeTTL
+	if ttl <= 0 || !c.beginWrite() {
+		return
+	}
+	defer c.endWrite()
+	if atomic.LoadInt32(&c.closed) == 1 {
+		return
+	}
+	c.ensureInitialized()
+	if err := c.store.AddWithTTL(key, tombstone{}, ttl); err != nil {
+		c.log.Error("写入负缓存失败", zap.String("key", key), zap.Error(err))
+	}
+}
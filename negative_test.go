@@ -0,0 +1,89 @@
+package main
+
+import (
+	"Distributed-Cache-Go/lru"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// 负缓存比正常的值先过期，过期之前不会调用 loader，过期之后重新加载并按 DefaultTTL 缓存加载到的值
+func TestNegativeCacheExpires(t *testing.T) {
+	clock := lru.NewFakeClock(time.Unix(0, 0))
+	opt := DefaultCacheOptions()
+	opt.Clock = clock
+	opt.DefaultTTL = time.Hour
+	opt.NegativeTTL = time.Second
+	c := NewCache(&opt)
+	defer c.Close()
+	ctx := context.Background()
+	calls := 0
+	exists := false
+	loader := func(ctx context.Context, key string) ([]byte, error) {
+		calls++
+		if !exists {
+			return nil, fmt.Errorf("查询数据库: %w", ErrNotFound)
+		}
+		return []byte("v"), nil
+	}
+
+	tests := []struct {
+		name      string
+		advance   time.Duration
+		exists    bool
+		wantErr   error
+		wantCalls int
+	}{
+		{"第一次加载未命中", 0, false, ErrNotFound, 1},
+		{"负缓存有效期内不调用 loader", 500 * time.Millisecond, true, ErrNotFound, 1},
+		{"负缓存过期之后重新加载", time.Second, true, nil, 2},
+		{"加载到的值按 DefaultTTL 缓存", 10 * time.Minute, true, nil, 2},
+	}
+	for _, tt := range tests {
+		clock.Advance(tt.advance)
+		exists = tt.exists
+		v, err := c.GetOrLoad(ctx, "k", loader)
+		if !errors.Is(err, tt.wantErr) || calls != tt.wantCalls {
+			t.Fatalf("%s: GetOrLoad 返回 %v，loader 调用了 %d 次", tt.name, err, calls)
+		}
+		if err == nil && v.String() != "v" {
+			t.Fatalf("%s: 加载到的值为 %q", tt.name, v.String())
+		}
+	}
+}
+
+// Increment 把负缓存当作不存在的key从 0 开始计数，GetMulti 不返回负缓存并记为未命中
+func TestNegativeCacheSkippedByReaders(t *testing.T) {
+	opt := DefaultCacheOptions()
+	opt.NegativeTTL = time.Hour
+	c := NewCache(&opt)
+	defer c.Close()
+	ctx := context.Background()
+	notFound := func(ctx context.Context, key string) ([]byte, error) {
+		return nil, ErrNotFound
+	}
+	for _, key := range []string{"n", "m"} {
+		if _, err := c.GetOrLoad(ctx, key, notFound); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("%s 应该返回 ErrNotFound，实际为 %v", key, err)
+		}
+	}
+	c.AddBytes("a", []byte("1"))
+	c.ResetStats()
+
+	got, err := c.GetMulti(ctx, []string{"a", "n"})
+	if err != nil || len(got) != 1 || got["a"].String() != "1" {
+		t.Fatalf("GetMulti 的结果为 %v %v", got, err)
+	}
+	if stats := c.Stats(); stats.Hits != 1 || stats.Misses != 1 {
+		t.Fatalf("负缓存应该记为未命中: %+v", stats)
+	}
+
+	if n, err := c.Increment("m", 5); err != nil || n != 5 {
+		t.Fatalf("Increment 返回 %d %v，期望从 0 开始计数", n, err)
+	}
+	if v, ok := c.Get(ctx, "m"); !ok || v.String() != "5" {
+		t.Fatalf("Increment 之后 m 的值为 %q %v", v.String(), ok)
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"errors"
+)
+
+// PeerPicker 根据 key 选择负责该 key 的远程节点
+// 如果 key 由当前节点负责（或者没有任何远程节点），ok 返回 false
+type PeerPicker interface {
+	PickPeer(key string) (peer PeerGetter, ok bool)
+}
+
+// PeerGetter 从远程节点获取缓存数据的接口，HTTP 和 gRPC 客户端都需要实现该接口
+type PeerGetter interface {
+	Get(ctx context.Context, group string, key string) ([]byte, error)
+}
+
+// PeerSetter 向远程节点写入缓存数据的接口，开启副本时用于把写入同步到副本节点
+type PeerSetter interface {
+	Set(ctx context.Context, group string, key string, value []byte) error
+}
+
+// ReplicaPicker 支持副本的 PeerPicker，CacheOptions.ReplicationFactor 大于 1 时使用
+type ReplicaPicker interface {
+	PeerPicker
+	// PickReplicas 按哈希环顺序返回负责 key 的前 n 个节点中的远程节点，第一个是主节点（当前节点除外）
+	// self 表示当前节点是否也是其中之一
+	PickReplicas(key string, n int) (peers []PeerGetter, self bool)
+}
+
+// ErrPeerNotFound 远程节点上不存在该 key
+var ErrPeerNotFound = errors.New("远程节点未找到该 key")
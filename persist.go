@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Persister 缓存的持久化接口，用于崩溃恢复
+// 写入缓存时会同步调用 Save，value 为 nil 表示该 key 已经被删除；创建缓存时会调用 Load 恢复数据
+// 只有写入和显式删除会被记录，key 的过期时间以及过期、容量淘汰造成的删除都不会记录，
+// 因此恢复时所有数据都按照 DefaultTTL 重新写入，已经过期或者被淘汰的key也会被恢复
+type Persister interface {
+	Save(key string, value []byte) error
+	Load() (map[string][]byte, error)
+}
+
+// 持久化文件中的记录类型
+const (
+	recordSet    = "S"
+	recordDelete = "D"
+)
+
+// 文件中的记录数超过 2*存活的key数量+compactMinRecords 时压缩文件
+const compactMinRecords = 1024
+
+// FilePersister 基于文件的 Persister 实现，每次写入都追加一行记录
+// 记录格式为 "<类型> <base64(key)> <base64(value)>"，加载时按顺序回放所有记录
+// 同一个key被反复写入时文件中会留下大量过时的记录，记录数增长到一定程度时会自动压缩，只保留每个key最新的值
+type FilePersister struct {
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	records int // 文件中的记录数
+	live    int // 上一次回放时仍然存在的key数量
+}
+
+// NewFilePersister 打开（不存在时创建）持久化文件
+func NewFilePersister(path string) (*FilePersister, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("打开持久化文件失败:%v", err)
+	}
+	return &FilePersister{path: path, file: file}, nil
+}
+
+// Save 追加一条记录，value 为 nil 时记录删除操作
+// 记录已经写入但是随后的压缩失败时同样返回错误，压缩会在下一次写入时重试
+func (p *FilePersister) Save(key string, value []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, err := p.file.WriteString(encodeRecord(key, value)); err != nil {
+		return err
+	}
+	p.records++
+	if !p.needCompact() {
+		return nil
+	}
+	data, err := p.replay()
+	if err != nil {
+		return fmt.Errorf("压缩持久化文件失败:%v", err)
+	}
+	return p.compact(data)
+}
+
+// 编码一条记录，value 为 nil 时为删除记录
+func encodeRecord(key string, value []byte) string {
+	if value == nil {
+		return recordDelete + " " + base64.StdEncoding.EncodeToString([]byte(key)) + "\n"
+	}
+	return recordSet + " " + base64.StdEncoding.EncodeToString([]byte(key)) + " " + base64.StdEncoding.EncodeToString(value) + "\n"
+}
+
+// Load 按顺序回放文件中的所有记录，返回最终仍然存在的数据
+// 写入最后一条记录时进程崩溃会留下不完整的记录，这条记录会被丢弃并从文件中截断；文件中间的记录损坏时返回错误
+func (p *FilePersister) Load() (map[string][]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	data, err := p.replay()
+	if err != nil {
+		return nil, err
+	}
+	if p.needCompact() {
+		if err := p.compact(data); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// Compact 重写持久化文件，只保留每个key最新的值，删除的key和过时的记录都会被丢弃
+func (p *FilePersister) Compact() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	data, err := p.replay()
+	if err != nil {
+		return err
+	}
+	return p.compact(data)
+}
+
+// 过时的记录是否已经多到需要压缩，调用此方法前必须持有锁
+func (p *FilePersister) needCompact() bool {
+	return p.records >= 2*p.live+compactMinRecords
+}
+
+// replay 按顺序回放文件中的所有记录，并截断末尾不完整的记录，调用此方法前必须持有锁
+func (p *FilePersister) replay() (map[string][]byte, error) {
+	file, err := os.Open(p.path)
+	if err != nil {
+		return nil, fmt.Errorf("打开持久化文件失败:%v", err)
+	}
+	defer file.Close()
+
+	data := make(map[string][]byte)
+	// value 可能很大，按换行符读取，不限制单行的长度
+	reader := bufio.NewReaderSize(file, 64*1024)
+	var offset int64 // 已经成功回放的记录的结尾位置
+	records := 0
+	for lineNo := 1; ; lineNo++ {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			// 没有换行符的最后一行是写入到一半的记录
+			if len(line) > 0 {
+				if err := p.truncate(offset); err != nil {
+					return nil, err
+				}
+			}
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("读取持久化文件失败:%v", err)
+		}
+		if err := applyRecord(data, string(line[:len(line)-1])); err != nil {
+			// 损坏的记录位于文件末尾时同样当作写入到一半的记录，位于中间时说明文件已经损坏
+			if _, peekErr := reader.Peek(1); peekErr != io.EOF {
+				return nil, fmt.Errorf("持久化文件第 %d 行损坏:%v", lineNo, err)
+			}
+			if err := p.truncate(offset); err != nil {
+				return nil, err
+			}
+			break
+		}
+		offset += int64(len(line))
+		records++
+	}
+	p.records, p.live = records, len(data)
+	return data, nil
+}
+
+// 回放一条记录
+func applyRecord(data map[string][]byte, line string) error {
+	fields := strings.Split(line, " ")
+	if len(fields) < 2 {
+		return fmt.Errorf("格式错误")
+	}
+	key, err := base64.StdEncoding.DecodeString(fields[1])
+	if err != nil {
+		return fmt.Errorf("key 解码失败:%v", err)
+	}
+	switch {
+	case fields[0] == recordDelete && len(fields) == 2:
+		delete(data, string(key))
+	case fields[0] == recordSet && len(fields) == 3:
+		value, err := base64.StdEncoding.DecodeString(fields[2])
+		if err != nil {
+			return fmt.Errorf("value 解码失败:%v", err)
+		}
+		data[string(key)] = value
+	default:
+		return fmt.Errorf("格式错误")
+	}
+	return nil
+}
+
+// 把文件截断到 offset，丢弃之后不完整的记录，调用此方法前必须持有锁
+func (p *FilePersister) truncate(offset int64) error {
+	if err := p.file.Truncate(offset); err != nil {
+		return fmt.Errorf("截断持久化文件中不完整的记录失败:%v", err)
+	}
+	return nil
+}
+
+// compact 把 data 写入一个新文件，写入完成后替换原来的文件，调用此方法前必须持有锁
+// 先写临时文件再重命名，压缩过程中崩溃不会损坏原来的文件
+func (p *FilePersister) compact(data map[string][]byte) error {
+	tmp := p.path + ".compact"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("创建压缩文件失败:%v", err)
+	}
+	w := bufio.NewWriter(file)
+	for key, value := range data {
+		w.WriteString(encodeRecord(key, value))
+	}
+	if err = w.Flush(); err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, p.path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("写入压缩文件失败:%v", err)
+	}
+	// 原来的文件句柄指向已经被替换的文件，重新打开
+	newFile, err := os.OpenFile(p.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("打开持久化文件失败:%v", err)
+	}
+	p.file.Close()
+	p.file = newFile
+	p.records, p.live = len(data), len(data)
+	return nil
+}
+
+// Close 关闭持久化文件
+func (p *FilePersister) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.file.Close()
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// 写入、删除和空值都能通过持久化文件恢复
+func TestFilePersisterRestore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.log")
+	p, err := NewFilePersister(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opt := DefaultCacheOptions()
+	opt.Persister = p
+	c := NewCache(&opt)
+	c.Add("a", NewByteView([]byte("1\n2")))
+	c.Add("b", NewByteView([]byte("2")))
+	c.Delete("b")
+	c.Add("e", NewByteView(nil))
+	c.Close()
+	p.Close()
+
+	p2, err := NewFilePersister(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p2.Close()
+	opt2 := DefaultCacheOptions()
+	opt2.Persister = p2
+	c2 := NewCache(&opt2)
+	defer c2.Close()
+	ctx := context.Background()
+	if v, ok := c2.Get(ctx, "a"); !ok || v.String() != "1\n2" {
+		t.Fatalf("a 恢复为 %q %v", v.String(), ok)
+	}
+	if _, ok := c2.Get(ctx, "b"); ok {
+		t.Fatal("已经删除的 b 不应该被恢复")
+	}
+	if _, ok := c2.Get(ctx, "e"); !ok {
+		t.Fatal("空值 e 应该被恢复")
+	}
+}
+
+// 末尾写入到一半的记录被丢弃并截断，之后的写入可以正常追加；文件中间的记录损坏时返回错误
+func TestFilePersisterTornRecord(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    map[string]string
+		wantErr bool
+	}{
+		{"末尾没有换行符", "S YQ== MQ==\nS Yg== Mg", map[string]string{"a": "1"}, false},
+		{"末尾记录损坏", "S YQ== MQ==\nS Yg==\n", map[string]string{"a": "1"}, false},
+		{"中间记录损坏", "S YQ== MQ==\nS Yg==\nS Yw== Mw==\n", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "cache.log")
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			p, err := NewFilePersister(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer p.Close()
+			data, err := p.Load()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("文件中间的记录损坏时应该返回错误")
+				}
+				return
+			}
+			if err != nil || len(data) != len(tt.want) {
+				t.Fatalf("Load() = %v, %v", data, err)
+			}
+			for k, v := range tt.want {
+				if string(data[k]) != v {
+					t.Fatalf("%s 的值为 %q，期望 %q", k, data[k], v)
+				}
+			}
+			// 截断之后追加的记录不会和不完整的记录拼在同一行
+			if err := p.Save("c", []byte("3")); err != nil {
+				t.Fatal(err)
+			}
+			if data, err := p.Load(); err != nil || string(data["c"]) != "3" {
+				t.Fatalf("截断之后写入的记录没有被正确回放: %v, %v", data, err)
+			}
+		})
+	}
+}
+
+// 同一个key反复写入时文件会被自动压缩，压缩前后回放的结果一致
+func TestFilePersisterCompact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.log")
+	p, err := NewFilePersister(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	for i := 0; i < 10*compactMinRecords; i++ {
+		if err := p.Save("k"+strconv.Itoa(i%10), []byte(strconv.Itoa(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p.Save("k0", nil)
+	if p.records > 2*p.live+compactMinRecords {
+		t.Fatalf("文件中有 %d 条记录，存活的key只有 %d 个", p.records, p.live)
+	}
+	data, err := p.Load()
+	if err != nil || len(data) != 9 || string(data["k9"]) != strconv.Itoa(10*compactMinRecords-1) {
+		t.Fatalf("压缩之后回放的结果错误: %d 个key, %v", len(data), err)
+	}
+
+	if err := p.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if p.records != 9 {
+		t.Fatalf("Compact 之后文件中应该只有 9 条记录，实际为 %d", p.records)
+	}
+	if _, err := os.Stat(path + ".compact"); !os.IsNotExist(err) {
+		t.Fatal("压缩完成后不应该留下临时文件")
+	}
+}
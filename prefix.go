@@ -0,0 +1,138 @@
+package main
+
+import (
+	"Distributed-Cache-Go/lru"
+	"strings"
+	"time"
+)
+
+// prefixStore 给所有key加上固定前缀之后再访问底层存储，配置了 KeyPrefix 时由 Cache 使用
+// Range 和 DumpKeys 返回去掉前缀之后的逻辑key，不带该前缀的key（不应该出现）会被跳过
+type prefixStore struct {
+	store  lru.Store
+	prefix string
+}
+
+func (s *prefixStore) AddAndUpdateCache(key string, value lru.Value) error {
+	return s.store.AddAndUpdateCache(s.prefix+key, value)
+}
+
+func (s *prefixStore) AddWithTTL(key string, value lru.Value, ttl time.Duration) error {
+	return s.store.AddWithTTL(s.prefix+key, value, ttl)
+}
+
+func (s *prefixStore) AddIfAbsent(key string, value lru.Value, ttl time.Duration) (bool, error) {
+	return s.store.AddIfAbsent(s.prefix+key, value, ttl)
+}
+
+func (s *prefixStore) DeleteCache(key string) error {
+	return s.store.DeleteCache(s.prefix + key)
+}
+
+func (s *prefixStore) FindCache(key string) (lru.Value, bool) {
+	return s.store.FindCache(s.prefix + key)
+}
+
+func (s *prefixStore) Peek(key string) (lru.Value, bool) {
+	return s.store.Peek(s.prefix + key)
+}
+
+func (s *prefixStore) Contains(key string) bool {
+	return s.store.Contains(s.prefix + key)
+}
+
+func (s *prefixStore) TTL(key string) (time.Duration, bool) {
+	return s.store.TTL(s.prefix + key)
+}
+
+func (s *prefixStore) Range(f func(key string, value lru.Value) bool) {
+	s.store.Range(func(key string, value lru.Value) bool {
+		if !strings.HasPrefix(key, s.prefix) {
+			return true
+		}
+		return f(key[len(s.prefix):], value)
+	})
+}
+
+func (s *prefixStore) Len() int {
+	return s.store.Len()
+}
+
+func (s *prefixStore) Bytes() int64 {
+	return s.store.Bytes()
+}
+
+func (s *prefixStore) Clear() {
+	s.store.Clear()
+}
+
+func (s *prefixStore) Resize(maxBytes int64) int {
+	return s.store.Resize(maxBytes)
+}
+
+func (s *prefixStore) Close() {
+	s.store.Close()
+}
+
+// Stats 底层存储支持时返回它的统计信息，否则返回空
+func (s *prefixStore) Stats() lru.CacheStats {
+	if st, ok := s.store.(interface{ Stats() lru.CacheStats }); ok {
+		return st.Stats()
+	}
+	return lru.CacheStats{}
+}
+
+// ResetStats 底层存储支持时清零它的统计信息并返回清零之前的值，否则返回空
+func (s *prefixStore) ResetStats() lru.CacheStats {
+	if st, ok := s.store.(interface{ ResetStats() lru.CacheStats }); ok {
+		return st.ResetStats()
+	}
+	return lru.CacheStats{}
+}
+
+// DumpKeys 底层存储支持时返回去掉前缀之后的key信息，否则返回空
+func (s *prefixStore) DumpKeys() []lru.KeyInfo {
+	d, ok := s.store.(interface{ DumpKeys() []lru.KeyInfo })
+	if !ok {
+		return nil
+	}
+	infos := d.DumpKeys()
+	result := infos[:0]
+	for _, info := range infos {
+		if strings.HasPrefix(info.Key, s.prefix) {
+			info.Key = info.Key[len(s.prefix):]
+			result = append(result, info)
+		}
+	}
+	return result
+}
+
+// GetMany 给所有key加上前缀之后批量查找，返回的结果中是去掉前缀之后的key
+func (s *prefixStore) GetMany(keys []string) map[string]lru.Value {
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = s.prefix + key
+	}
+	found := lru.GetMany(s.store, prefixed)
+	result := make(map[string]lru.Value, len(found))
+	for key, value := range found {
+		result[key[len(s.prefix):]] = value
+	}
+	return result
+}
+
+// AddMany 给所有key加上前缀之后批量写入，返回的错误中是去掉前缀之后的key
+func (s *prefixStore) AddMany(items map[string]lru.Value) map[string]error {
+	prefixed := make(map[string]lru.Value, len(items))
+	for key, value := range items {
+		prefixed[s.prefix+key] = value
+	}
+	var errs map[string]error
+	for key, err := range lru.AddMany(s.store, prefixed) {
+		if errs == nil {
+			errs = make(map[string]error)
+		}
+		errs[key[len(s.prefix):]] = err
+	}
+	return errs
+}
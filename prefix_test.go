@@ -0,0 +1,63 @@
+package main
+
+import (
+	"Distributed-Cache-Go/lru"
+	"context"
+	"reflect"
+	"testing"
+)
+
+// 两个 KeyPrefix 不同的缓存共享同一个持久化存储，同一个逻辑key互不冲突；DumpKeys、keys 和 OnEvicted 看到的都是逻辑key
+func TestKeyPrefix(t *testing.T) {
+	p := newMemPersister()
+	var evicted []string
+	optA := DefaultCacheOptions()
+	optA.KeyPrefix = "a:"
+	optA.Persister = p
+	optA.OnEvicted = func(key string, _ lru.Value, _ lru.EvictReason) { evicted = append(evicted, key) }
+	a := NewCache(&optA)
+	defer a.Close()
+	optB := DefaultCacheOptions()
+	optB.KeyPrefix = "b:"
+	optB.Persister = p
+	b := NewCache(&optB)
+	defer b.Close()
+	ctx := context.Background()
+	a.AddBytes("k", []byte("A"))
+	b.AddBytes("k", []byte("B"))
+
+	tests := []struct {
+		name string
+		c    *Cache
+		want string
+	}{
+		{"a:", a, "A"},
+		{"b:", b, "B"},
+	}
+	for _, tt := range tests {
+		if v, ok := tt.c.Get(ctx, "k"); !ok || v.String() != tt.want {
+			t.Fatalf("前缀 %s 读取到 %q %v，期望 %q", tt.name, v.String(), ok, tt.want)
+		}
+		if ks := tt.c.keys(); !reflect.DeepEqual(ks, []string{"k"}) {
+			t.Fatalf("前缀 %s 的 keys() = %v", tt.name, ks)
+		}
+		if ks := tt.c.DumpKeys(); len(ks) != 1 || ks[0].Key != "k" {
+			t.Fatalf("前缀 %s 的 DumpKeys() = %v", tt.name, ks)
+		}
+	}
+
+	// 从共享的持久化存储恢复时只加载自己前缀下的数据
+	a2 := NewCache(&optA)
+	defer a2.Close()
+	if v, ok := a2.Get(ctx, "k"); !ok || v.String() != "A" || a2.Stats().Entries != 1 {
+		t.Fatalf("恢复之后读取到 %q %v，共 %d 个条目", v.String(), ok, a2.Stats().Entries)
+	}
+
+	a.Delete("k")
+	if !reflect.DeepEqual(evicted, []string{"k"}) {
+		t.Fatalf("OnEvicted 收到的key为 %v", evicted)
+	}
+	if v, ok := b.Get(ctx, "k"); !ok || v.String() != "B" {
+		t.Fatal("删除 a 中的key不应该影响 b")
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// 节点之间的协议版本，HTTP 通过请求头和响应头中的 X-Cache-Protocol 协商，gRPC 通过 metadata 中的 x-cache-protocol 协商
+//
+//	0  没有版本头的旧节点，请求体和响应体都是原始字节
+//	1  在版本 0 的基础上加上版本头，消息格式不变
+//
+// 客户端在请求头中带上自己支持的最高版本，服务端回复双方都支持的最高版本；
+// 旧节点会忽略请求头，回复中也不带版本头，视为版本 0，所以新节点默认仍然可以与旧节点互相访问。
+// 以后修改消息格式时增加版本号，并按照协商出的版本编码和解码
+const (
+	protocolHeader  = "X-Cache-Protocol"
+	protocolVersion = 1
+	// gRPC 的 metadata 中使用的key，gRPC 要求 metadata 的key为小写，含义与 protocolHeader 相同
+	grpcProtocolKey = "x-cache-protocol"
+)
+
+// ErrProtocolVersion 远程节点的协议版本不在当前节点支持的范围内，或者版本头无法解析
+// 这种错误不会因为重试而消失，所以不会被重试
+var ErrProtocolVersion = errors.New("节点之间的协议版本不兼容")
+
+// 解析版本头，没有版本头时为 0
+func parseProtocolVersion(header string) (int, error) {
+	if header == "" {
+		return 0, nil
+	}
+	v, err := strconv.Atoi(header)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("%w: 无法解析版本 %q", ErrProtocolVersion, header)
+	}
+	return v, nil
+}
+
+// 检查对方的版本是否在 [minVersion, protocolVersion] 之间
+func checkProtocolVersion(v int, minVersion int) error {
+	if v < minVersion || v > protocolVersion {
+		return fmt.Errorf("%w: 对方版本为 %d，当前节点支持 %d 到 %d", ErrProtocolVersion, v, minVersion, protocolVersion)
+	}
+	return nil
+}
+
+// SetMinProtocolVersion 设置与其他节点通信时接受的最低协议版本，默认为 0，即兼容没有版本头的旧节点
+// 集群中所有节点都升级之后可以调高，此时低于该版本的请求会被拒绝并返回 400，访问低于该版本的节点会返回 ErrProtocolVersion
+func (p *HTTPPool) SetMinProtocolVersion(v int) {
+	if v < 0 {
+		v = 0
+	}
+	if v > protocolVersion {
+		v = protocolVersion
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.minProtocolVersion = v
+	// 正在使用中的 httpGetter 可能被其他协程读取，所以替换成新的对象而不是直接修改
+	for peer, getter := range p.httpGetters {
+		p.httpGetters[peer] = &httpGetter{baseURL: getter.baseURL, timeout: getter.timeout, minVersion: v}
+	}
+}
+
+// negotiateProtocol 检查请求的协议版本并在响应头中写入协商出的版本，版本不兼容时返回 400 和 false
+func (p *HTTPPool) negotiateProtocol(w http.ResponseWriter, r *http.Request) bool {
+	p.mu.Lock()
+	minVersion := p.minProtocolVersion
+	p.mu.Unlock()
+	v, err := parseProtocolVersion(r.Header.Get(protocolHeader))
+	if err == nil && v < minVersion {
+		err = checkProtocolVersion(v, minVersion)
+	}
+	if err != nil {
+		// 带上当前节点的版本，客户端据此给出明确的错误，而不是把错误信息当作缓存值
+		w.Header().Set(protocolHeader, strconv.Itoa(protocolVersion))
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	// 对方版本更高时按照当前节点的版本回复，由对方决定是否接受
+	if v > protocolVersion {
+		v = protocolVersion
+	}
+	if v > 0 {
+		w.Header().Set(protocolHeader, strconv.Itoa(v))
+	}
+	return true
+}
+
+// 检查远程节点回复的协议版本，必须先于状态码检查，这样版本不兼容的 400 会返回 ErrProtocolVersion
+func (h *httpGetter) checkProtocol(resp *http.Response) error {
+	v, err := parseProtocolVersion(resp.Header.Get(protocolHeader))
+	if err != nil {
+		return err
+	}
+	return checkProtocolVersion(v, h.minVersion)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+)
+
+const (
+	defaultRetryAttempts = 3
+	defaultRetryBase     = 50 * time.Millisecond
+	defaultRetryMax      = time.Second
+)
+
+// RetryOptions 访问远程节点失败时的重试参数
+// 第 n 次重试之前等待 min(BaseDelay*2^(n-1), MaxDelay)，再在 [d/2, d] 之间随机抖动，避免多个节点同时重试
+type RetryOptions struct {
+	MaxAttempts int           // 最多尝试的次数（包括第一次），默认为 3
+	BaseDelay   time.Duration // 第一次重试之前的等待时间，默认为 50ms
+	MaxDelay    time.Duration // 单次等待时间的上限，默认为 1s
+}
+
+func (o RetryOptions) withDefault() RetryOptions {
+	if o.MaxAttempts <= 0 {
+		o.MaxAttempts = defaultRetryAttempts
+	}
+	if o.BaseDelay <= 0 {
+		o.BaseDelay = defaultRetryBase
+	}
+	if o.MaxDelay <= 0 {
+		o.MaxDelay = defaultRetryMax
+	}
+	if o.MaxDelay < o.BaseDelay {
+		o.MaxDelay = o.BaseDelay
+	}
+	return o
+}
+
+// 第 attempt 次重试（从 1 开始）之前的等待时间
+func (o RetryOptions) backoff(attempt int) time.Duration {
+	d := o.BaseDelay
+	for i := 1; i < attempt && d < o.MaxDelay; i++ {
+		d *= 2
+	}
+	if d > o.MaxDelay {
+		d = o.MaxDelay
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(d-half)+1))
+}
+
+// retryPeer 为远程节点加上重试，实现了 PeerGetter 和 PeerSetter 接口
+type retryPeer struct {
+	peer PeerGetter
+	opts RetryOptions
+}
+
+// NewRetryPeer 为 peer 加上指数退避重试，peer 实现了 PeerSetter 时写入同样会重试
+// 远程节点未命中、熔断器打开、协议版本不兼容以及 ctx 结束时不会重试；剩余时间不够等待下一次重试时直接返回最后一次的错误
+func NewRetryPeer(peer PeerGetter, opts RetryOptions) PeerGetter {
+	return &retryPeer{peer: peer, opts: opts.withDefault()}
+}
+
+// 判断失败的请求是否值得重试
+func isRetryable(ctx context.Context, err error) bool {
+	return isPeerFailure(ctx, err) && !errors.Is(err, ErrCircuitOpen) && !errors.Is(err, ErrProtocolVersion)
+}
+
+// do 执行 fn，失败时按照退避策略重试
+func (p *retryPeer) do(ctx context.Context, fn func() error) error {
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = fn()
+		if attempt >= p.opts.MaxAttempts || !isRetryable(ctx, err) {
+			return err
+		}
+		wait := p.opts.backoff(attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return err
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+	}
+}
+
+func (p *retryPeer) Get(ctx context.Context, group string, key string) ([]byte, error) {
+	var b []byte
+	err := p.do(ctx, func() error {
+		var err error
+		b, err = p.peer.Get(ctx, group, key)
+		return err
+	})
+	return b, err
+}
+
+func (p *retryPeer) Set(ctx context.Context, group string, key string, value []byte) error {
+	setter, ok := p.peer.(PeerSetter)
+	if !ok {
+		return errors.New("远程节点不支持写入")
+	}
+	return p.do(ctx, func() error {
+		return setter.Set(ctx, group, key, value)
+	})
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"Distributed-Cache-Go/lru"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 远程节点失败两次之后恢复，重试在预算之内拿到正确的值
+func TestRetryEventuallySucceeds(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) <= 2 {
+			http.Error(w, "暂时不可用", http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+	pool := NewHTTPPool("self", nil)
+	pool.Set(srv.URL)
+	pool.SetRetry(RetryOptions{BaseDelay: 10 * time.Millisecond})
+	peer, ok := pool.PickPeer("k")
+	if !ok {
+		t.Fatal("应该选中远程节点")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	start := time.Now()
+	b, err := peer.Get(ctx, "", "k")
+	if err != nil || string(b) != "ok" || atomic.LoadInt32(&calls) != 3 {
+		t.Fatalf("Get() = %q, %v，远程节点被调用 %d 次", b, err, calls)
+	}
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Fatalf("两次重试耗时 %v，超出了退避时间", d)
+	}
+}
+
+// 最大尝试次数、不可重试的错误以及 ctx 截止时间都会限制重试
+func TestRetryAttempts(t *testing.T) {
+	fail := errors.New("连接失败")
+	tests := []struct {
+		name      string
+		err       error
+		opts      RetryOptions
+		timeout   time.Duration
+		wantCalls int
+		maxCalls  int
+	}{
+		{"用完全部尝试次数", fail, RetryOptions{MaxAttempts: 4, BaseDelay: time.Millisecond}, 0, 4, 4},
+		{"第一次成功不重试", nil, RetryOptions{MaxAttempts: 4, BaseDelay: time.Millisecond}, 0, 1, 1},
+		{"未命中不重试", ErrPeerNotFound, RetryOptions{MaxAttempts: 4, BaseDelay: time.Millisecond}, 0, 1, 1},
+		{"熔断器打开不重试", ErrCircuitOpen, RetryOptions{MaxAttempts: 4, BaseDelay: time.Millisecond}, 0, 1, 1},
+		{"协议版本不兼容不重试", ErrProtocolVersion, RetryOptions{MaxAttempts: 4, BaseDelay: time.Millisecond}, 0, 1, 1},
+		// 剩余时间不够等待下一次重试时直接返回
+		{"截止时间限制重试次数", fail, RetryOptions{MaxAttempts: 10, BaseDelay: 20 * time.Millisecond}, 30 * time.Millisecond, 1, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.timeout)
+				defer cancel()
+			}
+			stub := &stubPeer{err: tt.err}
+			_, err := NewRetryPeer(stub, tt.opts).Get(ctx, "g", "k")
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("应该返回最后一次的错误 %v，实际为 %v", tt.err, err)
+			}
+			if stub.calls < tt.wantCalls || stub.calls > tt.maxCalls {
+				t.Fatalf("远程节点被调用 %d 次，期望 %d 到 %d 次", stub.calls, tt.wantCalls, tt.maxCalls)
+			}
+		})
+	}
+}
+
+// 等待重试期间调用方取消 ctx，立即返回而不是等到退避结束
+func TestRetryContextCancel(t *testing.T) {
+	stub := &stubPeer{err: errors.New("连接失败")}
+	p := NewRetryPeer(stub, RetryOptions{MaxAttempts: 3, BaseDelay: time.Hour})
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	start := time.Now()
+	if _, err := p.Get(ctx, "g", "k"); err == nil {
+		t.Fatal("取消之后应该返回错误")
+	}
+	if d := time.Since(start); d > time.Second || stub.calls != 1 {
+		t.Fatalf("取消之后等待了 %v，远程节点被调用 %d 次", d, stub.calls)
+	}
+}
+
+// 退避时间按照指数增长，抖动之后落在 [d/2, d] 之间且不超过 MaxDelay
+func TestRetryBackoff(t *testing.T) {
+	o := RetryOptions{BaseDelay: 10 * time.Millisecond, MaxDelay: 40 * time.Millisecond}.withDefault()
+	tests := []struct {
+		attempt int
+		max     time.Duration
+	}{
+		{1, 10 * time.Millisecond},
+		{2, 20 * time.Millisecond},
+		{3, 40 * time.Millisecond},
+		{10, 40 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 100; i++ {
+			if d := o.backoff(tt.attempt); d < tt.max/2 || d > tt.max {
+				t.Fatalf("第 %d 次重试等待 %v，期望在 [%v, %v] 之间", tt.attempt, d, tt.max/2, tt.max)
+			}
+		}
+	}
+}
+
+// 熔断器包在重试外层，一次带重试的调用只算一次失败；熔断之后不再访问远程节点
+func TestRetryWithBreaker(t *testing.T) {
+	clock := lru.NewFakeClock(time.Unix(0, 0))
+	stub := &stubPeer{err: errors.New("连接失败")}
+	retry := NewRetryPeer(stub, RetryOptions{MaxAttempts: 3, BaseDelay: time.Millisecond})
+	p := NewBreakerPeer(retry, BreakerOptions{FailureThreshold: 2, Cooldown: time.Second, Clock: clock})
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		p.Get(ctx, "g", "k")
+	}
+	if stub.calls != 6 {
+		t.Fatalf("熔断之前每次调用应该尝试 3 次，实际共调用 %d 次", stub.calls)
+	}
+	if _, err := p.Get(ctx, "g", "k"); !errors.Is(err, ErrCircuitOpen) || stub.calls != 6 {
+		t.Fatalf("熔断之后应该快速失败，实际为 %v，调用 %d 次", err, stub.calls)
+	}
+
+	// 重试包在熔断器外层时，熔断器打开返回的错误不会被重试
+	inner := &stubPeer{err: errors.New("连接失败")}
+	p = NewRetryPeer(NewBreakerPeer(inner, BreakerOptions{FailureThreshold: 1, Cooldown: time.Second, Clock: clock}),
+		RetryOptions{MaxAttempts: 5, BaseDelay: time.Millisecond})
+	if _, err := p.Get(ctx, "g", "k"); !errors.Is(err, ErrCircuitOpen) || inner.calls != 1 {
+		t.Fatalf("熔断器打开之后不应该继续重试，实际为 %v，调用 %d 次", err, inner.calls)
+	}
+}
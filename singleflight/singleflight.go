@@ -0,0 +1,73 @@
+package singleflight
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrPanic 执行 fn 的调用发生了 panic，等待同一个 key 的其他调用方会得到这个错误
+var ErrPanic = errors.New("singleflight: fn 发生了 panic")
+
+// call 表示一次正在进行中或者已经完成的调用
+type call struct {
+	wg   sync.WaitGroup
+	val  interface{}
+	err  error
+	dups int // 共享本次调用结果的其他调用方数量
+}
+
+// Group 用于合并对同一个 key 的并发调用，零值即可直接使用
+// 多个协程同时对同一个 key 调用 Do 时，只有第一个协程会真正执行 fn，其余协程等待并共享它的结果，
+// 从而避免缓存未命中时大量请求同时打到后端存储（缓存击穿）
+type Group struct {
+	mu sync.Mutex       // 保护 m
+	m  map[string]*call // 正在进行中的调用
+}
+
+// Do 执行 fn 并返回其结果，同一时刻对同一个 key 只会有一个 fn 在执行
+// 返回值 shared 表示结果是否被多个调用方共享
+// fn 发生 panic 时，等待中的调用方得到 ErrPanic，执行 fn 的调用方在清理完成后重新 panic
+func (g *Group) Do(key string, fn func() (interface{}, error)) (v interface{}, err error, shared bool) {
+	g.mu.Lock()
+	if g.m == nil {
+		g.m = make(map[string]*call)
+	}
+	// 已经有相同 key 的调用在进行中，等待它完成后直接复用结果
+	if c, ok := g.m[key]; ok {
+		c.dups++
+		g.mu.Unlock()
+		c.wg.Wait()
+		return c.val, c.err, true
+	}
+	c := new(call)
+	c.wg.Add(1)
+	g.m[key] = c
+	g.mu.Unlock()
+
+	g.doCall(c, key, fn)
+	return c.val, c.err, c.dups > 0
+}
+
+// doCall 执行 fn，无论 fn 是否 panic 都会唤醒等待的调用方并删除 key，否则之后对该 key 的调用会永远阻塞
+func (g *Group) doCall(c *call, key string, fn func() (interface{}, error)) {
+	normalReturn := false
+	defer func() {
+		var r interface{}
+		if !normalReturn {
+			// recover 返回 nil 说明 fn 调用了 runtime.Goexit，此时不需要重新 panic
+			r = recover()
+			c.val, c.err = nil, fmt.Errorf("%w: %v", ErrPanic, r)
+		}
+		// 调用完成后删除，之后对该 key 的调用会重新执行 fn
+		g.mu.Lock()
+		delete(g.m, key)
+		g.mu.Unlock()
+		c.wg.Done()
+		if r != nil {
+			panic(r)
+		}
+	}()
+	c.val, c.err = fn()
+	normalReturn = true
+}
@@ -0,0 +1,43 @@
+package singleflight
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fn 发生 panic 时，执行 fn 的调用方重新 panic，等待中的调用方得到 ErrPanic，之后对该 key 的调用会重新执行 fn
+func TestDoPanic(t *testing.T) {
+	var g Group
+	started := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Errorf("应该重新 panic，recover 得到 %v", r)
+			}
+		}()
+		g.Do("k", func() (interface{}, error) {
+			close(started)
+			time.Sleep(50 * time.Millisecond)
+			panic("boom")
+		})
+	}()
+	<-started
+	_, err, shared := g.Do("k", func() (interface{}, error) {
+		t.Error("已经有进行中的调用时不应该再次执行 fn")
+		return nil, nil
+	})
+	if !errors.Is(err, ErrPanic) || !shared {
+		t.Fatalf("等待中的调用方应该得到 ErrPanic，实际为 %v %v", err, shared)
+	}
+	wg.Wait()
+
+	v, err, _ := g.Do("k", func() (interface{}, error) { return "v", nil })
+	if err != nil || v != "v" {
+		t.Fatalf("panic 之后再次调用返回 %v %v", v, err)
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"Distributed-Cache-Go/lru"
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// 快照格式：
+//
+//	头部：magic(4 字节 "DCGS") + version(1 字节) + 条目数(uint64)
+//	条目：keyLen(uint32) + key + valueLen(uint32) + value + 过期时间(int64，Unix 纳秒，0 表示永不过期)
+//
+// 所有整数都使用大端序。过期时间记录为绝对时间，导入时据此计算剩余的过期时间并跳过已经过期的条目
+const (
+	snapshotMagic   = "DCGS"
+	snapshotVersion = 1
+	// 导入时单个 key 或者 value 允许的最大长度，没有配置 MaxValueBytes 时使用
+	maxSnapshotFieldBytes = 256 << 20
+)
+
+// ErrBadSnapshot 快照数据格式错误
+var ErrBadSnapshot = errors.New("快照数据格式错误")
+
+// 快照中的一个条目
+type snapshotEntry struct {
+	key      string
+	value    []byte
+	expireAt int64
+}
+
+// Export 将所有未过期的数据（以及它们的过期时间）写入 w，可以在重启后通过 Import 预热缓存
+func (c *Cache) Export(w io.Writer) error {
+	var entries []snapshotEntry
+	if atomic.LoadInt32(&c.initialized) == 1 {
+		// Range 期间不能回调缓存，所以先收集数据，再逐个查询过期时间
+		var keys []string
+		var values []lru.Value
+		c.store.Range(func(key string, value lru.Value) bool {
+			keys = append(keys, key)
+			values = append(values, value)
+			return true
+		})
+		// 解压可能比较耗时，放到 Range 之外进行
+		for i, key := range keys {
+			if bv, err := c.decodeValue(key, values[i]); err == nil {
+				entries = append(entries, snapshotEntry{key: key, value: bv.b})
+			}
+		}
+	}
+	now := c.now()
+	live := entries[:0]
+	for _, e := range entries {
+		ttl, ok := c.store.TTL(e.key)
+		if !ok {
+			// 收集之后才过期或者被删除的条目直接跳过
+			continue
+		}
+		if ttl > 0 {
+			e.expireAt = now.Add(ttl).UnixNano()
+		}
+		live = append(live, e)
+	}
+
+	bw := bufio.NewWriter(w)
+	header := make([]byte, 0, len(snapshotMagic)+1+8)
+	header = append(header, snapshotMagic...)
+	header = append(header, snapshotVersion)
+	header = binary.BigEndian.AppendUint64(header, uint64(len(live)))
+	if _, err := bw.Write(header); err != nil {
+		return err
+	}
+	for _, e := range live {
+		buf := make([]byte, 0, 4+len(e.key)+4+len(e.value)+8)
+		buf = binary.BigEndian.AppendUint32(buf, uint32(len(e.key)))
+		buf = append(buf, e.key...)
+		buf = binary.BigEndian.AppendUint32(buf, uint32(len(e.value)))
+		buf = append(buf, e.value...)
+		buf = binary.BigEndian.AppendUint64(buf, uint64(e.expireAt))
+		if _, err := bw.Write(buf); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// Import 从 r 中读取 Export 导出的快照并写入缓存，已经过期的条目会被跳过，缓存已经关闭或者正在 Drain 时返回 ErrCacheClosed
+func (c *Cache) Import(r io.Reader) error {
+	if atomic.LoadInt32(&c.closed) == 1 {
+		return ErrCacheClosed
+	}
+	if !c.beginWrite() {
+		return ErrCacheClosed
+	}
+	defer c.endWrite()
+	br := bufio.NewReader(r)
+	header := make([]byte, len(snapshotMagic)+1+8)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return fmt.Errorf("%w: 读取头部失败:%v", ErrBadSnapshot, err)
+	}
+	if string(header[:len(snapshotMagic)]) != snapshotMagic {
+		return fmt.Errorf("%w: magic 不匹配", ErrBadSnapshot)
+	}
+	if version := header[len(snapshotMagic)]; version != snapshotVersion {
+		return fmt.Errorf("%w: 不支持的版本 %d", ErrBadSnapshot, version)
+	}
+	count := binary.BigEndian.Uint64(header[len(snapshotMagic)+1:])
+
+	c.ensureInitialized()
+	limit := c.snapshotFieldLimit()
+	var lenBuf [8]byte
+	for i := uint64(0); i < count; i++ {
+		key, err := readSnapshotField(br, limit)
+		if err != nil {
+			return fmt.Errorf("%w: 读取第 %d 个条目失败:%v", ErrBadSnapshot, i, err)
+		}
+		value, err := readSnapshotField(br, limit)
+		if err != nil {
+			return fmt.Errorf("%w: 读取第 %d 个条目失败:%v", ErrBadSnapshot, i, err)
+		}
+		if _, err := io.ReadFull(br, lenBuf[:8]); err != nil {
+			return fmt.Errorf("%w: 读取第 %d 个条目失败:%v", ErrBadSnapshot, i, err)
+		}
+		expireAt := int64(binary.BigEndian.Uint64(lenBuf[:8]))
+
+		var ttl time.Duration
+		if expireAt != 0 {
+			ttl = time.Unix(0, expireAt).Sub(c.now())
+			if ttl <= 0 {
+				continue
+			}
+		}
+		err = c.store.AddWithTTL(string(key), c.encodeValue(string(key), ByteView{b: value}), ttl)
+		if err != nil {
+			c.log.Error("导入缓存数据失败", zap.String("key", string(key)), zap.Error(err))
+			continue
+		}
+		c.persist(string(key), value)
+	}
+	return nil
+}
+
+// now 返回导出、导入快照时使用的当前时间，与底层存储使用同一个 Clock，没有配置时使用系统时间
+func (c *Cache) now() time.Time {
+	if c.cacheOptions.Clock != nil {
+		return c.cacheOptions.Clock.Now()
+	}
+	return time.Now()
+}
+
+// 导入时单个 key 或者 value 允许的最大长度，配置了 MaxValueBytes 并且没有开启压缩时使用 MaxValueBytes，
+// 开启压缩时快照中是解压之后的数据，可能超过 MaxValueBytes，所以使用固定的上限
+func (c *Cache) snapshotFieldLimit() uint32 {
+	if n := c.cacheOptions.MaxValueBytes; n > 0 && n < maxSnapshotFieldBytes && c.cacheOptions.CompressThreshold <= 0 {
+		return uint32(n)
+	}
+	return maxSnapshotFieldBytes
+}
+
+// 读取一个 uint32 长度前缀以及对应的数据，长度超过 limit 时直接返回错误，不会按照损坏的长度分配内存
+func readSnapshotField(r io.Reader, limit uint32) ([]byte, error) {
+	var lenBuf [4]byte
+	if _, err := io.ReadFull(r, lenBuf[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(lenBuf[:])
+	if n > limit {
+		return nil, fmt.Errorf("长度 %d 超过了上限 %d", n, limit)
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
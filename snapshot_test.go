@@ -0,0 +1,100 @@
+package main
+
+import (
+	"Distributed-Cache-Go/lru"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	opt := DefaultCacheOptions()
+	src := NewCache(&opt)
+	defer src.Close()
+	src.Add("a", NewByteView([]byte("1")))
+	src.Set(context.Background(), "b", NewByteView([]byte("2")))
+	var buf bytes.Buffer
+	if err := src.Export(&buf); err != nil {
+		t.Fatal(err)
+	}
+	dst := NewCache(&opt)
+	defer dst.Close()
+	if err := dst.Import(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]string{"a": "1", "b": "2"} {
+		if v, ok := dst.Get(context.Background(), key); !ok || v.String() != want {
+			t.Fatalf("key %q 导入之后为 %v %v", key, v, ok)
+		}
+	}
+}
+
+// 损坏的长度字段不会导致按照该长度分配内存
+func TestSnapshotCorruptLength(t *testing.T) {
+	var buf bytes.Buffer
+	buf.WriteString(snapshotMagic)
+	buf.WriteByte(snapshotVersion)
+	buf.Write(binary.BigEndian.AppendUint64(nil, 1))
+	buf.Write(binary.BigEndian.AppendUint32(nil, 0xffffffff))
+	opt := DefaultCacheOptions()
+	c := NewCache(&opt)
+	defer c.Close()
+	if err := c.Import(&buf); !errors.Is(err, ErrBadSnapshot) {
+		t.Fatalf("应该返回 ErrBadSnapshot，实际为 %v", err)
+	}
+	opt.MaxValueBytes = 8
+	limited := NewCache(&opt)
+	defer limited.Close()
+	var entry bytes.Buffer
+	entry.WriteString(snapshotMagic)
+	entry.WriteByte(snapshotVersion)
+	entry.Write(binary.BigEndian.AppendUint64(nil, 1))
+	entry.Write(binary.BigEndian.AppendUint32(nil, 1))
+	entry.WriteString("k")
+	entry.Write(binary.BigEndian.AppendUint32(nil, 9))
+	entry.WriteString("123456789")
+	entry.Write(binary.BigEndian.AppendUint64(nil, uint64(time.Now().Add(time.Hour).UnixNano())))
+	if err := limited.Import(&entry); !errors.Is(err, ErrBadSnapshot) {
+		t.Fatalf("超过 MaxValueBytes 时应该返回 ErrBadSnapshot，实际为 %v", err)
+	}
+}
+
+// 导出和导入都使用配置的 Clock 计算过期时间，导入时已经过期的条目被跳过；缓存关闭后 Import 返回 ErrCacheClosed
+func TestSnapshotUsesClock(t *testing.T) {
+	clock := lru.NewFakeClock(time.Unix(1000, 0))
+	opt := DefaultCacheOptions()
+	opt.Clock = clock
+	opt.DisableBackgroundCleanup = true
+	src := NewCache(&opt)
+	defer src.Close()
+	src.Add("a", NewByteView([]byte("1")))
+	src.store.AddWithTTL("short", NewByteView([]byte("x")), time.Second)
+	src.store.AddWithTTL("long", NewByteView([]byte("y")), time.Hour)
+	var buf bytes.Buffer
+	if err := src.Export(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	clock.Advance(time.Minute)
+	dst := NewCache(&opt)
+	if err := dst.Import(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := dst.Get(context.Background(), "a"); !ok || v.String() != "1" {
+		t.Fatalf("a 导入为 %q %v", v.String(), ok)
+	}
+	if _, ok := dst.Get(context.Background(), "short"); ok {
+		t.Fatal("按照 Clock 已经过期的 short 不应该被导入")
+	}
+	if ttl, ok := dst.store.TTL("long"); !ok || ttl != 59*time.Minute {
+		t.Fatalf("long 剩余的过期时间为 %v，期望 %v", ttl, 59*time.Minute)
+	}
+
+	dst.Close()
+	if err := dst.Import(bytes.NewReader(buf.Bytes())); !errors.Is(err, ErrCacheClosed) {
+		t.Fatalf("缓存关闭后 Import 应该返回 ErrCacheClosed，实际为 %v", err)
+	}
+}
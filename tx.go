@@ -0,0 +1,177 @@
+package main
+
+import (
+	"Distributed-Cache-Go/lru"
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// Tx 事务，Set 和 Delete 只记录在事务内部，Transaction 提交时才会一起写入缓存
+type Tx struct {
+	c      *Cache
+	writes map[string]*ByteView // 缓冲的写操作，值为 nil 表示删除
+}
+
+// Get 先查找事务内缓冲的写操作，事务内删除过的key返回未命中；其余key直接读取缓存当前的值
+// 读取不会加锁，所以事务并不隔离其他协程在 fn 执行期间的写入
+func (tx *Tx) Get(key string) (ByteView, bool) {
+	if v, ok := tx.writes[key]; ok {
+		if v == nil {
+			return ByteView{}, false
+		}
+		return *v, true
+	}
+	return tx.c.Get(context.Background(), key)
+}
+
+// Set 在事务中写入key，使用默认的过期时间
+func (tx *Tx) Set(key string, value ByteView) {
+	tx.writes[key] = &value
+}
+
+// Delete 在事务中删除key
+func (tx *Tx) Delete(key string) {
+	tx.writes[key] = nil
+}
+
+// 提交前记录的旧值，用于回滚
+type txUndo struct {
+	key   string
+	value lru.Value // 为空表示提交前key不存在
+	ttl   time.Duration
+}
+
+// Transaction 执行 fn，fn 返回 nil 时把事务中缓冲的所有写操作原子地应用到缓存，返回错误时丢弃全部写操作并原样返回该错误
+// 提交时持有所有相关key的锁以及缓存的写锁，其他协程的读取要么看到全部写操作，要么一个都看不到；
+// 某个写操作失败（例如超过 MaxValueBytes）时会恢复已经应用的key并返回错误，此时不会写入 Persister
+// 提交写入的数据可能会因为容量不足淘汰其他key，这部分淘汰不会回滚；写操作只作用于本地缓存，不会写入副本节点
+// 提交期间（包括后台清理）触发的 OnEvicted 会推迟到提交完成、释放锁之后再调用，所以回调中可以访问缓存
+func (c *Cache) Transaction(fn func(tx *Tx) error) error {
+	if atomic.LoadInt32(&c.closed) == 1 {
+		return ErrCacheClosed
+	}
+	tx := &Tx{c: c, writes: make(map[string]*ByteView)}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if len(tx.writes) == 0 {
+		return nil
+	}
+	if !c.beginWrite() {
+		return ErrCacheClosed
+	}
+	// 提交期间的淘汰回调在释放所有锁（包括 endWrite）之后才调用，OnEvicted 中可以正常读写缓存
+	c.holdEvictions()
+	defer c.releaseEvictions()
+	defer c.checkHighWater()
+	defer c.endWrite()
+	c.ensureInitialized()
+
+	keys := make([]string, 0, len(tx.writes))
+	for key := range tx.writes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	unlock := c.keyLocks.LockKeys(keys)
+	defer unlock()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if atomic.LoadInt32(&c.closed) == 1 {
+		return ErrCacheClosed
+	}
+
+	undo := make([]txUndo, 0, len(keys))
+	for _, key := range keys {
+		u := txUndo{key: key}
+		if old, ok := c.store.Peek(key); ok {
+			if ttl, ok := c.store.TTL(key); ok {
+				u.value, u.ttl = old, ttl
+			}
+		}
+		undo = append(undo, u)
+		var err error
+		if v := tx.writes[key]; v != nil {
+			err = c.store.AddAndUpdateCache(key, c.encodeValue(key, *v))
+		} else {
+			err = c.store.DeleteCache(key)
+		}
+		if err != nil {
+			c.rollback(undo)
+			return fmt.Errorf("Transaction 提交失败，已回滚:%v", err.Error())
+		}
+	}
+	for _, key := range keys {
+		if v := tx.writes[key]; v != nil {
+			c.persist(key, v.ByteSlice())
+		} else {
+			c.persist(key, nil)
+		}
+	}
+	return nil
+}
+
+// 按照相反的顺序恢复提交前的旧值，调用此方法前必须持有相关key的锁和写锁
+func (c *Cache) rollback(undo []txUndo) {
+	for i := len(undo) - 1; i >= 0; i-- {
+		u := undo[i]
+		var err error
+		if u.value == nil {
+			err = c.store.DeleteCache(u.key)
+		} else {
+			err = c.store.AddWithTTL(u.key, u.value, u.ttl)
+		}
+		if err != nil {
+			c.log.Error("Transaction 回滚失败", zap.String("key", u.key), zap.Error(err))
+		}
+	}
+}
+
+// 等待调用的淘汰回调
+type pendingEviction struct {
+	key    string
+	value  lru.Value
+	reason lru.EvictReason
+}
+
+// 包装底层存储的淘汰回调，有 Transaction 正在提交时先记录下来，由 releaseEvictions 在释放锁之后调用
+func (c *Cache) deferrableOnEvicted(fn func(key string, value lru.Value, reason lru.EvictReason)) func(key string, value lru.Value, reason lru.EvictReason) {
+	return func(key string, value lru.Value, reason lru.EvictReason) {
+		c.evictMu.Lock()
+		if c.evictHolds > 0 {
+			c.pendingEvictions = append(c.pendingEvictions, pendingEviction{key: key, value: value, reason: reason})
+			c.evictMu.Unlock()
+			return
+		}
+		c.evictMu.Unlock()
+		fn(key, value, reason)
+	}
+}
+
+// 开始推迟淘汰回调，必须与 releaseEvictions 成对调用
+func (c *Cache) holdEvictions() {
+	c.evictMu.Lock()
+	c.evictHolds++
+	c.evictMu.Unlock()
+}
+
+// 结束推迟淘汰回调，最后一个结束的 Transaction 负责调用期间积累的所有回调
+func (c *Cache) releaseEvictions() {
+	c.evictMu.Lock()
+	c.evictHolds--
+	var pending []pendingEviction
+	if c.evictHolds == 0 {
+		pending, c.pendingEvictions = c.pendingEvictions, nil
+	}
+	c.evictMu.Unlock()
+	if len(pending) == 0 {
+		return
+	}
+	fn := c.cacheOptions.onEvicted()
+	for _, e := range pending {
+		fn(e.key, e.value, e.reason)
+	}
+}
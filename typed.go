@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"go.uber.org/zap"
+)
+
+// TypedCache 基于泛型的类型安全缓存封装，通过编码/解码函数在 T 和 ByteView 之间转换
+// 调用方不再需要对 Value 做类型断言
+type TypedCache[T any] struct {
+	cache  *Cache
+	encode func(value T) ([]byte, error)
+	decode func(data []byte) (T, error)
+}
+
+// NewTypedCache 创建类型安全的缓存，encode 或 decode 为空时使用 cache 配置的 Codec（默认为 gob）
+func NewTypedCache[T any](cache *Cache, encode func(value T) ([]byte, error), decode func(data []byte) (T, error)) *TypedCache[T] {
+	codec := cache.codec()
+	if encode == nil {
+		encode = func(value T) ([]byte, error) {
+			return codec.Marshal(value)
+		}
+	}
+	if decode == nil {
+		decode = func(data []byte) (T, error) {
+			var value T
+			err := codec.Unmarshal(data, &value)
+			return value, err
+		}
+	}
+	return &TypedCache[T]{
+		cache:  cache,
+		encode: encode,
+		decode: decode,
+	}
+}
+
+// Add 编码后写入缓存，编码失败或者写入失败时返回错误
+func (c *TypedCache[T]) Add(key string, value T) error {
+	b, err := c.encode(value)
+	if err != nil {
+		return err
+	}
+	return c.cache.Add(key, ByteView{b: b})
+}
+
+// Get 查找并解码，未命中或者解码失败时返回 false
+func (c *TypedCache[T]) Get(ctx context.Context, key string) (T, bool) {
+	var zero T
+	value, ok := c.cache.Get(ctx, key)
+	if !ok {
+		return zero, false
+	}
+	result, err := c.decode(value.b)
+	if err != nil {
+		c.cache.log.Error("缓存值解码失败", zap.String("key", key), zap.Error(err))
+		return zero, false
+	}
+	return result, true
+}
+
+// Delete 删除缓存
+func (c *TypedCache[T]) Delete(key string) {
+	c.cache.Delete(key)
+}
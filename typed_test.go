@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+type typedUser struct {
+	Name string
+	Age  int
+}
+
+// TypedCache[typedUser] 使用默认的 gob 和自定义的 JSON 编码都能往返结构体，未命中、解码失败以及删除之后返回零值
+func TestTypedCache(t *testing.T) {
+	tests := []struct {
+		name   string
+		encode func(typedUser) ([]byte, error)
+		decode func([]byte) (typedUser, error)
+	}{
+		{"默认编码", nil, nil},
+		{"JSON 编码", func(u typedUser) ([]byte, error) {
+			return json.Marshal(u)
+		}, func(b []byte) (typedUser, error) {
+			var u typedUser
+			err := json.Unmarshal(b, &u)
+			return u, err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opt := DefaultCacheOptions()
+			c := NewCache(&opt)
+			defer c.Close()
+			tc := NewTypedCache[typedUser](c, tt.encode, tt.decode)
+			ctx := context.Background()
+			want := typedUser{Name: "张三", Age: 30}
+			if err := tc.Add("u", want); err != nil {
+				t.Fatal(err)
+			}
+			if got, ok := tc.Get(ctx, "u"); !ok || got != want {
+				t.Fatalf("Get(u) = %+v %v", got, ok)
+			}
+			if got, ok := tc.Get(ctx, "none"); ok || got != (typedUser{}) {
+				t.Fatalf("未命中时应该返回零值，实际为 %+v %v", got, ok)
+			}
+			c.AddBytes("bad", []byte{0xff, 0x00})
+			if got, ok := tc.Get(ctx, "bad"); ok || got != (typedUser{}) {
+				t.Fatalf("解码失败时应该返回零值，实际为 %+v %v", got, ok)
+			}
+			tc.Delete("u")
+			if _, ok := tc.Get(ctx, "u"); ok {
+				t.Fatal("删除之后不应该命中")
+			}
+		})
+	}
+}
+
+// 编码失败时返回错误并且不写入缓存
+func TestTypedCacheEncodeError(t *testing.T) {
+	opt := DefaultCacheOptions()
+	c := NewCache(&opt)
+	defer c.Close()
+	want := errors.New("编码失败")
+	tc := NewTypedCache[typedUser](c, func(typedUser) ([]byte, error) { return nil, want }, nil)
+	if err := tc.Add("u", typedUser{}); !errors.Is(err, want) {
+		t.Fatalf("应该返回编码的错误，实际为 %v", err)
+	}
+	if _, ok := c.Get(context.Background(), "u"); ok {
+		t.Fatal("编码失败时不应该写入缓存")
+	}
+}
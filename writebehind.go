@@ -0,0 +1,145 @@
+package main
+
+import (
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+// 异步持久化的默认参数
+const (
+	defaultWriteBehindInterval  = time.Second
+	defaultWriteBehindBatchSize = 1024
+)
+
+// WriteBehindPersister 异步（write-behind）持久化，包装另一个 Persister
+// Save 只把 key 记录为脏数据，由后台协程按 interval 定时、或者脏数据达到 batchSize 时批量写入底层 Persister；
+// 同一个 key 在两次刷新之间的多次写入只会保留最后一次。Close 会等待后台协程退出并做最后一次刷新，之后的 Save 直接同步写入
+type WriteBehindPersister struct {
+	p         Persister
+	interval  time.Duration
+	batchSize int
+	log       *zap.Logger
+
+	mu      sync.Mutex
+	dirty   map[string][]byte // value 为 nil 表示删除
+	closed  bool
+	flushMu sync.Mutex // 保证批量写入按顺序进行
+
+	flushCh   chan struct{}
+	stopCh    chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewWriteBehindPersister 创建异步持久化，interval 和 batchSize 小于等于 0 时使用默认值
+// 底层 Persister 的生命周期由调用方管理，Close 不会关闭它
+func NewWriteBehindPersister(p Persister, interval time.Duration, batchSize int, log *zap.Logger) *WriteBehindPersister {
+	if interval <= 0 {
+		interval = defaultWriteBehindInterval
+	}
+	if batchSize <= 0 {
+		batchSize = defaultWriteBehindBatchSize
+	}
+	if log == nil {
+		log = zap.NewNop()
+	}
+	w := &WriteBehindPersister{
+		p:         p,
+		interval:  interval,
+		batchSize: batchSize,
+		log:       log,
+		dirty:     make(map[string][]byte),
+		flushCh:   make(chan struct{}, 1),
+		stopCh:    make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go w.flushLoop()
+	return w
+}
+
+// Save 记录脏数据，脏数据达到 batchSize 时通知后台协程立即刷新；关闭之后直接同步写入底层 Persister
+func (w *WriteBehindPersister) Save(key string, value []byte) error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		w.flushMu.Lock()
+		defer w.flushMu.Unlock()
+		return w.p.Save(key, value)
+	}
+	w.dirty[key] = value
+	full := len(w.dirty) >= w.batchSize
+	w.mu.Unlock()
+	if full {
+		select {
+		case w.flushCh <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Load 先把尚未写入的脏数据刷新到底层 Persister，再从底层 Persister 加载
+func (w *WriteBehindPersister) Load() (map[string][]byte, error) {
+	w.Flush()
+	return w.p.Load()
+}
+
+// Flush 把当前所有脏数据写入底层 Persister，写入失败的 key 只记录日志
+func (w *WriteBehindPersister) Flush() {
+	w.flush(false)
+}
+
+// flush 取出当前的脏数据并写入底层 Persister，close 为 true 时在取出的同时标记关闭
+// 两步在同一次加锁中完成，并且整个过程持有 flushMu，保证关闭之后同步写入的数据不会被旧的脏数据覆盖
+func (w *WriteBehindPersister) flush(close bool) {
+	w.flushMu.Lock()
+	defer w.flushMu.Unlock()
+	w.mu.Lock()
+	batch := w.dirty
+	w.dirty = make(map[string][]byte)
+	if close {
+		w.closed = true
+	}
+	w.mu.Unlock()
+	for key, value := range batch {
+		if err := w.p.Save(key, value); err != nil {
+			w.log.Error("异步持久化写入失败", zap.String("key", key), zap.Error(err))
+		}
+	}
+	if len(batch) > 0 {
+		w.log.Debug("异步持久化刷新完成", zap.Int("count", len(batch)))
+	}
+}
+
+// Pending 返回尚未写入底层 Persister 的脏数据数量
+func (w *WriteBehindPersister) Pending() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.dirty)
+}
+
+// Close 停止后台协程并做最后一次刷新，保证关闭之前的写入都不会丢失，重复调用是安全的
+func (w *WriteBehindPersister) Close() {
+	w.closeOnce.Do(func() {
+		close(w.stopCh)
+		<-w.done
+		w.flush(true)
+	})
+}
+
+func (w *WriteBehindPersister) flushLoop() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			w.Flush()
+		case <-w.flushCh:
+			w.Flush()
+		case <-w.stopCh:
+			return
+		}
+	}
+}
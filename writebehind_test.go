@@ -0,0 +1,128 @@
+package main
+
+import (
+	"errors"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 写入指定key时返回错误的 Persister
+type failingPersister struct {
+	*memPersister
+	failKey string
+}
+
+func (p *failingPersister) Save(key string, value []byte) error {
+	if key == p.failKey {
+		return errors.New("磁盘已满")
+	}
+	return p.memPersister.Save(key, value)
+}
+
+// 脏数据达到 batchSize 时立即批量写入，不需要等待 interval；同一个key在两次刷新之间只保留最后一次写入
+func TestWriteBehindBatch(t *testing.T) {
+	p := newMemPersister()
+	w := NewWriteBehindPersister(p, time.Hour, 10, nil)
+	defer w.Close()
+	for i := 0; i < 9; i++ {
+		w.Save(strconv.Itoa(i), []byte("old"))
+		w.Save(strconv.Itoa(i), []byte("new"))
+	}
+	if data, _ := p.Load(); len(data) != 0 || w.Pending() != 9 {
+		t.Fatalf("没有达到 batchSize 时不应该写入，已经写入 %d 个，Pending=%d", len(data), w.Pending())
+	}
+	w.Save("9", []byte("new"))
+	deadline := time.Now().Add(time.Second)
+	for w.Pending() != 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	data, _ := p.Load()
+	if len(data) != 10 {
+		t.Fatalf("达到 batchSize 之后应该写入 10 个，实际为 %d", len(data))
+	}
+	for k, v := range data {
+		if string(v) != "new" {
+			t.Fatalf("%s 写入的是 %q，应该只保留最后一次写入", k, v)
+		}
+	}
+}
+
+// Close 把尚未写入的数据全部刷新，之后的 Save 直接同步写入；通过缓存使用时删除也会被持久化
+func TestWriteBehindFlushOnClose(t *testing.T) {
+	p := newMemPersister()
+	opt := DefaultCacheOptions()
+	opt.Persister = p
+	opt.WriteBehindInterval = time.Hour
+	opt.WriteBehindBatchSize = 1 << 20
+	c := NewCache(&opt)
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				c.AddBytes(strconv.Itoa(g)+"-"+strconv.Itoa(i), []byte("v"))
+			}
+		}(g)
+	}
+	wg.Wait()
+	c.Delete("0-0")
+	if data, _ := p.Load(); len(data) != 0 {
+		t.Fatalf("Close 之前不应该写入底层 Persister，实际写入了 %d 个", len(data))
+	}
+	c.Close()
+	if data, _ := p.Load(); len(data) != 1999 {
+		t.Fatalf("Close 之后应该写入 1999 个，实际为 %d", len(data))
+	}
+
+	w := NewWriteBehindPersister(p, time.Hour, 0, nil)
+	w.Close()
+	w.Save("late", []byte("x"))
+	if data, _ := p.Load(); string(data["late"]) != "x" {
+		t.Fatal("Close 之后的 Save 应该直接同步写入")
+	}
+
+	// 与 FilePersister 组合使用
+	fp, err := NewFilePersister(filepath.Join(t.TempDir(), "cache.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fp.Close()
+	opt = DefaultCacheOptions()
+	opt.Persister = fp
+	opt.WriteBehindInterval = time.Hour
+	c = NewCache(&opt)
+	c.AddBytes("a", []byte("1"))
+	c.Close()
+	if data, _ := fp.Load(); string(data["a"]) != "1" {
+		t.Fatalf("关闭之后文件中读取到 %v", data)
+	}
+}
+
+// 刷新时某个key写入失败只记录错误日志，不影响同一批次中的其他key
+func TestWriteBehindFlushError(t *testing.T) {
+	core, logs := observer.New(zap.ErrorLevel)
+	p := &failingPersister{memPersister: newMemPersister(), failKey: "bad"}
+	w := NewWriteBehindPersister(p, time.Hour, 0, zap.New(core))
+	defer w.Close()
+	w.Save("a", []byte("1"))
+	w.Save("bad", []byte("2"))
+	w.Save("b", []byte("3"))
+	w.Flush()
+	data, _ := p.Load()
+	if len(data) != 2 || string(data["a"]) != "1" || string(data["b"]) != "3" {
+		t.Fatalf("写入失败的key不应该影响其他key，实际写入了 %v", data)
+	}
+	if w.Pending() != 0 {
+		t.Fatalf("刷新之后 Pending=%d", w.Pending())
+	}
+	entries := logs.FilterField(zap.String("key", "bad")).All()
+	if len(entries) != 1 || entries[0].Message != "异步持久化写入失败" {
+		t.Fatalf("写入失败时应该记录一条错误日志，实际为 %v", entries)
+	}
+}